@@ -0,0 +1,73 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// Allocator provides the memory of the byte slices allocated while decoding,
+// e.g. from an arena or a pool that can be freed in bulk once the decoded values
+// aren't used anymore.
+//
+// Bytes must return a slice of length n, which the decoder fully overwrites.
+// The decoded values reference the returned memory directly: it must stay valid,
+// and must not be reused, for as long as any decoded value is in use.
+// Releasing it earlier results in decoded values silently changing.
+type Allocator interface {
+	Bytes(n int) []byte
+}
+
+// SetAllocator sets the allocator of the byte slices returned by ReadNBytes
+// and of the decoded []byte values (the element types of which don't implement
+// BinaryUnmarshaler). Strings, and slices of other types, are still allocated by Go.
+// A nil allocator, which is the default, uses the regular Go allocation.
+func (dec *Decoder) SetAllocator(a Allocator) {
+	dec.allocator = a
+}
+
+// allocBytes returns a slice of n bytes from the allocator of the decoder.
+func (dec *Decoder) allocBytes(n int) ([]byte, error) {
+	out := dec.allocator.Bytes(n)
+	if len(out) != n {
+		return nil, fmt.Errorf("allocator: requested %d bytes, got %d", n, len(out))
+	}
+	return out, nil
+}
+
+func isByteSliceType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Slice &&
+		rt.Elem().Kind() == reflect.Uint8 &&
+		!reflect.PtrTo(rt.Elem()).Implements(unmarshalableType) &&
+		!rt.Elem().Implements(unmarshalableType)
+}
+
+// readAllocatedBytes reads the l bytes of a []byte value
+// into memory provided by the allocator of the decoder.
+func (dec *Decoder) readAllocatedBytes(rv reflect.Value, l int) error {
+	data, err := dec.ReadNBytes(l)
+	if err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read allocated bytes", zap.Int("len", l))
+	}
+	rv.SetBytes(data)
+	dec.reportProgress()
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// arena hands out consecutive chunks of a single buffer.
+type arena struct {
+	buf   []byte
+	calls int
+}
+
+func (a *arena) Bytes(n int) []byte {
+	a.calls++
+	out := a.buf[:n:n]
+	a.buf = a.buf[n:]
+	return out
+}
+
+type shortAllocator struct{}
+
+func (shortAllocator) Bytes(n int) []byte { return make([]byte, n-1) }
+
+func TestDecoder_SetAllocator(t *testing.T) {
+	type record struct {
+		ID   uint8
+		Data []byte
+		Keys [][]byte
+		Name string
+	}
+	in := record{ID: 1, Data: []byte{1, 2, 3}, Keys: [][]byte{{4}, {5, 6}}, Name: "abc"}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			mem := &arena{buf: make([]byte, 64)}
+			backing := mem.buf
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			dec.SetAllocator(mem)
+
+			var out record
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 3, mem.calls)
+			assert.Equal(t, []byte{1, 2, 3, 4, 5, 6}, backing[:6])
+
+			chunk, err := NewDecoderWithEncoding([]byte{7, 8}, encoding).ReadNBytes(2)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{7, 8}, chunk)
+		})
+	}
+
+	dec := NewBorshDecoder([]byte{0x01, 0x02})
+	dec.SetAllocator(shortAllocator{})
+	_, err := dec.ReadNBytes(2)
+	assert.EqualError(t, err, "allocator: requested 2 bytes, got 1")
+	assert.Equal(t, 0, int(dec.Position()))
+}
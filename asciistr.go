@@ -0,0 +1,70 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// asciiStrPadding holds the characters trimmed from
+// the end of a decoded `bin:"asciistr=N"` string.
+const asciiStrPadding = "\x00 "
+
+func checkASCIIStr(rv reflect.Value, size int) error {
+	if rv.Kind() != reflect.String {
+		return fmt.Errorf("asciistr: expected a string field, got %s", rv.Type())
+	}
+	if size <= 0 {
+		return fmt.Errorf("asciistr: the tag must specify a positive byte count, like asciistr=32")
+	}
+	return nil
+}
+
+// decodeASCIIStr reads a `bin:"asciistr=N"` string field: N bytes
+// with the trailing NUL and space characters trimmed.
+func (dec *Decoder) decodeASCIIStr(rv reflect.Value, size int) error {
+	if err := checkASCIIStr(rv, size); err != nil {
+		return err
+	}
+	data, err := dec.ReadNBytes(size)
+	if err != nil {
+		return fmt.Errorf("asciistr: %w", err)
+	}
+	out := strings.TrimRight(string(data), asciiStrPadding)
+	if traceEnabled {
+		zlog.Debug("decode: read asciistr", zap.Int("size", size), zap.String("val", out))
+	}
+	rv.SetString(out)
+	return nil
+}
+
+// encodeASCIIStr writes a `bin:"asciistr=N"` string field,
+// padded with NUL characters up to N bytes.
+func (e *Encoder) encodeASCIIStr(rv reflect.Value, size int) error {
+	if err := checkASCIIStr(rv, size); err != nil {
+		return err
+	}
+	s := rv.String()
+	if len(s) > size {
+		return fmt.Errorf("asciistr: string of %d bytes doesn't fit in %d bytes", len(s), size)
+	}
+	data := make([]byte, size)
+	copy(data, s)
+	return e.toWriter(data)
+}
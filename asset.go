@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+)
+
+// Symbol is the symbol of an EOS asset: its precision,
+// and its name of up to TypeSize.CurrencyName characters.
+type Symbol struct {
+	Precision uint8
+	Name      string
+}
+
+// Asset is an EOS asset: Amount counts units of 10^-Symbol.Precision of the symbol.
+//
+// It has the wire format of Money (see Money), but groups the precision and the name
+// into a Symbol, which EOS also encodes on its own, e.g. in token contract actions.
+type Asset struct {
+	Amount int64
+	Symbol Symbol
+}
+
+// Money returns the asset as a Money amount, which has the same wire format.
+func (a Asset) Money() Money {
+	return Money{Amount: a.Amount, Precision: a.Symbol.Precision, Currency: a.Symbol.Name}
+}
+
+// String formats the amount with the precision of the symbol, then its name, e.g. "1.0000 EOS".
+func (a Asset) String() string {
+	return a.Money().String()
+}
+
+func (s *Symbol) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadSymbol()
+	if err != nil {
+		return err
+	}
+	*s = out
+	return nil
+}
+
+func (s Symbol) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteSymbol(s)
+}
+
+func (a *Asset) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadAsset()
+	if err != nil {
+		return err
+	}
+	*a = out
+	return nil
+}
+
+func (a Asset) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteAsset(a)
+}
+
+// ReadSymbol reads an EOS symbol: see Symbol.
+func (dec *Decoder) ReadSymbol() (out Symbol, err error) {
+	if out.Precision, err = dec.ReadUint8(); err != nil {
+		return out, fmt.Errorf("symbol: precision: %w", err)
+	}
+	if out.Name, err = dec.ReadCurrencyName(); err != nil {
+		return out, fmt.Errorf("symbol: %w", err)
+	}
+	return out, nil
+}
+
+// ReadAsset reads an EOS asset: see Asset.
+func (dec *Decoder) ReadAsset() (Asset, error) {
+	m, err := dec.ReadMoney()
+	if err != nil {
+		return Asset{}, fmt.Errorf("asset: %w", err)
+	}
+	return Asset{Amount: m.Amount, Symbol: Symbol{Precision: m.Precision, Name: m.Currency}}, nil
+}
+
+// WriteSymbol writes an EOS symbol: see Symbol.
+func (e *Encoder) WriteSymbol(s Symbol) error {
+	if err := e.WriteUint8(s.Precision); err != nil {
+		return err
+	}
+	return e.WriteCurrencyName(s.Name)
+}
+
+// WriteAsset writes an EOS asset: see Asset.
+func (e *Encoder) WriteAsset(a Asset) error {
+	return e.WriteMoney(a.Money())
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsset(t *testing.T) {
+	type transfer struct {
+		From     uint64
+		Quantity Asset
+	}
+	in := transfer{From: 1, Quantity: Asset{Amount: 10000, Symbol: Symbol{Precision: 4, Name: "EOS"}}}
+
+	data, err := MarshalBin(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x10, 0x27, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x04,
+		'E', 'O', 'S', 0x00, 0x00, 0x00, 0x00,
+	}, data)
+
+	var out transfer
+	require.NoError(t, NewBinDecoder(data).Decode(&out))
+	assert.Equal(t, in, out)
+	assert.Equal(t, "1.0000 EOS", out.Quantity.String())
+
+	// An asset has the wire format of Money.
+	money, err := NewBinDecoder(data[8:]).ReadMoney()
+	require.NoError(t, err)
+	assert.Equal(t, Money{Amount: 10000, Precision: 4, Currency: "EOS"}, money)
+	assert.Equal(t, money, out.Quantity.Money())
+
+	asset, err := NewBinDecoder(data[8:]).ReadAsset()
+	require.NoError(t, err)
+	assert.Equal(t, in.Quantity, asset)
+
+	_, err = NewBinDecoder(data[8:17]).ReadAsset()
+	assert.Error(t, err)
+}
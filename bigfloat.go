@@ -0,0 +1,123 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var bigFloatType = reflect.TypeOf(big.Float{})
+
+// ReadBigFloat reads an arbitrary-precision float encoded as a sign byte
+// (0 for positive, 1 for negative), a zigzag varint exponent (that fits in 32 bits), and
+// a length-prefixed big-endian unsigned integer mantissa: the value is mantissa × 2^exponent.
+// The precision of the result is the bit length of the mantissa, and at least 64.
+func (dec *Decoder) ReadBigFloat() (*big.Float, error) {
+	sign, err := dec.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("bigfloat: sign: %w", err)
+	}
+	if sign > 1 {
+		return nil, fmt.Errorf("bigfloat: invalid sign byte %d", sign)
+	}
+	exp, err := dec.ReadVarintN(32)
+	if err != nil {
+		return nil, fmt.Errorf("bigfloat: exponent: %w", err)
+	}
+	mantBytes, err := dec.ReadByteSlice()
+	if err != nil {
+		return nil, fmt.Errorf("bigfloat: mantissa: %w", err)
+	}
+
+	mant := new(big.Int).SetBytes(mantBytes)
+	prec := uint(mant.BitLen())
+	if prec < 64 {
+		prec = 64
+	}
+	out := new(big.Float).SetPrec(prec).SetInt(mant)
+	out.SetMantExp(out, int(exp))
+	if sign == 1 {
+		out.Neg(out)
+	}
+	return out, nil
+}
+
+// WriteBigFloat writes a finite arbitrary-precision float: see ReadBigFloat.
+// The mantissa is the smallest integer that represents f exactly.
+func (e *Encoder) WriteBigFloat(f *big.Float) error {
+	if f.IsInf() {
+		return fmt.Errorf("bigfloat: cannot encode an infinite value")
+	}
+	var sign byte
+	if f.Signbit() {
+		sign = 1
+	}
+	if err := e.WriteByte(sign); err != nil {
+		return err
+	}
+
+	var exp int
+	var mant []byte
+	if f.Sign() != 0 {
+		abs := new(big.Float).Abs(f)
+		// f = m × 2^fexp with 0.5 <= m < 1, and m has prec significant bits.
+		prec := int(abs.MinPrec())
+		fexp := abs.MantExp(nil)
+		intMant, _ := new(big.Float).SetMantExp(abs, prec-fexp).Int(nil)
+		mant = intMant.Bytes()
+		exp = fexp - prec
+	}
+	if err := e.WriteVarInt(exp); err != nil {
+		return err
+	}
+	return e.WriteBytes(mant, true)
+}
+
+// decodeBigFloat reads a `bin:"bigfloat"` big.Float or *big.Float field.
+func (dec *Decoder) decodeBigFloat(rv reflect.Value) error {
+	f, err := dec.ReadBigFloat()
+	if err != nil {
+		return err
+	}
+	switch rv.Type() {
+	case bigFloatType:
+		rv.Set(reflect.ValueOf(f).Elem())
+	case reflect.PtrTo(bigFloatType):
+		rv.Set(reflect.ValueOf(f))
+	default:
+		return fmt.Errorf("bigfloat: expected a big.Float field, got %s", rv.Type())
+	}
+	return nil
+}
+
+// encodeBigFloat writes a `bin:"bigfloat"` big.Float or *big.Float field;
+// a nil *big.Float is encoded as zero.
+func (e *Encoder) encodeBigFloat(rv reflect.Value) error {
+	switch rv.Type() {
+	case bigFloatType:
+		f := rv.Interface().(big.Float)
+		return e.WriteBigFloat(&f)
+	case reflect.PtrTo(bigFloatType):
+		f := rv.Interface().(*big.Float)
+		if f == nil {
+			f = new(big.Float)
+		}
+		return e.WriteBigFloat(f)
+	default:
+		return fmt.Errorf("bigfloat: expected a big.Float field, got %s", rv.Type())
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigFloat(t *testing.T) {
+	type measure struct {
+		Value *big.Float `bin:"bigfloat"`
+		Other big.Float  `bin:"bigfloat"`
+	}
+
+	huge, _, err := big.ParseFloat("-1.5e400", 10, 200, big.ToNearestEven)
+	require.NoError(t, err)
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			in := measure{Value: huge, Other: *big.NewFloat(0.75)}
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out measure
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Zero(t, in.Value.Cmp(out.Value))
+			assert.Zero(t, in.Other.Cmp(&out.Other))
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).WriteBigFloat(big.NewFloat(-0.75)))
+	// -3 × 2^-2
+	assert.Equal(t, []byte{0x01, 0x03, 0x01, 0x00, 0x00, 0x00, 0x03}, buf.Bytes())
+
+	buf.Reset()
+	require.NoError(t, NewBorshEncoder(buf).WriteBigFloat(new(big.Float)))
+	f, err := NewBorshDecoder(buf.Bytes()).ReadBigFloat()
+	require.NoError(t, err)
+	assert.Equal(t, 0, f.Sign())
+
+	_, err = NewBorshDecoder([]byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x00}).ReadBigFloat()
+	assert.EqualError(t, err, "bigfloat: invalid sign byte 2")
+
+	err = NewBorshEncoder(buf).WriteBigFloat(new(big.Float).SetInf(false))
+	assert.Error(t, err)
+}
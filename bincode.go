@@ -0,0 +1,172 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// The markers of the Bincode varint integer encoding: a first byte below
+// bincodeVarintU16 is the value itself, otherwise it selects the width
+// of the little endian value that follows.
+const (
+	bincodeVarintU16  = 251
+	bincodeVarintU32  = 252
+	bincodeVarintU64  = 253
+	bincodeVarintU128 = 254
+)
+
+// ReadBincodeVarint reads an unsigned integer in the varint mode of Bincode
+// (similar to the CompactSize of Bitcoin): values up to 250 take a single byte,
+// and larger ones a marker byte (251, 252, 253 or 254) followed by the value as a
+// little endian u16, u32, u64 or u128. A u128 that doesn't fit in 64 bits is an error.
+// On error, the position of the decoder is left unchanged.
+//
+// Note that this is NOT the same format as ReadUvarint64 (LEB128) or ReadCompactU16.
+func (dec *Decoder) ReadBincodeVarint() (out uint64, err error) {
+	start := dec.pos
+	defer func() {
+		if err != nil {
+			dec.pos = start
+		}
+	}()
+
+	marker, err := dec.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("bincode varint: %w", err)
+	}
+	switch marker {
+	case bincodeVarintU16:
+		var n uint16
+		n, err = dec.ReadUint16(binary.LittleEndian)
+		out = uint64(n)
+	case bincodeVarintU32:
+		var n uint32
+		n, err = dec.ReadUint32(binary.LittleEndian)
+		out = uint64(n)
+	case bincodeVarintU64:
+		out, err = dec.ReadUint64(binary.LittleEndian)
+	case bincodeVarintU128:
+		var n Uint128
+		if n, err = dec.ReadUint128(binary.LittleEndian); err == nil && n.Hi != 0 {
+			return 0, errorf(ErrInvalidLength, "bincode varint: u128 value overflows 64 bits")
+		}
+		out = n.Lo
+	case 255:
+		return 0, fmt.Errorf("bincode varint: invalid marker byte %d", marker)
+	default:
+		out = uint64(marker)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("bincode varint: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read bincode varint", zap.Uint64("val", out))
+	}
+	return out, nil
+}
+
+// ReadBincodeSignedVarint reads a signed integer in the varint mode of Bincode:
+// its zigzag encoding, as read by ReadBincodeVarint.
+func (dec *Decoder) ReadBincodeSignedVarint() (int64, error) {
+	n, err := dec.ReadBincodeVarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(n>>1) ^ -int64(n&1), nil
+}
+
+// WriteBincodeVarint writes an unsigned integer in the varint mode of Bincode,
+// with the smallest width that fits the value. See Decoder.ReadBincodeVarint.
+func (e *Encoder) WriteBincodeVarint(v uint64) error {
+	if traceEnabled {
+		zlog.Debug("encode: write bincode varint", zap.Uint64("val", v))
+	}
+	var buf [9]byte
+	switch {
+	case v < bincodeVarintU16:
+		buf[0] = byte(v)
+		return e.toWriter(buf[:1])
+	case v <= 0xffff:
+		buf[0] = bincodeVarintU16
+		binary.LittleEndian.PutUint16(buf[1:], uint16(v))
+		return e.toWriter(buf[:3])
+	case v <= 0xffffffff:
+		buf[0] = bincodeVarintU32
+		binary.LittleEndian.PutUint32(buf[1:], uint32(v))
+		return e.toWriter(buf[:5])
+	default:
+		buf[0] = bincodeVarintU64
+		binary.LittleEndian.PutUint64(buf[1:], v)
+		return e.toWriter(buf[:])
+	}
+}
+
+// WriteBincodeSignedVarint writes a signed integer in the varint mode of Bincode:
+// its zigzag encoding, as written by WriteBincodeVarint.
+func (e *Encoder) WriteBincodeSignedVarint(v int64) error {
+	return e.WriteBincodeVarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// isBincodeVarintInt reports whether rv is an integer that is encoded in the varint
+// mode of Bincode with LengthEncodingBincodeVarint: all of them but the single byte ones.
+func isBincodeVarintInt(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// encodeBincodeVarintInt writes the integer rv in the varint mode of Bincode,
+// zigzag encoded if signed.
+func (e *Encoder) encodeBincodeVarintInt(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.WriteBincodeSignedVarint(rv.Int())
+	default:
+		return e.WriteBincodeVarint(rv.Uint())
+	}
+}
+
+// decodeBincodeVarintInt reads the integer rv in the varint mode of Bincode,
+// zigzag encoded if signed. A value that overflows the type of rv is an error.
+func (dec *Decoder) decodeBincodeVarintInt(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := dec.ReadBincodeSignedVarint()
+		if err != nil {
+			return err
+		}
+		if rv.OverflowInt(n) {
+			return errorf(ErrInvalidLength, "bincode varint: value %d overflows %s", n, rv.Type())
+		}
+		rv.SetInt(n)
+	default:
+		n, err := dec.ReadBincodeVarint()
+		if err != nil {
+			return err
+		}
+		if rv.OverflowUint(n) {
+			return errorf(ErrInvalidLength, "bincode varint: value %d overflows %s", n, rv.Type())
+		}
+		rv.SetUint(n)
+	}
+	return nil
+}
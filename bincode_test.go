@@ -0,0 +1,200 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBincodeVarint(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{250, []byte{0xfa}},
+		{251, []byte{0xfb, 0xfb, 0x00}},
+		{0xffff, []byte{0xfb, 0xff, 0xff}},
+		{0x10000, []byte{0xfc, 0x00, 0x00, 0x01, 0x00}},
+		{math.MaxUint64, []byte{0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteBincodeVarint(test.value))
+		assert.Equal(t, test.encoded, buf.Bytes())
+
+		dec := NewBinDecoder(test.encoded)
+		out, err := dec.ReadBincodeVarint()
+		require.NoError(t, err)
+		assert.Equal(t, test.value, out)
+		assert.False(t, dec.HasRemaining())
+	}
+
+	u128 := append([]byte{0xfe, 0x2a}, make([]byte, 15)...)
+	out, err := NewBinDecoder(u128).ReadBincodeVarint()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), out)
+
+	u128[16] = 0x01
+	dec := NewBinDecoder(u128)
+	_, err = dec.ReadBincodeVarint()
+	assert.EqualError(t, err, "bincode varint: u128 value overflows 64 bits")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	assert.Equal(t, 0, int(dec.Position()))
+
+	_, err = NewBinDecoder([]byte{0xff}).ReadBincodeVarint()
+	assert.EqualError(t, err, "bincode varint: invalid marker byte 255")
+
+	dec = NewBinDecoder([]byte{0xfc, 0x01, 0x02})
+	_, err = dec.ReadBincodeVarint()
+	assert.Error(t, err)
+	assert.Equal(t, 0, int(dec.Position()))
+}
+
+func TestBincodeSignedVarint(t *testing.T) {
+	for _, value := range []int64{0, -1, 1, -126, 125, -300, math.MinInt64, math.MaxInt64} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteBincodeSignedVarint(value))
+
+		out, err := NewBinDecoder(buf.Bytes()).ReadBincodeSignedVarint()
+		require.NoError(t, err)
+		assert.Equal(t, value, out)
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WriteBincodeSignedVarint(-1))
+	assert.Equal(t, []byte{0x01}, buf.Bytes())
+}
+
+func TestDecoder_LengthEncodingBincodeVarint(t *testing.T) {
+	data := append([]byte{0xfb, 0x2c, 0x01}, make([]byte, 300)...)
+	dec := NewBorshDecoder(data)
+	dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+
+	var out []byte
+	require.NoError(t, dec.Decode(&out))
+	assert.Len(t, out, 300)
+	assert.False(t, dec.HasRemaining())
+	assert.Equal(t, "BincodeVarint", LengthEncodingBincodeVarint.String())
+}
+
+func TestEncoder_LengthEncodingBincodeVarint(t *testing.T) {
+	type record struct {
+		Values []uint16
+		Counts map[uint8]uint8
+	}
+	in := record{
+		Values: make([]uint16, 300),
+		Counts: map[uint8]uint8{1: 2, 3: 4},
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			enc := NewEncoderWithEncoding(buf, encoding)
+			enc.SetLengthEncoding(LengthEncodingBincodeVarint)
+			require.NoError(t, enc.Encode(in))
+
+			data := buf.Bytes()
+			assert.Equal(t, []byte{0xfb, 0x2c, 0x01}, data[:3])
+			assert.Equal(t, []byte{0x02, 0x01, 0x02, 0x03, 0x04}, data[3+300:])
+
+			var out record
+			dec := NewDecoderWithEncoding(data, encoding)
+			dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.False(t, dec.HasRemaining())
+		})
+	}
+}
+
+func TestBincodeVarintIntegers(t *testing.T) {
+	type record struct {
+		A uint16
+		B int32
+		C uint8
+		D uint64
+		E []uint32
+	}
+	in := record{A: 300, B: -2, C: 7, D: 1 << 32, E: []uint32{1, 251}}
+	// The output of bincode::options().with_varint_encoding() in Rust.
+	expected := []byte{
+		0xfb, 0x2c, 0x01,
+		0x03,
+		0x07,
+		0xfd, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+		0x02, 0x01, 0xfb, 0xfb, 0x00,
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			enc := NewEncoderWithEncoding(buf, encoding)
+			enc.SetLengthEncoding(LengthEncodingBincodeVarint)
+			require.NoError(t, enc.Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out record
+			dec := NewDecoderWithEncoding(expected, encoding)
+			dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.False(t, dec.HasRemaining())
+		})
+	}
+
+	var out uint16
+	dec := NewBinDecoder([]byte{0xfc, 0x00, 0x00, 0x01, 0x00})
+	dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+	err := dec.Decode(&out)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	assert.EqualError(t, err, "bincode varint: value 65536 overflows uint16")
+}
+
+func TestBincodeVarintFixedWidthPaths(t *testing.T) {
+	type flat struct {
+		A uint16
+		B int64
+	}
+	data := []byte{0xfb, 0x2c, 0x01, 0x03, 0xff}
+	rt := reflect.TypeOf(flat{})
+
+	// A flat struct isn't read with fixed-width fields, at the top level too.
+	var out flat
+	dec := NewBinDecoder(data)
+	dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, flat{A: 300, B: -2}, out)
+
+	dec = NewBinDecoder(data)
+	dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+	require.NoError(t, dec.SkipValue(rt))
+	assert.Equal(t, 1, dec.Remaining())
+
+	dec = NewBinDecoder(data)
+	dec.SetLengthEncoding(LengthEncodingBincodeVarint)
+	err := dec.DecodeArrayElement(0, 0, &out)
+	assert.EqualError(t, err, "array element: element type bin.flat doesn't have a fixed size")
+	_, err = dec.SplitElements(rt)
+	assert.EqualError(t, err, "split: element type bin.flat doesn't have a fixed size")
+}
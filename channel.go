@@ -0,0 +1,155 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DecodeToChannelN reads a length-prefixed slice, and sends its elements in order
+// to ch, which must be a channel of the element type (e.g. chan Transfer, and not
+// chan *Transfer). Sending blocks while ch is full, so a slow consumer applies
+// backpressure on decoding; ch is not closed once done.
+//
+// With more than one worker, and elements of a fixed size (see FixedSize),
+// the elements are decoded concurrently by up to workers goroutines, with at most
+// workers elements decoded ahead of the consumer; otherwise they are decoded sequentially.
+//
+// Decoding stops at the first error, which is returned; the elements sent before it
+// stay sent, and the position of the decoder is left unchanged.
+func (dec *Decoder) DecodeToChannelN(ch interface{}, workers int) error {
+	chv := reflect.ValueOf(ch)
+	if chv.Kind() != reflect.Chan || chv.Type().ChanDir()&reflect.SendDir == 0 {
+		return fmt.Errorf("decode to channel: expected a send channel, got %T", ch)
+	}
+	elem := chv.Type().Elem()
+
+	start := dec.pos
+	count, err := dec.ReadLength()
+	if err != nil {
+		dec.pos = start
+		return fmt.Errorf("decode to channel: %w", err)
+	}
+
+	size, fixed := dec.fixedSizeOf(elem)
+	fixed = fixed && size > 0 && !(elem.Kind() == reflect.Struct && hasCLayout(elem))
+	if workers > 1 && fixed {
+		err = dec.decodeToChannelParallel(chv, count, size, workers)
+	} else {
+		err = dec.decodeToChannelSequential(chv, count)
+	}
+	if err != nil {
+		dec.pos = start
+		return fmt.Errorf("decode to channel: %w", err)
+	}
+	return nil
+}
+
+func (dec *Decoder) decodeToChannelSequential(chv reflect.Value, count int) error {
+	elem := chv.Type().Elem()
+	for i := 0; i < count; i++ {
+		v := reflect.New(elem)
+		if err := dec.Decode(v.Interface()); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		chv.Send(v.Elem())
+	}
+	return nil
+}
+
+type channelElement struct {
+	value reflect.Value
+	err   error
+}
+
+type channelJob struct {
+	index  int
+	dec    *Decoder
+	result chan channelElement
+}
+
+func (dec *Decoder) decodeToChannelParallel(chv reflect.Value, count, size, workers int) error {
+	if count > dec.Remaining()/size {
+		return errorf(ErrShortBuffer, "%d elements of %d bytes exceed the %d remaining bytes", count, size, dec.Remaining())
+	}
+	elem := chv.Type().Elem()
+
+	// The results are queued in order: the queue capacity bounds
+	// the number of elements decoded ahead of the consumer.
+	queue := make(chan chan channelElement, workers)
+	jobs := make(chan channelJob)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+		defer close(jobs)
+		pos := dec.pos
+		for i := 0; i < count; i++ {
+			job := channelJob{
+				index:  i,
+				dec:    dec.subDecoder(dec.data[pos : pos+size]),
+				result: make(chan channelElement, 1),
+			}
+			pos += size
+			select {
+			case queue <- job.result:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				v := reflect.New(elem)
+				if err := job.dec.Decode(v.Interface()); err != nil {
+					job.result <- channelElement{err: fmt.Errorf("element %d: %w", job.index, err)}
+					continue
+				}
+				job.result <- channelElement{value: v.Elem()}
+			}
+		}()
+	}
+
+	var err error
+	for result := range queue {
+		element := <-result
+		if element.err != nil {
+			err = element.err
+			break
+		}
+		chv.Send(element.value)
+	}
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	dec.pos += count * size
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type channelPoint struct {
+	X uint32
+	Y float64
+}
+
+func TestDecoder_DecodeToChannelN(t *testing.T) {
+	in := make([]channelPoint, 100)
+	for i := range in {
+		in[i] = channelPoint{X: uint32(i), Y: float64(i) / 2}
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).Encode(in))
+	require.NoError(t, NewBorshEncoder(buf).Encode(uint8(42)))
+
+	for _, workers := range []int{0, 1, 4} {
+		// An unbuffered channel: decoding waits for the consumer.
+		ch := make(chan channelPoint)
+		var got []channelPoint
+		consumed := make(chan struct{})
+		go func() {
+			defer close(consumed)
+			for p := range ch {
+				got = append(got, p)
+			}
+		}()
+
+		dec := NewBorshDecoder(buf.Bytes())
+		require.NoError(t, dec.DecodeToChannelN(ch, workers))
+		close(ch)
+		<-consumed
+		assert.Equal(t, in, got, "workers: %d", workers)
+
+		last, err := dec.ReadUint8()
+		require.NoError(t, err)
+		assert.Equal(t, uint8(42), last)
+	}
+}
+
+func TestDecoder_DecodeToChannelN_VariableSize(t *testing.T) {
+	in := []string{"a", "bb", "ccc"}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(in))
+
+	ch := make(chan string, len(in))
+	dec := NewBinDecoder(buf.Bytes())
+	require.NoError(t, dec.DecodeToChannelN(ch, 4))
+	close(ch)
+	var got []string
+	for s := range ch {
+		got = append(got, s)
+	}
+	assert.Equal(t, in, got)
+	assert.False(t, dec.HasRemaining())
+}
+
+func TestDecoder_DecodeToChannelN_Errors(t *testing.T) {
+	in := make([]channelPoint, 50)
+	in[30].Y = math.NaN()
+	buf := new(bytes.Buffer)
+	// Borsh refuses to encode NaN: write the elements with a Bin encoder.
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUint32(uint32(len(in)), LE))
+	for _, p := range in {
+		require.NoError(t, enc.WriteUint32(p.X, LE))
+		require.NoError(t, enc.WriteFloat64(p.Y, LE))
+	}
+
+	for _, workers := range []int{1, 4} {
+		ch := make(chan channelPoint, len(in))
+		dec := NewBorshDecoder(buf.Bytes())
+		err := dec.DecodeToChannelN(ch, workers)
+		assert.True(t, errors.Is(err, ErrNaN), "workers: %d", workers)
+		assert.Contains(t, err.Error(), "element 30")
+		assert.Equal(t, 30, len(ch))
+		assert.Equal(t, 0, int(dec.Position()))
+	}
+
+	err := NewBorshDecoder(buf.Bytes()).DecodeToChannelN(make(<-chan channelPoint), 1)
+	assert.EqualError(t, err, "decode to channel: expected a send channel, got <-chan bin.channelPoint")
+
+	err = NewBorshDecoder(buf.Bytes()[:100]).DecodeToChannelN(make(chan channelPoint, len(in)), 4)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
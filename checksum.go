@@ -0,0 +1,124 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+)
+
+// ChecksumAlgo is a checksum algorithm, for ReadChecked.
+type ChecksumAlgo interface {
+	// Size returns the size in bytes of the encoded checksum: 2, 4 or 8.
+	Size() int
+	// Checksum returns the checksum of data.
+	Checksum(data []byte) uint64
+}
+
+var (
+	// CRC32 is the CRC-32 with the IEEE polynomial, as computed by hash/crc32.
+	CRC32 ChecksumAlgo = crc32Algo{}
+	// CRC16 is the CRC-16/CCITT-FALSE: polynomial 0x1021, initial value 0xffff.
+	CRC16 ChecksumAlgo = crc16Algo{}
+)
+
+type crc32Algo struct{}
+
+func (crc32Algo) Size() int { return TypeSize.Uint32 }
+
+func (crc32Algo) Checksum(data []byte) uint64 {
+	return uint64(crc32.ChecksumIEEE(data))
+}
+
+type crc16Algo struct{}
+
+func (crc16Algo) Size() int { return TypeSize.Uint16 }
+
+func (crc16Algo) Checksum(data []byte) uint64 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint64(crc)
+}
+
+// ReadChecked decodes v, then reads the checksum that follows it, and verifies it
+// against the checksum of the bytes consumed by decoding v.
+// The checksum is read with the byte order of the decoder.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadChecked(v interface{}, algo ChecksumAlgo) (err error) {
+	switch algo.Size() {
+	case TypeSize.Uint16, TypeSize.Uint32, TypeSize.Uint64:
+	default:
+		return fmt.Errorf("checksum: unsupported size %d", algo.Size())
+	}
+
+	start := dec.pos
+	defer func() {
+		if err != nil {
+			dec.pos = start
+		}
+	}()
+
+	if err = dec.Decode(v); err != nil {
+		return err
+	}
+	span := dec.data[start:dec.pos]
+
+	var expected uint64
+	switch algo.Size() {
+	case TypeSize.Uint16:
+		var n uint16
+		n, err = dec.ReadUint16(dec.order)
+		expected = uint64(n)
+	case TypeSize.Uint32:
+		var n uint32
+		n, err = dec.ReadUint32(dec.order)
+		expected = uint64(n)
+	case TypeSize.Uint64:
+		expected, err = dec.ReadUint64(dec.order)
+	}
+	if err != nil {
+		return fmt.Errorf("checksum: %w", err)
+	}
+
+	if actual := algo.Checksum(span); actual != expected {
+		return fmt.Errorf("checksum: mismatch over %d bytes: expected 0x%x, got 0x%x", len(span), expected, actual)
+	}
+	return nil
+}
+
+// DecodeAndHash decodes v, and returns the sum of h over the exact bytes consumed
+// by decoding it, e.g. to identify the decoded value by the hash of its serialized form.
+// h is reset first. On error, the position of the decoder is left unchanged.
+func (dec *Decoder) DecodeAndHash(v interface{}, h hash.Hash) ([]byte, error) {
+	start := dec.pos
+	if err := dec.Decode(v); err != nil {
+		dec.pos = start
+		return nil, err
+	}
+	h.Reset()
+	// hash.Hash.Write never returns an error.
+	h.Write(dec.data[start:dec.pos])
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumAlgos(t *testing.T) {
+	check := []byte("123456789")
+	assert.Equal(t, uint64(0xcbf43926), CRC32.Checksum(check))
+	assert.Equal(t, uint64(0x29b1), CRC16.Checksum(check))
+}
+
+func TestDecoder_ReadChecked(t *testing.T) {
+	type record struct {
+		A uint16
+		B string
+	}
+	in := record{A: 7, B: "hi"}
+
+	for _, algo := range []ChecksumAlgo{CRC16, CRC32} {
+		buf := new(bytes.Buffer)
+		enc := NewBorshEncoder(buf)
+		require.NoError(t, enc.Encode(in))
+		sum := algo.Checksum(buf.Bytes())
+		if algo.Size() == TypeSize.Uint16 {
+			require.NoError(t, enc.WriteUint16(uint16(sum), LE))
+		} else {
+			require.NoError(t, enc.WriteUint32(uint32(sum), LE))
+		}
+		require.NoError(t, enc.WriteByte(0xff))
+		data := buf.Bytes()
+
+		var out record
+		dec := NewBorshDecoder(data)
+		require.NoError(t, dec.ReadChecked(&out, algo))
+		assert.Equal(t, in, out)
+		assert.Equal(t, 1, dec.Remaining())
+
+		corrupted := append([]byte{}, data...)
+		corrupted[0] ^= 0x01
+		dec = NewBorshDecoder(corrupted)
+		err := dec.ReadChecked(&out, algo)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum: mismatch over 8 bytes")
+		assert.Equal(t, 0, int(dec.Position()))
+
+		dec = NewBorshDecoder(data[:8])
+		err = dec.ReadChecked(&out, algo)
+		assert.True(t, errors.Is(err, ErrShortBuffer))
+		assert.Equal(t, 0, int(dec.Position()))
+	}
+
+	// The algorithm is validated before anything is decoded.
+	var out record
+	dec := NewBorshDecoder([]byte{0x07, 0x00})
+	err := dec.ReadChecked(&out, crc24Algo{})
+	assert.EqualError(t, err, "checksum: unsupported size 3")
+	assert.Equal(t, record{}, out)
+	assert.Equal(t, 0, int(dec.Position()))
+}
+
+type crc24Algo struct{}
+
+func (crc24Algo) Size() int { return 3 }
+
+func (crc24Algo) Checksum(data []byte) uint64 { return 0 }
+
+func TestDecoder_DecodeAndHash(t *testing.T) {
+	type record struct {
+		ID   uint32
+		Name string
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).Encode(record{ID: 7, Name: "seven"}))
+	encoded := append([]byte(nil), buf.Bytes()...)
+	buf.WriteByte(0xff)
+
+	h := sha256.New()
+	h.Write([]byte("stale"))
+
+	var got record
+	dec := NewBorshDecoder(buf.Bytes())
+	sum, err := dec.DecodeAndHash(&got, h)
+	require.NoError(t, err)
+	assert.Equal(t, record{ID: 7, Name: "seven"}, got)
+	expected := sha256.Sum256(encoded)
+	assert.Equal(t, expected[:], sum)
+	assert.Equal(t, 1, dec.Remaining())
+
+	dec = NewBorshDecoder(encoded[:6])
+	_, err = dec.DecodeAndHash(&got, h)
+	assert.Error(t, err)
+	assert.Equal(t, 0, int(dec.Position()))
+}
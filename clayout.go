@@ -0,0 +1,140 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// hasCLayout returns true if one of the fields of the struct type
+// is tagged with `bin:"c_layout"`, usually a blank marker field:
+//
+//	type Header struct {
+//		_     struct{} `bin:"c_layout"`
+//		Flag  uint8
+//		Value uint32 // preceded by 3 bytes of padding
+//	}
+//
+// The fields of such a struct are aligned according to the C natural
+// alignment rules (like a `#[repr(C)]` Rust struct), relative to the start of the
+// struct, and the struct is padded to a multiple of its own alignment.
+// Nested structs are aligned as a whole, but their own fields are only
+// aligned if they are marked with c_layout too.
+func hasCLayout(rt reflect.Type) bool {
+	for i := 0; i < rt.NumField(); i++ {
+		if parseFieldTag(rt.Field(i).Tag).CLayout {
+			return true
+		}
+	}
+	return false
+}
+
+// cAlignOf returns the C natural alignment of the provided type.
+func cAlignOf(rt reflect.Type) (int, error) {
+	switch rt.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1, nil
+	case reflect.Int16, reflect.Uint16:
+		return 2, nil
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4, nil
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 8, nil
+	case reflect.Array:
+		return cAlignOf(rt.Elem())
+	case reflect.Struct:
+		align := 1
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if parseFieldTag(field.Tag).Skip {
+				continue
+			}
+			fieldAlign, err := cAlignOf(field.Type)
+			if err != nil {
+				return 0, err
+			}
+			if fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return align, nil
+	default:
+		return 0, fmt.Errorf("c_layout: type %s has no C layout", rt)
+	}
+}
+
+// cPaddingFor returns the number of padding bytes needed after offset
+// for a value of the provided type to be naturally aligned.
+func cPaddingFor(offset int, rt reflect.Type) (int, error) {
+	align, err := cAlignOf(rt)
+	if err != nil {
+		return 0, err
+	}
+	return (align - offset%align) % align, nil
+}
+
+// skipCPadding skips the padding preceding a value of the
+// provided type, given the start position of the enclosing struct.
+func (dec *Decoder) skipCPadding(start int, rt reflect.Type) error {
+	padding, err := cPaddingFor(dec.pos-start, rt)
+	if err != nil {
+		return err
+	}
+	if padding == 0 {
+		return nil
+	}
+	if traceEnabled {
+		zlog.Debug("decode: skipping c_layout padding", zap.Int("padding", padding))
+	}
+	return dec.SkipBytes(uint(padding))
+}
+
+// writeCPadding writes the zero padding preceding a value of the
+// provided type, given the start count of the enclosing struct.
+func (e *Encoder) writeCPadding(start int, rt reflect.Type) error {
+	padding, err := cPaddingFor(e.count-start, rt)
+	if err != nil {
+		return err
+	}
+	if padding == 0 {
+		return nil
+	}
+	if traceEnabled {
+		zlog.Debug("encode: writing c_layout padding", zap.Int("padding", padding))
+	}
+	return e.toWriter(make([]byte, padding))
+}
+
+// ReadAlignedStruct decodes into v, a pointer to a struct, as if the
+// struct and all the structs nested in it were tagged with `bin:"c_layout"`.
+// This is meant for data copied verbatim from the memory of a C struct,
+// or of a `#[repr(C)]` Rust struct.
+func (dec *Decoder) ReadAlignedStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("c_layout: expected a non-nil pointer to a struct, got %T", v)
+	}
+	if _, err := cAlignOf(rv.Elem().Type()); err != nil {
+		return err
+	}
+
+	previous := dec.cLayout
+	dec.cLayout = true
+	defer func() { dec.cLayout = previous }()
+	return dec.Decode(v)
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cLayoutInner struct {
+	A uint8
+	B uint16
+}
+
+type cLayoutStruct struct {
+	_     struct{} `bin:"c_layout"`
+	Flag  uint8
+	Value uint32
+	Short uint16
+	Inner cLayoutInner
+	Big   uint64
+	Tail  uint8
+}
+
+func TestCLayout(t *testing.T) {
+	data := []byte{
+		0x01, 0x00, 0x00, 0x00, // Flag + padding
+		0x02, 0x00, 0x00, 0x00, // Value
+		0x03, 0x00, // Short
+		0x04, 0x05, 0x00, // Inner (unaligned fields, no c_layout)
+		0x00, 0x00, 0x00, // padding
+		0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Big
+		0x07,                                     // Tail
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // trailing padding
+	}
+	expected := cLayoutStruct{Flag: 1, Value: 2, Short: 3, Inner: cLayoutInner{A: 4, B: 5}, Big: 6, Tail: 7}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var got cLayoutStruct
+			dec := NewDecoderWithEncoding(data, encoding)
+			require.NoError(t, dec.Decode(&got))
+			assert.Equal(t, expected, got)
+			assert.Equal(t, 0, dec.Remaining())
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(expected))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+}
+
+func TestDecoder_ReadAlignedStruct(t *testing.T) {
+	type inner struct {
+		A uint8
+		B uint16
+	}
+	type header struct {
+		Flag  uint8
+		Inner inner
+		Value uint32
+	}
+
+	data := []byte{
+		0x01, 0x00, // Flag + padding
+		0x02, 0x00, 0x03, 0x00, // Inner (aligned)
+		0x00, 0x00, // padding
+		0x04, 0x00, 0x00, 0x00, // Value
+	}
+
+	var got header
+	dec := NewBinDecoder(data)
+	require.NoError(t, dec.ReadAlignedStruct(&got))
+	assert.Equal(t, header{Flag: 1, Inner: inner{A: 2, B: 3}, Value: 4}, got)
+	assert.Equal(t, 0, dec.Remaining())
+	assert.False(t, dec.cLayout)
+
+	err := NewBinDecoder(data).ReadAlignedStruct(&struct{ S string }{})
+	assert.EqualError(t, err, "c_layout: type string has no C layout")
+
+	err = NewBinDecoder(data).ReadAlignedStruct(got)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// FieldDecodeFunc decodes a struct field from the decoder into rv.
+// The provided rv is settable.
+type FieldDecodeFunc func(dec *Decoder, rv reflect.Value) error
+
+// FieldEncodeFunc encodes the struct field rv using the encoder.
+type FieldEncodeFunc func(enc *Encoder, rv reflect.Value) error
+
+type fieldCodec struct {
+	decode FieldDecodeFunc
+	encode FieldEncodeFunc
+}
+
+var (
+	fieldCodecsMu sync.RWMutex
+	fieldCodecs   = map[string]fieldCodec{}
+)
+
+// RegisterFieldCodec registers a custom codec under the provided name.
+// Struct fields tagged with `bin:"codec=<name>"` are then decoded and encoded
+// through the provided functions instead of the default reflection-based logic.
+//
+// This is useful for types you don't own and thus cannot implement
+// BinaryUnmarshaler/BinaryMarshaler on.
+// RegisterFieldCodec panics if the name is empty, if any of the functions
+// is nil, or if a codec with the same name is already registered.
+func RegisterFieldCodec(name string, decode FieldDecodeFunc, encode FieldEncodeFunc) {
+	if name == "" {
+		panic("field codec name cannot be empty")
+	}
+	if decode == nil || encode == nil {
+		panic(fmt.Sprintf("field codec %q must have both a decode and an encode func", name))
+	}
+
+	fieldCodecsMu.Lock()
+	defer fieldCodecsMu.Unlock()
+
+	if _, found := fieldCodecs[name]; found {
+		panic(fmt.Sprintf("field codec %q is already registered", name))
+	}
+	fieldCodecs[name] = fieldCodec{
+		decode: decode,
+		encode: encode,
+	}
+}
+
+func getFieldCodec(name string) (fieldCodec, error) {
+	fieldCodecsMu.RLock()
+	defer fieldCodecsMu.RUnlock()
+
+	codec, found := fieldCodecs[name]
+	if !found {
+		return fieldCodec{}, fmt.Errorf("no field codec registered with name %q", name)
+	}
+	return codec, nil
+}
+
+func (dec *Decoder) decodeWithFieldCodec(name string, rv reflect.Value) error {
+	codec, err := getFieldCodec(name)
+	if err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: using field codec", zap.String("codec", name))
+	}
+	return codec.decode(dec, rv)
+}
+
+func (e *Encoder) encodeWithFieldCodec(name string, rv reflect.Value) error {
+	codec, err := getFieldCodec(name)
+	if err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("encode: using field codec", zap.String("codec", name))
+	}
+	return codec.encode(e, rv)
+}
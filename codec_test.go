@@ -0,0 +1,141 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Encodes a big.Int as a u8 length followed by the big-endian magnitude.
+	RegisterFieldCodec(
+		"test_bigint",
+		func(dec *Decoder, rv reflect.Value) error {
+			l, err := dec.ReadUint8()
+			if err != nil {
+				return err
+			}
+			data, err := dec.ReadNBytes(int(l))
+			if err != nil {
+				return err
+			}
+			rv.Set(reflect.ValueOf(new(big.Int).SetBytes(data)))
+			return nil
+		},
+		func(enc *Encoder, rv reflect.Value) error {
+			data := rv.Interface().(*big.Int).Bytes()
+			if err := enc.WriteUint8(uint8(len(data))); err != nil {
+				return err
+			}
+			return enc.WriteBytes(data, false)
+		},
+	)
+	// Encodes a uint8 as its bitwise complement.
+	RegisterFieldCodec(
+		"test_not8",
+		func(dec *Decoder, rv reflect.Value) error {
+			n, err := dec.ReadUint8()
+			if err != nil {
+				return err
+			}
+			rv.SetUint(uint64(^n))
+			return nil
+		},
+		func(enc *Encoder, rv reflect.Value) error {
+			return enc.WriteUint8(^uint8(rv.Uint()))
+		},
+	)
+}
+
+type fieldCodecTestStruct struct {
+	A uint16
+	B *big.Int `bin:"codec=test_bigint"`
+	C uint8
+}
+
+func TestFieldCodec(t *testing.T) {
+	in := fieldCodecTestStruct{
+		A: 7,
+		B: big.NewInt(0x010203),
+		C: 9,
+	}
+	expected := []byte{
+		0x07, 0x00,
+		0x03, 0x01, 0x02, 0x03,
+		0x09,
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(WriteByWrite)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out fieldCodecTestStruct
+			require.NoError(t, NewDecoderWithEncoding(expected, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+}
+
+func TestFieldCodec_SizeOf(t *testing.T) {
+	type sized struct {
+		Len   uint8 `bin:"sizeof=Items codec=test_not8"`
+		Items []uint16
+		C     uint8
+	}
+	in := sized{Len: 2, Items: []uint16{0x0102, 0x0304}, C: 9}
+	expected := []byte{
+		0xfd,
+		0x02, 0x01, 0x04, 0x03,
+		0x09,
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(WriteByWrite)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out sized
+			require.NoError(t, NewDecoderWithEncoding(expected, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+}
+
+func TestFieldCodec_Unknown(t *testing.T) {
+	type unknownCodec struct {
+		A uint8 `bin:"codec=does_not_exist"`
+	}
+
+	var out unknownCodec
+	err := NewBinDecoder([]byte{0x01}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "A" field: no field codec registered with name "does_not_exist"`)
+
+	_, err = MarshalBin(unknownCodec{A: 1})
+	assert.EqualError(t, err, `error while encoding "A" field: no field codec registered with name "does_not_exist"`)
+}
+
+func TestRegisterFieldCodec_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterFieldCodec("test_bigint", func(*Decoder, reflect.Value) error { return nil }, func(*Encoder, reflect.Value) error { return nil })
+	})
+}
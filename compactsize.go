@@ -0,0 +1,106 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// The prefixes of the CompactSize values of Bitcoin that don't fit in a single byte.
+const (
+	compactSizeU16 = 0xfd
+	compactSizeU32 = 0xfe
+	compactSizeU64 = 0xff
+)
+
+// SetStrictCompactSize makes ReadCompactSize (and so the lengths of EncodingBitcoin)
+// reject the values that are not minimally encoded, like Bitcoin Core does,
+// with an error wrapping ErrNonCanonical.
+func (dec *Decoder) SetStrictCompactSize(strict bool) {
+	dec.strictCompactSize = strict
+}
+
+// ReadCompactSize reads a CompactSize, the variable-length integer of Bitcoin:
+// values below 0xfd take a single byte, and larger ones a 0xfd, 0xfe or 0xff prefix
+// followed by the value as a little endian uint16, uint32 or uint64.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadCompactSize() (out uint64, err error) {
+	start := dec.pos
+	defer func() {
+		if err != nil {
+			dec.pos = start
+		}
+	}()
+
+	prefix, err := dec.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("compact size: %w", err)
+	}
+	var min uint64
+	switch prefix {
+	case compactSizeU16:
+		var n uint16
+		n, err = dec.ReadUint16(binary.LittleEndian)
+		out, min = uint64(n), compactSizeU16
+	case compactSizeU32:
+		var n uint32
+		n, err = dec.ReadUint32(binary.LittleEndian)
+		out, min = uint64(n), 0x10000
+	case compactSizeU64:
+		out, err = dec.ReadUint64(binary.LittleEndian)
+		min = 0x100000000
+	default:
+		out = uint64(prefix)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("compact size: %w", err)
+	}
+	if dec.strictCompactSize && out < min {
+		return 0, fmt.Errorf("%w: compact size %d is not minimally encoded", ErrNonCanonical, out)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read compact size", zap.Uint64("val", out))
+	}
+	return out, nil
+}
+
+// WriteCompactSize writes a CompactSize, the variable-length integer of Bitcoin,
+// minimally encoded. See Decoder.ReadCompactSize.
+func (e *Encoder) WriteCompactSize(v uint64) error {
+	if traceEnabled {
+		zlog.Debug("encode: write compact size", zap.Uint64("val", v))
+	}
+	var buf [9]byte
+	switch {
+	case v < compactSizeU16:
+		buf[0] = byte(v)
+		return e.toWriter(buf[:1])
+	case v <= 0xffff:
+		buf[0] = compactSizeU16
+		binary.LittleEndian.PutUint16(buf[1:], uint16(v))
+		return e.toWriter(buf[:3])
+	case v <= 0xffffffff:
+		buf[0] = compactSizeU32
+		binary.LittleEndian.PutUint32(buf[1:], uint32(v))
+		return e.toWriter(buf[:5])
+	default:
+		buf[0] = compactSizeU64
+		binary.LittleEndian.PutUint64(buf[1:], v)
+		return e.toWriter(buf[:])
+	}
+}
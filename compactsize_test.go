@@ -0,0 +1,107 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactSize(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{0xfc, []byte{0xfc}},
+		{0xfd, []byte{0xfd, 0xfd, 0x00}},
+		{0xffff, []byte{0xfd, 0xff, 0xff}},
+		{0x10000, []byte{0xfe, 0x00, 0x00, 0x01, 0x00}},
+		{0x100000000, []byte{0xff, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}},
+		{math.MaxUint64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBitcoinEncoder(buf).WriteCompactSize(test.value))
+		assert.Equal(t, test.encoded, buf.Bytes())
+
+		dec := NewBitcoinDecoder(test.encoded)
+		dec.SetStrictCompactSize(true)
+		out, err := dec.ReadCompactSize()
+		require.NoError(t, err)
+		assert.Equal(t, test.value, out)
+		assert.False(t, dec.HasRemaining())
+	}
+
+	nonMinimal := []byte{0xfd, 0x01, 0x00}
+	out, err := NewBitcoinDecoder(nonMinimal).ReadCompactSize()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), out)
+
+	dec := NewBitcoinDecoder(nonMinimal)
+	dec.SetStrictCompactSize(true)
+	_, err = dec.ReadCompactSize()
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+	assert.Equal(t, 0, int(dec.Position()))
+
+	dec = NewBitcoinDecoder([]byte{0xfe, 0x01})
+	_, err = dec.ReadCompactSize()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 0, int(dec.Position()))
+}
+
+func TestEncodingBitcoin(t *testing.T) {
+	type outPoint struct {
+		Hash  [32]byte
+		Index uint32
+	}
+	type tx struct {
+		Version int32
+		Inputs  []outPoint
+		Script  []byte
+		Memo    string
+	}
+
+	in := tx{
+		Version: 2,
+		Inputs:  []outPoint{{Index: 1}},
+		Script:  bytes.Repeat([]byte{0x51}, 300),
+		Memo:    "hi",
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBitcoinEncoder(buf).Encode(in))
+
+	expected := []byte{0x02, 0x00, 0x00, 0x00, 0x01}
+	expected = append(expected, make([]byte, 32)...)
+	expected = append(expected, 0x01, 0x00, 0x00, 0x00)
+	expected = append(expected, 0xfd, 0x2c, 0x01)
+	expected = append(expected, in.Script...)
+	expected = append(expected, 0x02, 'h', 'i')
+	assert.Equal(t, expected, buf.Bytes())
+
+	var out tx
+	dec := NewBitcoinDecoder(buf.Bytes())
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, in, out)
+	assert.False(t, dec.HasRemaining())
+
+	assert.Equal(t, "Bitcoin", EncodingBitcoin.String())
+	assert.Equal(t, "CompactSize", LengthEncodingCompactSize.String())
+}
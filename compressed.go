@@ -0,0 +1,115 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// CompressionAlgo is the compression algorithm of a payload read by ReadCompressed.
+type CompressionAlgo int
+
+const (
+	// CompressionFlate is raw DEFLATE (RFC 1951), without a zlib or gzip header.
+	CompressionFlate CompressionAlgo = iota
+	// CompressionZstd is Zstandard; it's only available when built with the zstd tag.
+	CompressionZstd
+)
+
+func (algo CompressionAlgo) String() string {
+	switch algo {
+	case CompressionFlate:
+		return "flate"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+var (
+	decompressorsMu sync.RWMutex
+	// decompressors returns a reader of the decompressed
+	// bytes of a reader, for each algorithm.
+	decompressors = map[CompressionAlgo]func(r io.Reader) (io.ReadCloser, error){
+		CompressionFlate: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+	}
+)
+
+func registerDecompressor(algo CompressionAlgo, fn func(r io.Reader) (io.ReadCloser, error)) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[algo] = fn
+}
+
+// ReadCompressed reads a compressed payload framed as its uncompressed length,
+// as a u32 in the byte order of the decoder, followed by the compressed bytes,
+// which extend to the end of the decoder (or of the frame of a sub-decoder).
+// It returns a decoder of the decompressed bytes, with the same settings as dec,
+// after verifying that their length matches the declared one.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadCompressed(algo CompressionAlgo) (*Decoder, error) {
+	decompressorsMu.RLock()
+	decompressor, found := decompressors[algo]
+	decompressorsMu.RUnlock()
+	if !found {
+		if algo == CompressionZstd {
+			return nil, fmt.Errorf("compressed: zstd is not supported, build with the zstd tag")
+		}
+		return nil, fmt.Errorf("compressed: unknown compression algorithm %d", algo)
+	}
+
+	start := dec.pos
+	declared, err := dec.ReadUint32(dec.order)
+	if err != nil {
+		return nil, fmt.Errorf("compressed: uncompressed length: %w", err)
+	}
+	compressed := dec.data[dec.pos:]
+
+	rc, err := decompressor(bytes.NewReader(compressed))
+	if err != nil {
+		dec.pos = start
+		return nil, fmt.Errorf("compressed: %s: %w", algo, err)
+	}
+	defer rc.Close()
+	// Read one byte more than declared to detect a longer payload,
+	// without decompressing more than that.
+	out, err := ioutil.ReadAll(io.LimitReader(rc, int64(declared)+1))
+	if err != nil {
+		dec.pos = start
+		return nil, fmt.Errorf("compressed: %s: %w", algo, err)
+	}
+	if len(out) != int(declared) {
+		dec.pos = start
+		if len(out) > int(declared) {
+			return nil, errorf(ErrInvalidLength, "compressed: decompressed payload is longer than the declared %d bytes", declared)
+		}
+		return nil, errorf(ErrInvalidLength, "compressed: decompressed %d bytes, declared %d", len(out), declared)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read compressed", zap.Stringer("algo", algo), zap.Int("compressed", len(compressed)), zap.Int("len", len(out)))
+	}
+	dec.pos = len(dec.data)
+	return dec.subDecoder(out), nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deflate(t *testing.T, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	w, err := flate.NewWriter(buf, flate.BestCompression)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecoder_ReadCompressed(t *testing.T) {
+	type message struct {
+		ID   uint32
+		Body string
+	}
+	in := message{ID: 7, Body: "hello hello hello hello"}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			payload := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(payload, encoding).Encode(in))
+
+			buf := new(bytes.Buffer)
+			enc := NewEncoderWithEncoding(buf, encoding)
+			require.NoError(t, enc.WriteUint32(uint32(payload.Len()), LE))
+			require.NoError(t, enc.WriteBytes(deflate(t, payload.Bytes()), false))
+
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			inner, err := dec.ReadCompressed(CompressionFlate)
+			require.NoError(t, err)
+			assert.Equal(t, 0, dec.Remaining())
+			assert.Equal(t, encoding, inner.Encoding())
+
+			var out message
+			require.NoError(t, inner.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, inner.Remaining())
+		})
+	}
+}
+
+func TestDecoder_ReadCompressed_Errors(t *testing.T) {
+	compressed := deflate(t, []byte("abcdef"))
+
+	for _, declared := range []uint32{5, 7} {
+		data := append([]byte{byte(declared), 0x00, 0x00, 0x00}, compressed...)
+		dec := NewBinDecoder(data)
+		_, err := dec.ReadCompressed(CompressionFlate)
+		assert.True(t, errors.Is(err, ErrInvalidLength), "declared %d", declared)
+		assert.Equal(t, 0, int(dec.Position()))
+	}
+	_, err := NewBinDecoder(append([]byte{0x05, 0x00, 0x00, 0x00}, compressed...)).ReadCompressed(CompressionFlate)
+	assert.EqualError(t, err, "compressed: decompressed payload is longer than the declared 5 bytes")
+
+	dec := NewBinDecoder([]byte{0x06, 0x00, 0x00, 0x00, 0xff, 0xff})
+	_, err = dec.ReadCompressed(CompressionFlate)
+	assert.Error(t, err)
+	assert.Equal(t, 0, int(dec.Position()))
+
+	_, err = NewBinDecoder([]byte{0x06, 0x00}).ReadCompressed(CompressionFlate)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	_, err = NewBinDecoder(nil).ReadCompressed(CompressionAlgo(9))
+	assert.EqualError(t, err, "compressed: unknown compression algorithm 9")
+}
+
+func TestDecoder_ReadCompressed_Zstd(t *testing.T) {
+	decompressorsMu.RLock()
+	_, found := decompressors[CompressionZstd]
+	decompressorsMu.RUnlock()
+	if found {
+		t.Skip("built with the zstd tag")
+	}
+	_, err := NewBinDecoder([]byte{0x00, 0x00, 0x00, 0x00}).ReadCompressed(CompressionZstd)
+	assert.EqualError(t, err, "compressed: zstd is not supported, build with the zstd tag")
+}
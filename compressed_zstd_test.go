@@ -0,0 +1,53 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build zstd
+// +build zstd
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_ReadCompressed_ZstdFrame(t *testing.T) {
+	w, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	payload := bytes.Repeat([]byte("zstd "), 20)
+	compressed := w.EncodeAll(payload, nil)
+	require.NoError(t, w.Close())
+
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUint32(uint32(len(payload)), LE))
+	require.NoError(t, enc.WriteBytes(compressed, false))
+
+	dec := NewBinDecoder(buf.Bytes())
+	inner, err := dec.ReadCompressed(CompressionZstd)
+	require.NoError(t, err)
+	assert.Equal(t, 0, dec.Remaining())
+	out, err := inner.ReadNBytes(inner.Remaining())
+	require.NoError(t, err)
+	assert.Equal(t, payload, out)
+
+	data := append([]byte{byte(len(payload) - 1), 0x00, 0x00, 0x00}, compressed...)
+	_, err = NewBinDecoder(data).ReadCompressed(CompressionZstd)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+}
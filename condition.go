@@ -0,0 +1,154 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fieldCondition is the parsed expression of a `bin:"if=..."` tag:
+// the field is present if the Field value, masked by Mask,
+// is non-zero, or (if Op is set) compares to Value with Op.
+//
+//	if=Flags&0x01      Flags&0x01 != 0
+//	if=Version==2      Version == 2
+//	if=Kind&0x0f!=3    Kind&0x0f != 3
+type fieldCondition struct {
+	Field string
+	Mask  uint64
+	Op    string
+	Value uint64
+
+	// index is the index of Field in the struct.
+	index int
+}
+
+// fieldConditions caches the conditions of the fields of struct types, by type and field index.
+var fieldConditions sync.Map
+
+type fieldConditionKey struct {
+	rt    reflect.Type
+	index int
+}
+
+type fieldConditionEntry struct {
+	cond *fieldCondition
+	err  error
+}
+
+func parseFieldCondition(expr string) (*fieldCondition, error) {
+	cond := &fieldCondition{Mask: ^uint64(0)}
+	for _, op := range []string{"==", "!="} {
+		if idx := strings.Index(expr, op); idx >= 0 {
+			value, err := strconv.ParseUint(expr[idx+len(op):], 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("if: invalid value in %q", expr)
+			}
+			cond.Op, cond.Value = op, value
+			expr = expr[:idx]
+			break
+		}
+	}
+	if idx := strings.Index(expr, "&"); idx >= 0 {
+		mask, err := strconv.ParseUint(expr[idx+1:], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("if: invalid mask in %q", expr)
+		}
+		cond.Mask = mask
+		expr = expr[:idx]
+	}
+	if expr == "" {
+		return nil, fmt.Errorf("if: missing field name")
+	}
+	cond.Field = expr
+	return cond, nil
+}
+
+// fieldConditionOf returns the condition of the field at index fieldIndex of the
+// struct type rt, with the `bin:"if=..."` expression. The field the expression refers to
+// must be an integer or a bool that precedes the conditional field on the wire.
+func fieldConditionOf(rt reflect.Type, fieldIndex int, expr string) (*fieldCondition, error) {
+	key := fieldConditionKey{rt: rt, index: fieldIndex}
+	if cached, ok := fieldConditions.Load(key); ok {
+		entry := cached.(*fieldConditionEntry)
+		return entry.cond, entry.err
+	}
+	cond, err := newFieldCondition(rt, fieldIndex, expr)
+	fieldConditions.Store(key, &fieldConditionEntry{cond: cond, err: err})
+	return cond, err
+}
+
+func newFieldCondition(rt reflect.Type, fieldIndex int, expr string) (*fieldCondition, error) {
+	cond, err := parseFieldCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	field, ok := rt.FieldByName(cond.Field)
+	if !ok || len(field.Index) != 1 {
+		return nil, fmt.Errorf("if: no field %q in %s", cond.Field, rt)
+	}
+	cond.index = field.Index[0]
+	switch field.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Bool:
+	default:
+		return nil, fmt.Errorf("if: field %q must be an integer or a bool, got %s", cond.Field, field.Type)
+	}
+
+	precedes, err := fieldPrecedes(rt, cond.index, fieldIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !precedes {
+		return nil, fmt.Errorf("if: %q is not a field preceding %q", cond.Field, rt.Field(fieldIndex).Name)
+	}
+	return cond, nil
+}
+
+// evalFieldCondition reports whether the field at index fieldIndex of the struct rv,
+// with the `bin:"if=..."` expression, is present: see fieldConditionOf.
+func evalFieldCondition(rv reflect.Value, fieldIndex int, expr string) (bool, error) {
+	cond, err := fieldConditionOf(rv.Type(), fieldIndex, expr)
+	if err != nil {
+		return false, err
+	}
+	v := rv.Field(cond.index)
+	var value uint64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Only keep the bits of the width of the field.
+		value = uint64(v.Int()) & (1<<uint(v.Type().Bits()) - 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = v.Uint()
+	case reflect.Bool:
+		if v.Bool() {
+			value = 1
+		}
+	}
+	value &= cond.Mask
+	switch cond.Op {
+	case "==":
+		return value == cond.Value, nil
+	case "!=":
+		return value != cond.Value, nil
+	default:
+		return value != 0, nil
+	}
+}
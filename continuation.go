@@ -0,0 +1,132 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// continuationBit is the bit that is set when another element follows,
+// in the slices tagged with `bin:"continuation=..."`.
+const continuationBit = 0x80
+
+// The positions of the continuation bit of a `bin:"continuation=..."` slice.
+const (
+	// continuationFirstByte is the high bit of the first byte of each element,
+	// which is part of the element, like in a varint.
+	continuationFirstByte = "first_byte"
+	// continuationPrefixByte is a separate byte before each element,
+	// 0x80 if another element follows, or 0x00 for the last one.
+	continuationPrefixByte = "prefix_byte"
+)
+
+func checkContinuation(rv reflect.Value, mode string) error {
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("continuation: expected a slice field, got %s", rv.Type())
+	}
+	if mode != continuationFirstByte && mode != continuationPrefixByte {
+		return fmt.Errorf("continuation: unknown position %q, expected %q or %q", mode, continuationFirstByte, continuationPrefixByte)
+	}
+	return nil
+}
+
+// decodeContinuation reads a `bin:"continuation=..."` slice, which has no length
+// prefix: elements are read until one whose continuation bit is not set.
+// The slice has at least one element.
+func (dec *Decoder) decodeContinuation(rv reflect.Value, mode string, opt *option) error {
+	if err := checkContinuation(rv, mode); err != nil {
+		return err
+	}
+	out := reflect.Zero(rv.Type())
+	for more := true; more; {
+		if mode == continuationPrefixByte {
+			flag, err := dec.ReadByte()
+			if err != nil {
+				return fmt.Errorf("continuation: element %d: %w", out.Len(), err)
+			}
+			if flag&^continuationBit != 0 {
+				return fmt.Errorf("continuation: element %d: invalid continuation byte 0x%02x", out.Len(), flag)
+			}
+			more = flag == continuationBit
+		} else {
+			if !dec.HasRemaining() {
+				return fmt.Errorf("continuation: element %d: %w", out.Len(), errorf(ErrShortBuffer, "required [1] byte, remaining [0]"))
+			}
+			more = dec.data[dec.pos]&continuationBit != 0
+		}
+		elem := reflect.New(rv.Type().Elem())
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return fmt.Errorf("continuation: element %d: %w", out.Len(), err)
+		}
+		out = reflect.Append(out, elem.Elem())
+		if err := opt.checkMaxLen(out.Len()); err != nil {
+			return err
+		}
+		dec.reportProgress()
+	}
+	if err := opt.checkLen(out.Len()); err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read continuation slice", zap.String("mode", mode), zap.Int("len", out.Len()))
+	}
+	rv.Set(out)
+	return nil
+}
+
+// encodeContinuation writes a `bin:"continuation=..."` slice. With the first_byte
+// position, the elements must already have their continuation bit set as expected
+// (set on all the elements but the last), since it's part of their value.
+func (e *Encoder) encodeContinuation(rv reflect.Value, mode string) error {
+	if err := checkContinuation(rv, mode); err != nil {
+		return err
+	}
+	if rv.Len() == 0 {
+		return fmt.Errorf("continuation: cannot encode an empty slice, which has no last element")
+	}
+	for i := 0; i < rv.Len(); i++ {
+		more := i < rv.Len()-1
+		if mode == continuationPrefixByte {
+			flag := byte(0)
+			if more {
+				flag = continuationBit
+			}
+			if err := e.WriteByte(flag); err != nil {
+				return err
+			}
+			if err := e.Encode(rv.Index(i).Interface()); err != nil {
+				return fmt.Errorf("continuation: element %d: %w", i, err)
+			}
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+		elemEncoder := e.subEncoder(buf)
+		if err := elemEncoder.Encode(rv.Index(i).Interface()); err != nil {
+			return fmt.Errorf("continuation: element %d: %w", i, err)
+		}
+		if buf.Len() == 0 || (buf.Bytes()[0]&continuationBit != 0) != more {
+			return fmt.Errorf("continuation: element %d of %d doesn't have the expected continuation bit in its first byte", i, rv.Len())
+		}
+		if err := e.toWriter(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
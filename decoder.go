@@ -18,9 +18,10 @@
 package bin
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -69,9 +70,14 @@ var TypeSize = struct {
 
 	Float32: 4,
 	Float64: 8,
+
+	CurrencyName: 7,
 }
 
 // Decoder implements the EOS unpacking, similar to FC_BUFFER
+//
+// A Decoder is not safe for concurrent use; build with the bindebug tag
+// to panic on concurrent Decode calls on the same decoder.
 type Decoder struct {
 	data []byte
 	pos  int
@@ -79,6 +85,120 @@ type Decoder struct {
 	currentFieldOpt *option
 
 	encoding Encoding
+	// order is the default byte order for fixed-width
+	// numbers of fields that don't specify one.
+	order binary.ByteOrder
+	// cLayout forces the C layout on all the decoded structs;
+	// see ReadAlignedStruct.
+	cLayout bool
+
+	onProgress func(pos, total int)
+
+	// lengthEncoding overrides the length encoding
+	// of the encoding; see SetLengthEncoding.
+	lengthEncoding LengthEncoding
+
+	// floatPolicy overrides the default float validity
+	// rules of the encoding; see SetFloatPolicy.
+	floatPolicy *FloatPolicy
+
+	guard decoderGuard
+
+	// maxStringLen is the maximum length of the decoded
+	// strings, or 0 if unlimited; see SetMaxStringLen.
+	maxStringLen int
+
+	// namedInterfaces enables decoding interface values
+	// by type name; see SetNamedInterfaces.
+	namedInterfaces bool
+
+	// allocator provides the memory of the decoded
+	// byte slices; see SetAllocator.
+	allocator Allocator
+
+	// strictCompactSize rejects the CompactSize values that are not
+	// minimally encoded; see SetStrictCompactSize.
+	strictCompactSize bool
+
+	// fieldHook is called before decoding each struct field; see SetFieldHook.
+	fieldHook func(path []string, rt reflect.Type, startPos int)
+
+	// enumNameNormalizer normalizes the decoded names of types and string
+	// discriminants before their lookup; see WithEnumNameNormalizer.
+	enumNameNormalizer func(string) string
+
+	// strictMapOrder rejects the maps whose keys are not
+	// strictly increasing; see WithStrictMapOrder.
+	strictMapOrder bool
+
+	// lenientTrailingOptional decodes the optional values whose presence flag
+	// is missing at the end of the data as absent; see WithLenientTrailingOptional.
+	lenientTrailingOptional bool
+
+	// spans records the ranges of the decoded fields; see DecodeWithSpans.
+	spans *spanRecorder
+
+	// path holds the names of the struct fields being decoded, outermost first.
+	path []string
+}
+
+// FloatPolicy controls which float values are accepted when decoding.
+type FloatPolicy struct {
+	RejectNaN bool
+	RejectInf bool
+}
+
+// SetFloatPolicy sets the float values rejected by the decoder,
+// replacing the default rules of its encoding: Borsh rejects NaN,
+// and the other encodings accept any float.
+func (dec *Decoder) SetFloatPolicy(policy FloatPolicy) {
+	dec.floatPolicy = &policy
+}
+
+// checkFloat returns an error if the decoded float is rejected by the float policy.
+func (dec *Decoder) checkFloat(f float64) error {
+	policy := FloatPolicy{RejectNaN: dec.IsBorsh()}
+	if dec.floatPolicy != nil {
+		policy = *dec.floatPolicy
+	}
+	if policy.RejectNaN && math.IsNaN(f) {
+		return errorf(ErrNaN, "NaN for float not allowed")
+	}
+	if policy.RejectInf && math.IsInf(f, 0) {
+		return errorf(ErrInf, "Inf for float not allowed")
+	}
+	return nil
+}
+
+// FieldPath returns the names of the nested struct fields being decoded,
+// outermost first, so that custom unmarshalers can report where they are.
+func (dec *Decoder) FieldPath() []string {
+	return append([]string(nil), dec.path...)
+}
+
+// SetFieldHook sets a function called before each struct field is decoded, with the
+// path of the field (see FieldPath), its type, and the position of its first byte
+// (after any C layout padding), e.g. to check field offsets against an expected
+// layout, or to record which fields were reached.
+// Fields that are not decoded (skipped, unexported, or past a field count) are not reported.
+// Setting a hook disables the fast path of flat structs; the default is no hook.
+func (dec *Decoder) SetFieldHook(hook func(path []string, rt reflect.Type, startPos int)) {
+	dec.fieldHook = hook
+}
+
+// Encoding returns the encoding of the decoder.
+func (dec *Decoder) Encoding() Encoding {
+	return dec.encoding
+}
+
+// CurrentOption returns the options of the value being decoded,
+// so that custom unmarshalers can adapt to the tags of their field
+// (e.g. use the inherited byte order).
+func (dec *Decoder) CurrentOption() Option {
+	if dec.currentFieldOpt == nil {
+		return newDefaultOption().setOrder(dec.order).view()
+	}
+	return dec.currentFieldOpt.view()
 }
 
 func (dec *Decoder) IsBorsh() bool {
@@ -93,6 +213,10 @@ func (dec *Decoder) IsCompactU16() bool {
 	return dec.encoding.IsCompactU16()
 }
 
+func (dec *Decoder) IsBitcoin() bool {
+	return dec.encoding.IsBitcoin()
+}
+
 func NewDecoderWithEncoding(data []byte, enc Encoding) *Decoder {
 	if !isValidEncoding(enc) {
 		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
@@ -100,6 +224,7 @@ func NewDecoderWithEncoding(data []byte, enc Encoding) *Decoder {
 	return &Decoder{
 		data:     data,
 		encoding: enc,
+		order:    defaultByteOrder,
 	}
 }
 
@@ -107,6 +232,14 @@ func NewBinDecoder(data []byte) *Decoder {
 	return NewDecoderWithEncoding(data, EncodingBin)
 }
 
+// NewBinDecoderWithOrder creates a Bin decoder that reads fixed-width
+// numbers with the provided byte order, unless a field tag says otherwise.
+func NewBinDecoderWithOrder(data []byte, order binary.ByteOrder) *Decoder {
+	dec := NewBinDecoder(data)
+	dec.order = order
+	return dec
+}
+
 func NewBorshDecoder(data []byte) *Decoder {
 	return NewDecoderWithEncoding(data, EncodingBorsh)
 }
@@ -115,9 +248,55 @@ func NewCompactU16Decoder(data []byte) *Decoder {
 	return NewDecoderWithEncoding(data, EncodingCompactU16)
 }
 
+func NewBitcoinDecoder(data []byte) *Decoder {
+	return NewDecoderWithEncoding(data, EncodingBitcoin)
+}
+
+// Reset returns the decoder to the state it had once built by its constructor,
+// so that a pooled decoder doesn't leak its configuration between uses:
+//   - preserved: the data, the encoding and the default byte order (e.g. the one
+//     provided to NewBinDecoderWithOrder);
+//   - reset: the position (to 0), and the settings of ReadAlignedStruct,
+//     WithProgressCallback, SetLengthEncoding, SetFloatPolicy, SetMaxStringLen,
+//     SetNamedInterfaces, SetAllocator, SetFieldHook, SetStrictCompactSize,
+//     WithEnumNameNormalizer, WithStrictMapOrder and WithLenientTrailingOptional.
+func (dec *Decoder) Reset() {
+	dec.pos = 0
+	dec.currentFieldOpt = nil
+	dec.cLayout = false
+	dec.onProgress = nil
+	dec.lengthEncoding = LengthEncodingDefault
+	dec.floatPolicy = nil
+	dec.maxStringLen = 0
+	dec.namedInterfaces = false
+	dec.allocator = nil
+	dec.fieldHook = nil
+	dec.strictCompactSize = false
+	dec.enumNameNormalizer = nil
+	dec.strictMapOrder = false
+	dec.lenientTrailingOptional = false
+	dec.path = dec.path[:0]
+}
+
 func (dec *Decoder) Decode(v interface{}) (err error) {
+	dec.guard.enter()
+	defer dec.guard.exit()
+
+	if unmarshaler, ok := v.(BinaryUnmarshaler); ok {
+		// Fast path for generated decoders: v must still be a non-nil pointer,
+		// otherwise it's reported by the reflection path below.
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			return unmarshaler.UnmarshalWithDecoder(dec)
+		}
+	}
+
+	if rv, plan := dec.flatStructTarget(v); plan != nil {
+		return dec.decodeFlatStruct(rv, plan, dec.order)
+	}
+
 	switch dec.encoding {
-	case EncodingBin:
+	case EncodingBin, EncodingBitcoin:
+		// Bitcoin only differs from Bin by its length prefixes.
 		return dec.decodeWithOptionBin(v, nil)
 	case EncodingBorsh:
 		return dec.decodeWithOptionBorsh(v, nil)
@@ -128,6 +307,138 @@ func (dec *Decoder) Decode(v interface{}) (err error) {
 	}
 }
 
+// DecodeMulti decodes each of the provided pointers in turn, from consecutive bytes.
+// It stops at the first error, which reports the index of the value that failed;
+// the position of the decoder is then left where decoding stopped,
+// after the values that were decoded successfully.
+func (dec *Decoder) DecodeMulti(vs ...interface{}) error {
+	for i, v := range vs {
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("decode value %d (%T): %w", i, v, err)
+		}
+	}
+	return nil
+}
+
+// ReadDelimited reads a message prefixed by its length in bytes as a uvarint
+// (like protobuf's writeDelimitedTo), and decodes v from it; repeated calls walk
+// a stream of such messages.
+// Decoding v fails if it reads past the end of the message; bytes of the
+// message that are left over after decoding v are skipped.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadDelimited(v interface{}) error {
+	start := dec.pos
+	length, err := dec.ReadUvarint64()
+	if err != nil {
+		return fmt.Errorf("delimited: %w", err)
+	}
+	if remaining := dec.Remaining(); length > uint64(remaining) {
+		dec.pos = start
+		return errorf(ErrShortBuffer, "delimited: message length %d exceeds the %d remaining bytes", length, remaining)
+	}
+
+	msg := dec.subDecoder(dec.data[dec.pos : dec.pos+int(length)])
+	if err := msg.Decode(v); err != nil {
+		dec.pos = start
+		return fmt.Errorf("delimited: %w", err)
+	}
+	if traceEnabled && msg.Remaining() > 0 {
+		zlog.Debug("decode: skipping delimited message leftover", zap.Int("leftover", msg.Remaining()))
+	}
+	dec.pos += int(length)
+	return nil
+}
+
+// DecodeTx decodes v like Decode, but restores the position of the decoder
+// if decoding fails, e.g. after a custom unmarshaler consumed some bytes then
+// returned an error, so that the same bytes can be decoded again as another type.
+// Note that v may be partially filled on error.
+func (dec *Decoder) DecodeTx(v interface{}) error {
+	start := dec.pos
+	if err := dec.Decode(v); err != nil {
+		dec.pos = start
+		return err
+	}
+	return nil
+}
+
+// DecodeThen decodes outer, and returns a decoder of the bytes that follow it,
+// with the same settings as dec, for the two-stage decoding of layered protocols:
+// the type of the inner message can then be chosen from the contents of outer.
+// The position of dec is advanced past outer only; on error, it is left unchanged.
+func (dec *Decoder) DecodeThen(outer interface{}) (*Decoder, error) {
+	start := dec.pos
+	if err := dec.Decode(outer); err != nil {
+		dec.pos = start
+		return nil, fmt.Errorf("decode then: %w", err)
+	}
+	return dec.subDecoder(dec.data[dec.pos:]), nil
+}
+
+// subDecoder returns a decoder of the provided data
+// with the same settings as dec.
+func (dec *Decoder) subDecoder(data []byte) *Decoder {
+	return &Decoder{
+		data:                    data,
+		encoding:                dec.encoding,
+		order:                   dec.order,
+		cLayout:                 dec.cLayout,
+		onProgress:              dec.onProgress,
+		lengthEncoding:          dec.lengthEncoding,
+		floatPolicy:             dec.floatPolicy,
+		maxStringLen:            dec.maxStringLen,
+		namedInterfaces:         dec.namedInterfaces,
+		allocator:               dec.allocator,
+		fieldHook:               dec.fieldHook,
+		strictCompactSize:       dec.strictCompactSize,
+		enumNameNormalizer:      dec.enumNameNormalizer,
+		strictMapOrder:          dec.strictMapOrder,
+		lenientTrailingOptional: dec.lenientTrailingOptional,
+		path:                    dec.FieldPath(),
+	}
+}
+
+// encoder returns an encoder writing to the provided writer with the
+// settings of dec that affect the encoding, so that it encodes the
+// values decoded by dec the same way they were decoded.
+func (dec *Decoder) encoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		output:          writer,
+		encoding:        dec.encoding,
+		order:           dec.order,
+		cLayout:         dec.cLayout,
+		namedInterfaces: dec.namedInterfaces,
+		lengthEncoding:  dec.lengthEncoding,
+	}
+}
+
+// DecodeCanonical decodes v just like Decode, then re-encodes it with the same
+// encoding and returns an error wrapping ErrNonCanonical if the result differs
+// from the bytes that were consumed (e.g. non-minimal varints, or bools
+// that are neither 0 nor 1).
+func (dec *Decoder) DecodeCanonical(v interface{}) (err error) {
+	start := dec.pos
+	if err = dec.Decode(v); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = dec.encoder(buf).Encode(v); err != nil {
+		return fmt.Errorf("canonical: unable to re-encode: %w", err)
+	}
+
+	consumed := dec.data[start:dec.pos]
+	reencoded := buf.Bytes()
+	if !bytes.Equal(consumed, reencoded) {
+		offset := 0
+		for offset < len(consumed) && offset < len(reencoded) && consumed[offset] == reencoded[offset] {
+			offset++
+		}
+		return fmt.Errorf("%w: consumed %d bytes, re-encoded to %d bytes, first difference at offset %d", ErrNonCanonical, len(consumed), len(reencoded), start+offset)
+	}
+	return nil
+}
+
 func sizeof(t reflect.Type, v reflect.Value) int {
 	switch t.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -145,11 +456,17 @@ func sizeof(t reflect.Type, v reflect.Value) int {
 	}
 }
 
-var ErrVarIntBufferSize = errors.New("varint: invalid buffer size")
+var ErrVarIntBufferSize = errorf(ErrShortBuffer, "varint: invalid buffer size")
+
+// ErrVarIntOverflow reports a varint that doesn't fit in 64 bits.
+var ErrVarIntOverflow = errorf(ErrInvalidLength, "varint: overflows a 64-bit integer")
 
 func (dec *Decoder) ReadUvarint64() (uint64, error) {
 	l, read := binary.Uvarint(dec.data[dec.pos:])
-	if read <= 0 {
+	if read < 0 {
+		return 0, ErrVarIntOverflow
+	}
+	if read == 0 {
 		return l, ErrVarIntBufferSize
 	}
 	if traceEnabled {
@@ -161,7 +478,10 @@ func (dec *Decoder) ReadUvarint64() (uint64, error) {
 
 func (d *Decoder) ReadVarint64() (out int64, err error) {
 	l, read := binary.Varint(d.data[d.pos:])
-	if read <= 0 {
+	if read < 0 {
+		return 0, ErrVarIntOverflow
+	}
+	if read == 0 {
 		return l, ErrVarIntBufferSize
 	}
 	if traceEnabled {
@@ -220,6 +540,115 @@ func (dec *Decoder) ReadUvarint16() (out uint16, err error) {
 	return
 }
 
+// ReadVarintN reads a zigzag varint and returns an error if its value doesn't fit
+// in a signed integer of the provided bit width (1 to 64), instead of truncating it
+// like ReadVarint32 and ReadVarint16 do.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadVarintN(bits int) (out int64, err error) {
+	if bits < 1 || bits > 64 {
+		return 0, fmt.Errorf("varint: invalid bit width %d", bits)
+	}
+	start := dec.pos
+	out, err = dec.ReadVarint64()
+	if err != nil {
+		return out, err
+	}
+	if bits < 64 {
+		max := int64(1)<<(bits-1) - 1
+		min := -max - 1
+		if out < min || out > max {
+			dec.pos = start
+			return 0, errorf(ErrInvalidLength, "varint: value %d overflows %d bits", out, bits)
+		}
+	}
+	return out, nil
+}
+
+// ReadUvarint64Slice reads n consecutive uvarints.
+func (dec *Decoder) ReadUvarint64Slice(n int) (out []uint64, err error) {
+	// Each uvarint takes at least one byte.
+	if n < 0 || n > dec.Remaining() {
+		return nil, errorf(ErrShortBuffer, "uvarint slice: cannot read %d uvarints, remaining [%d] bytes", n, dec.Remaining())
+	}
+	out = make([]uint64, n)
+	for i := range out {
+		if out[i], err = dec.ReadUvarint64(); err != nil {
+			return nil, fmt.Errorf("uvarint slice: element %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// ReadDeltaUvarint64Slice reads n consecutive delta-encoded uvarints,
+// where each one is the difference from the previous value (the first one
+// being the difference from zero), and returns the accumulated values.
+func (dec *Decoder) ReadDeltaUvarint64Slice(n int) (out []uint64, err error) {
+	out, err = dec.ReadUvarint64Slice(n)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(out); i++ {
+		out[i] += out[i-1]
+	}
+	return out, nil
+}
+
+// ReadVarint64Slice reads n consecutive zigzag varints.
+func (dec *Decoder) ReadVarint64Slice(n int) (out []int64, err error) {
+	// Each varint takes at least one byte.
+	if n < 0 || n > dec.Remaining() {
+		return nil, errorf(ErrShortBuffer, "varint slice: cannot read %d varints, remaining [%d] bytes", n, dec.Remaining())
+	}
+	out = make([]int64, n)
+	for i := range out {
+		if out[i], err = dec.ReadVarint64(); err != nil {
+			return nil, fmt.Errorf("varint slice: element %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// ReadDeltaVarint64Slice reads n consecutive delta-encoded zigzag varints,
+// where each one is the signed difference from the previous value (the first one
+// being the difference from zero), and returns the accumulated values.
+// Like the int64 arithmetic, the accumulation wraps around on overflow.
+func (dec *Decoder) ReadDeltaVarint64Slice(n int) (out []int64, err error) {
+	out, err = dec.ReadVarint64Slice(n)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(out); i++ {
+		out[i] += out[i-1]
+	}
+	return out, nil
+}
+
+// ReadUvarintBE reads an unsigned varint encoded as big-endian
+// (most significant group first) 7-bit groups, where the high bit of
+// each byte signals that more bytes follow.
+//
+// Note that this is NOT the same format as ReadUvarint64 (LEB128).
+func (dec *Decoder) ReadUvarintBE() (out uint64, err error) {
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		if dec.Remaining() <= i {
+			return 0, ErrVarIntBufferSize
+		}
+		b := dec.data[dec.pos+i]
+		if out > math.MaxUint64>>7 {
+			break
+		}
+		out = out<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			dec.pos += i + 1
+			if traceEnabled {
+				zlog.Debug("decode: read uvarint BE", zap.Uint64("val", out))
+			}
+			return out, nil
+		}
+	}
+	return 0, errorf(ErrInvalidLength, "varint BE: overflows a 64-bit integer")
+}
+
 func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
 	length, err := dec.ReadLength()
 	if err != nil {
@@ -227,7 +656,7 @@ func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
 	}
 
 	if len(dec.data) < dec.pos+length {
-		return nil, fmt.Errorf("byte array: varlen=%d, missing %d bytes", length, dec.pos+length-len(dec.data))
+		return nil, errorf(ErrShortBuffer, "byte array: varlen=%d, missing %d bytes", length, dec.pos+length-len(dec.data))
 	}
 
 	out = dec.data[dec.pos : dec.pos+length]
@@ -238,32 +667,148 @@ func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
 	return
 }
 
+// ReadOptionalByteSlice reads an optional length-prefixed byte slice (e.g. a Borsh
+// Option<Vec<u8>>), with the presence flag of the optional fields of the encoding.
+// It distinguishes an absent slice (nil, false) from a present but empty one ([]byte{}, true).
+func (dec *Decoder) ReadOptionalByteSlice() (out []byte, present bool, err error) {
+	if dec.absentAtEOF() {
+		return nil, false, nil
+	}
+	if dec.IsBin() || dec.IsBitcoin() {
+		var flag uint32
+		flag, err = dec.ReadUint32(binary.LittleEndian)
+		present = flag != 0
+	} else {
+		var flag byte
+		flag, err = dec.ReadByte()
+		present = flag != 0
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("optional byte slice: isPresent: %w", err)
+	}
+	if !present {
+		return nil, false, nil
+	}
+
+	out, err = dec.ReadByteSlice()
+	if err != nil {
+		return nil, false, err
+	}
+	if out == nil {
+		out = []byte{}
+	}
+	return out, true, nil
+}
+
 func (dec *Decoder) ReadLength() (length int, err error) {
-	switch dec.encoding {
-	case EncodingBin:
+	kind := dec.lengthEncoding
+	if kind == LengthEncodingDefault {
+		switch dec.encoding {
+		case EncodingBin:
+			kind = LengthEncodingUvarint
+		case EncodingBorsh:
+			kind = LengthEncodingU32
+		case EncodingCompactU16:
+			kind = LengthEncodingCompactU16
+		case EncodingBitcoin:
+			kind = LengthEncodingCompactSize
+		default:
+			panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
+		}
+	}
+
+	switch kind {
+	case LengthEncodingUvarint:
 		val, err := dec.ReadUvarint64()
 		if err != nil {
 			return 0, err
 		}
 		length = int(val)
-	case EncodingBorsh:
-		val, err := dec.ReadUint32(LE)
+		if length < 0 {
+			return 0, errorf(ErrInvalidLength, "length %d overflows an int", val)
+		}
+	case LengthEncodingU16:
+		val, err := dec.ReadUint16(dec.order)
 		if err != nil {
 			return 0, err
 		}
 		length = int(val)
-	case EncodingCompactU16:
+	case LengthEncodingU32:
+		val, err := dec.ReadUint32(dec.order)
+		if err != nil {
+			return 0, err
+		}
+		length = int(val)
+	case LengthEncodingCompactU16:
 		val, err := DecodeCompactU16LengthFromByteReader(dec)
 		if err != nil {
 			return 0, err
 		}
 		length = val
+	case LengthEncodingBincodeVarint:
+		val, err := dec.ReadBincodeVarint()
+		if err != nil {
+			return 0, err
+		}
+		length = int(val)
+		if length < 0 {
+			return 0, errorf(ErrInvalidLength, "length %d overflows an int", val)
+		}
+	case LengthEncodingCompactSize:
+		val, err := dec.ReadCompactSize()
+		if err != nil {
+			return 0, err
+		}
+		length = int(val)
+		if length < 0 {
+			return 0, errorf(ErrInvalidLength, "length %d overflows an int", val)
+		}
 	default:
-		panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
+		panic(fmt.Errorf("length encoding not implemented: %s", kind))
 	}
 	return
 }
 
+// SetLengthEncoding overrides the encoding of the length prefixes read by the decoder
+// (see ReadLength), independently of its Encoding.
+// Use LengthEncodingDefault to restore the length encoding of the Encoding.
+func (dec *Decoder) SetLengthEncoding(kind LengthEncoding) {
+	dec.lengthEncoding = kind
+}
+
+// readByteLenFixedCount reads a length prefix that counts bytes
+// and returns the number of fixed-size elements of type elem it spans.
+func (dec *Decoder) readByteLenFixedCount(elem reflect.Type) (int, error) {
+	size, ok := staticSizeOf(elem)
+	if !ok || size == 0 || dec.lengthEncoding == LengthEncodingBincodeVarint {
+		return 0, fmt.Errorf("bytelen_fixed: element type %s doesn't have a fixed size", elem)
+	}
+	byteLen, err := dec.ReadLength()
+	if err != nil {
+		return 0, err
+	}
+	if byteLen%size != 0 {
+		return 0, errorf(ErrInvalidLength, "bytelen_fixed: byte length %d is not a multiple of the element size %d", byteLen, size)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read byte length of fixed-size elements", zap.Int("byte_len", byteLen), zap.Int("element_size", size))
+	}
+	return byteLen / size, nil
+}
+
+// fillCount returns the number of fixed-size elements of type elem
+// that fill the remaining bytes.
+func (dec *Decoder) fillCount(elem reflect.Type) (int, error) {
+	size, ok := staticSizeOf(elem)
+	if !ok || size == 0 || dec.lengthEncoding == LengthEncodingBincodeVarint {
+		return 0, fmt.Errorf("fill: element type %s doesn't have a fixed size", elem)
+	}
+	if dec.Remaining()%size != 0 {
+		return 0, errorf(ErrInvalidLength, "fill: %d remaining bytes are not a multiple of the element size %d", dec.Remaining(), size)
+	}
+	return dec.Remaining() / size, nil
+}
+
 type peekAbleByteReader interface {
 	io.ByteReader
 	Peek(n int) ([]byte, error)
@@ -283,9 +828,39 @@ func readNBytes(n int, reader peekAbleByteReader) ([]byte, error) {
 }
 
 func (dec *Decoder) ReadNBytes(n int) (out []byte, err error) {
+	// Check the length first, so that a bogus one doesn't allocate.
+	if n < 0 {
+		return nil, errorf(ErrInvalidLength, "n not valid: %d", n)
+	}
+	if n > dec.Remaining() {
+		return nil, errorf(ErrShortBuffer, "required [%d] bytes, remaining [%d]", n, dec.Remaining())
+	}
+	if dec.allocator != nil {
+		if out, err = dec.allocBytes(n); err != nil {
+			return nil, err
+		}
+		copy(out, dec.data[dec.pos:dec.pos+n])
+		dec.pos += n
+		return out, nil
+	}
 	return readNBytes(n, dec)
 }
 
+// ReadBytesInto copies the next len(dst) bytes into the caller-owned dst,
+// returning the number of bytes copied. It returns an error without
+// consuming anything if fewer than len(dst) bytes remain.
+func (dec *Decoder) ReadBytesInto(dst []byte) (n int, err error) {
+	if dec.Remaining() < len(dst) {
+		return 0, errorf(ErrShortBuffer, "required [%d] bytes, remaining [%d]", len(dst), dec.Remaining())
+	}
+	n = copy(dst, dec.data[dec.pos:])
+	dec.pos += n
+	if traceEnabled {
+		zlog.Debug("decode: read bytes into", zap.Int("n", n))
+	}
+	return n, nil
+}
+
 func (dec *Decoder) ReadTypeID() (out TypeID, err error) {
 	discriminator, err := dec.ReadNBytes(8)
 	if err != nil {
@@ -302,7 +877,7 @@ func (dec *Decoder) Peek(n int) (out []byte, err error) {
 
 	requiredSize := TypeSize.Byte * n
 	if dec.Remaining() < requiredSize {
-		err = fmt.Errorf("required [%d] bytes, remaining [%d]", requiredSize, dec.Remaining())
+		err = errorf(ErrShortBuffer, "required [%d] bytes, remaining [%d]", requiredSize, dec.Remaining())
 		return
 	}
 
@@ -313,9 +888,74 @@ func (dec *Decoder) Peek(n int) (out []byte, err error) {
 	return
 }
 
+// PeekTail returns the last n bytes of the remaining data, without consuming them,
+// e.g. to read a trailer before decoding the body from the front.
+func (dec *Decoder) PeekTail(n int) (out []byte, err error) {
+	if n < 0 {
+		err = fmt.Errorf("n not valid: %d", n)
+		return
+	}
+	if dec.Remaining() < n {
+		err = errorf(ErrShortBuffer, "required [%d] bytes, remaining [%d]", n, dec.Remaining())
+		return
+	}
+
+	out = dec.data[len(dec.data)-n:]
+	if traceEnabled {
+		zlog.Debug("decode: peek tail", zap.Int("n", n), zap.Binary("out", out))
+	}
+	return
+}
+
+// PeekTailByte returns the last byte of the remaining data, without consuming it,
+// e.g. to dispatch on a type tag placed at the end of a frame.
+func (dec *Decoder) PeekTailByte() (byte, error) {
+	out, err := dec.PeekTail(1)
+	if err != nil {
+		return 0, err
+	}
+	return out[0], nil
+}
+
+// PeekAt returns the n bytes at the absolute offset in the data,
+// without consuming them or changing the position of the decoder.
+func (dec *Decoder) PeekAt(offset uint, n int) (out []byte, err error) {
+	if n < 0 {
+		err = fmt.Errorf("n not valid: %d", n)
+		return
+	}
+	if offset > uint(len(dec.data)) || len(dec.data)-int(offset) < n {
+		err = errorf(ErrShortBuffer, "required [%d] bytes at offset %d, data length [%d]", n, offset, len(dec.data))
+		return
+	}
+
+	out = dec.data[offset : int(offset)+n]
+	if traceEnabled {
+		zlog.Debug("decode: peek at", zap.Uint("offset", offset), zap.Int("n", n), zap.Binary("out", out))
+	}
+	return
+}
+
+// ReadLenPrefixedAt reads the length-prefixed byte slice (see ReadByteSlice)
+// at the absolute offset in the data, e.g. a blob referenced by an offset table,
+// without changing the position of the decoder.
+func (dec *Decoder) ReadLenPrefixedAt(offset uint) (out []byte, err error) {
+	if _, err = dec.PeekAt(offset, 0); err != nil {
+		return nil, err
+	}
+	pos := dec.pos
+	defer func() { dec.pos = pos }()
+
+	dec.pos = int(offset)
+	if out, err = dec.ReadByteSlice(); err != nil {
+		return nil, fmt.Errorf("blob at offset %d: %w", offset, err)
+	}
+	return out, nil
+}
+
 func (dec *Decoder) ReadByte() (out byte, err error) {
 	if dec.Remaining() < TypeSize.Byte {
-		err = fmt.Errorf("required [1] byte, remaining [%d]", dec.Remaining())
+		err = errorf(ErrShortBuffer, "required [1] byte, remaining [%d]", dec.Remaining())
 		return
 	}
 
@@ -329,7 +969,7 @@ func (dec *Decoder) ReadByte() (out byte, err error) {
 
 func (dec *Decoder) ReadBool() (out bool, err error) {
 	if dec.Remaining() < TypeSize.Bool {
-		err = fmt.Errorf("bool required [%d] byte, remaining [%d]", TypeSize.Bool, dec.Remaining())
+		err = errorf(ErrShortBuffer, "bool required [%d] byte, remaining [%d]", TypeSize.Bool, dec.Remaining())
 		return
 	}
 
@@ -346,6 +986,42 @@ func (dec *Decoder) ReadBool() (out bool, err error) {
 
 }
 
+// ReadTriBool reads a tri-state boolean byte: 0 is false, 1 is true,
+// and 2 is unknown, which is returned as nil.
+// On values above 2, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadTriBool() (out *bool, err error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("tribool: %w", err)
+	}
+	switch b {
+	case 0, 1:
+		value := b == 1
+		out = &value
+	case 2:
+	default:
+		dec.pos--
+		return nil, fmt.Errorf("tribool: invalid value %d", b)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read tribool", zap.Uint8("val", b))
+	}
+	return out, nil
+}
+
+// decodeTriBool reads a `bin:"tribool"` *bool field.
+func (dec *Decoder) decodeTriBool(rv reflect.Value) error {
+	if rv.Type() != reflect.TypeOf((*bool)(nil)) {
+		return fmt.Errorf("tribool: expected a *bool field, got %s", rv.Type())
+	}
+	out, err := dec.ReadTriBool()
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(out))
+	return nil
+}
+
 func (dec *Decoder) ReadUint8() (out uint8, err error) {
 	out, err = dec.ReadByte()
 	return
@@ -362,7 +1038,7 @@ func (dec *Decoder) ReadInt8() (out int8, err error) {
 
 func (dec *Decoder) ReadUint16(order binary.ByteOrder) (out uint16, err error) {
 	if dec.Remaining() < TypeSize.Uint16 {
-		err = fmt.Errorf("uint16 required [%d] bytes, remaining [%d]", TypeSize.Uint16, dec.Remaining())
+		err = errorf(ErrShortBuffer, "uint16 required [%d] bytes, remaining [%d]", TypeSize.Uint16, dec.Remaining())
 		return
 	}
 
@@ -374,6 +1050,98 @@ func (dec *Decoder) ReadUint16(order binary.ByteOrder) (out uint16, err error) {
 	return
 }
 
+// ReadUintN reads an unsigned integer of nBytes bytes (1 to 8),
+// for widths that are not a power of two, like 24-bit integers.
+func (dec *Decoder) ReadUintN(nBytes int, order binary.ByteOrder) (out uint64, err error) {
+	if nBytes < 1 || nBytes > TypeSize.Uint64 {
+		return 0, fmt.Errorf("uintN: invalid width of %d bytes", nBytes)
+	}
+	if dec.Remaining() < nBytes {
+		return 0, errorf(ErrShortBuffer, "uint%d required [%d] bytes, remaining [%d]", nBytes*8, nBytes, dec.Remaining())
+	}
+
+	// Pad the value to 64 bits on its most significant side.
+	buf := [8]byte{}
+	if isBigEndian(order) {
+		copy(buf[TypeSize.Uint64-nBytes:], dec.data[dec.pos:dec.pos+nBytes])
+	} else {
+		copy(buf[:nBytes], dec.data[dec.pos:dec.pos+nBytes])
+	}
+	out = order.Uint64(buf[:])
+	dec.pos += nBytes
+	if traceEnabled {
+		zlog.Debug("decode: read uintN", zap.Int("bytes", nBytes), zap.Uint64("val", out))
+	}
+	return out, nil
+}
+
+// ReadUint24 reads a 24-bit unsigned integer.
+func (dec *Decoder) ReadUint24(order binary.ByteOrder) (out uint32, err error) {
+	n, err := dec.ReadUintN(3, order)
+	return uint32(n), err
+}
+
+// decodeU24 reads a `bin:"u24"` uint32 field.
+func (dec *Decoder) decodeU24(rv reflect.Value, order binary.ByteOrder) error {
+	if rv.Kind() != reflect.Uint32 {
+		return fmt.Errorf("u24: expected a uint32 field, got %s", rv.Type())
+	}
+	n, err := dec.ReadUint24(order)
+	if err != nil {
+		return err
+	}
+	rv.SetUint(uint64(n))
+	return nil
+}
+
+// ReadIntN reads a two's complement signed integer on nBytes bytes (1 to 8),
+// sign-extended to 64 bits, for widths that are not a power of two, like 24-bit integers.
+func (dec *Decoder) ReadIntN(nBytes int, order binary.ByteOrder) (out int64, err error) {
+	if nBytes < 1 || nBytes > TypeSize.Uint64 {
+		return 0, fmt.Errorf("intN: invalid width of %d bytes", nBytes)
+	}
+	u, err := dec.ReadUintN(nBytes, order)
+	if err != nil {
+		return 0, err
+	}
+	shift := uint(64 - nBytes*8)
+	return int64(u<<shift) >> shift, nil
+}
+
+// ReadInt24 reads a 24-bit signed integer.
+func (dec *Decoder) ReadInt24(order binary.ByteOrder) (out int32, err error) {
+	n, err := dec.ReadIntN(3, order)
+	return int32(n), err
+}
+
+// decodeIntN reads a `bin:"i24"` (or i40, i48, i56) signed integer field
+// of nBytes bytes, which must be at least as wide as the encoded integer.
+func (dec *Decoder) decodeIntN(rv reflect.Value, nBytes int, order binary.ByteOrder) error {
+	if err := checkIntNField(rv, nBytes); err != nil {
+		return err
+	}
+	n, err := dec.ReadIntN(nBytes, order)
+	if err != nil {
+		return err
+	}
+	rv.SetInt(n)
+	return nil
+}
+
+func checkIntNField(rv reflect.Value, nBytes int) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Type().Bits() >= nBytes*8 {
+			return nil
+		}
+	}
+	return fmt.Errorf("i%d: expected a signed integer field of at least %d bits, got %s", nBytes*8, nBytes*8, rv.Type())
+}
+
+func isBigEndian(order binary.ByteOrder) bool {
+	return order.Uint16([]byte{0x00, 0x01}) == 1
+}
+
 func (dec *Decoder) ReadInt16(order binary.ByteOrder) (out int16, err error) {
 	n, err := dec.ReadUint16(order)
 	out = int16(n)
@@ -394,7 +1162,7 @@ func (dec *Decoder) ReadInt64(order binary.ByteOrder) (out int64, err error) {
 
 func (dec *Decoder) ReadUint32(order binary.ByteOrder) (out uint32, err error) {
 	if dec.Remaining() < TypeSize.Uint32 {
-		err = fmt.Errorf("uint32 required [%d] bytes, remaining [%d]", TypeSize.Uint32, dec.Remaining())
+		err = errorf(ErrShortBuffer, "uint32 required [%d] bytes, remaining [%d]", TypeSize.Uint32, dec.Remaining())
 		return
 	}
 
@@ -417,15 +1185,13 @@ func (dec *Decoder) ReadInt32(order binary.ByteOrder) (out int32, err error) {
 
 func (dec *Decoder) ReadUint64(order binary.ByteOrder) (out uint64, err error) {
 	if dec.Remaining() < TypeSize.Uint64 {
-		err = fmt.Errorf("decode: uint64 required [%d] bytes, remaining [%d]", TypeSize.Uint64, dec.Remaining())
+		err = errorf(ErrShortBuffer, "decode: uint64 required [%d] bytes, remaining [%d]", TypeSize.Uint64, dec.Remaining())
 		return
 	}
 
-	data, err := dec.ReadNBytes(TypeSize.Uint64)
-	if err != nil {
-		return 0, err
-	}
+	data := dec.data[dec.pos : dec.pos+TypeSize.Uint64]
 	out = order.Uint64(data)
+	dec.pos += TypeSize.Uint64
 	if traceEnabled {
 		zlog.Debug("decode: read uint64", zap.Uint64("val", out), zap.Stringer("hex", HexBytes(data)))
 	}
@@ -443,7 +1209,7 @@ func (dec *Decoder) ReadInt128(order binary.ByteOrder) (out Int128, err error) {
 
 func (dec *Decoder) ReadUint128(order binary.ByteOrder) (out Uint128, err error) {
 	if dec.Remaining() < TypeSize.Uint128 {
-		err = fmt.Errorf("uint128 required [%d] bytes, remaining [%d]", TypeSize.Uint128, dec.Remaining())
+		err = errorf(ErrShortBuffer, "uint128 required [%d] bytes, remaining [%d]", TypeSize.Uint128, dec.Remaining())
 		return
 	}
 
@@ -467,7 +1233,7 @@ func (dec *Decoder) ReadUint128(order binary.ByteOrder) (out Uint128, err error)
 
 func (dec *Decoder) ReadFloat32(order binary.ByteOrder) (out float32, err error) {
 	if dec.Remaining() < TypeSize.Float32 {
-		err = fmt.Errorf("float32 required [%d] bytes, remaining [%d]", TypeSize.Float32, dec.Remaining())
+		err = errorf(ErrShortBuffer, "float32 required [%d] bytes, remaining [%d]", TypeSize.Float32, dec.Remaining())
 		return
 	}
 
@@ -478,17 +1244,15 @@ func (dec *Decoder) ReadFloat32(order binary.ByteOrder) (out float32, err error)
 		zlog.Debug("decode: read float32", zap.Float32("val", out))
 	}
 
-	if dec.IsBorsh() {
-		if math.IsNaN(float64(out)) {
-			return 0, errors.New("NaN for float not allowed")
-		}
+	if err = dec.checkFloat(float64(out)); err != nil {
+		return 0, err
 	}
 	return
 }
 
 func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error) {
 	if dec.Remaining() < TypeSize.Float64 {
-		err = fmt.Errorf("float64 required [%d] bytes, remaining [%d]", TypeSize.Float64, dec.Remaining())
+		err = errorf(ErrShortBuffer, "float64 required [%d] bytes, remaining [%d]", TypeSize.Float64, dec.Remaining())
 		return
 	}
 
@@ -498,10 +1262,8 @@ func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error)
 	if traceEnabled {
 		zlog.Debug("decode: read Float64", zap.Float64("val", out))
 	}
-	if dec.IsBorsh() {
-		if math.IsNaN(out) {
-			return 0, errors.New("NaN for float not allowed")
-		}
+	if err = dec.checkFloat(out); err != nil {
+		return 0, err
 	}
 	return
 }
@@ -516,7 +1278,7 @@ func (dec *Decoder) ReadFloat128(order binary.ByteOrder) (out Float128, err erro
 }
 
 func (dec *Decoder) SafeReadUTF8String() (out string, err error) {
-	data, err := dec.ReadByteSlice()
+	data, err := dec.readStringBytes(nil)
 	out = strings.Map(fixUtf, string(data))
 	if traceEnabled {
 		zlog.Debug("read safe UTF8 string", zap.String("val", out))
@@ -531,8 +1293,62 @@ func fixUtf(r rune) rune {
 	return r
 }
 
+// ReadLenString reads a string like ReadString.
+func (dec *Decoder) ReadLenString() (out string, err error) {
+	return dec.ReadString()
+}
+
+// SetMaxStringLen sets the maximum length in bytes of the strings read by the decoder:
+// reading a string with a longer declared length fails before allocating it.
+// A max of 0 means unlimited, which is the default.
+func (dec *Decoder) SetMaxStringLen(max int) {
+	dec.maxStringLen = max
+}
+
+// checkStringLen checks the length in bytes of a string read from the wire against
+// the max of the decoder, and against the minlen and maxlen tags of opt, if any.
+func (dec *Decoder) checkStringLen(length uint64, opt *option) error {
+	if dec.maxStringLen > 0 && length > uint64(dec.maxStringLen) {
+		return errorf(ErrInvalidLength, "string length %d exceeds the max of %d", length, dec.maxStringLen)
+	}
+	n := int(length)
+	if n < 0 || uint64(n) != length {
+		// Longer than any maxlen.
+		n = int(^uint(0) >> 1)
+	}
+	return opt.checkLen(n)
+}
+
+// readStringBytes reads the bytes of a string
+// prefixed by its length (see ReadLength).
+func (dec *Decoder) readStringBytes(opt *option) ([]byte, error) {
+	length, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	if err := dec.checkStringLen(uint64(length), opt); err != nil {
+		return nil, err
+	}
+	if len(dec.data) < dec.pos+length {
+		return nil, errorf(ErrShortBuffer, "string: varlen=%d, missing %d bytes", length, dec.pos+length-len(dec.data))
+	}
+	out := dec.data[dec.pos : dec.pos+length]
+	dec.pos += length
+	return out, nil
+}
+
+// ReadString reads a string prefixed by its length in bytes, encoded with the length
+// encoding of the decoder (see ReadLength): a uvarint for Bin, a u32 for Borsh,
+// a compact-u16 for CompactU16, and a CompactSize for Bitcoin, unless overridden
+// with SetLengthEncoding.
 func (dec *Decoder) ReadString() (out string, err error) {
-	data, err := dec.ReadByteSlice()
+	return dec.readString(nil)
+}
+
+// readString reads a string like ReadString, checking its length
+// against the minlen and maxlen tags of opt before reading it.
+func (dec *Decoder) readString(opt *option) (out string, err error) {
+	data, err := dec.readStringBytes(opt)
 	out = string(data)
 	if traceEnabled {
 		zlog.Debug("read string", zap.String("val", out))
@@ -540,16 +1356,115 @@ func (dec *Decoder) ReadString() (out string, err error) {
 	return
 }
 
+// ReadRLEBytes reads run-length-encoded bytes, as {count}{byte} pairs
+// of one byte each, until totalLen bytes are produced.
+// It returns an error if the last pair produces bytes past totalLen.
+func (dec *Decoder) ReadRLEBytes(totalLen int) (out []byte, err error) {
+	if totalLen < 0 {
+		return nil, errorf(ErrInvalidLength, "rle: invalid total length %d", totalLen)
+	}
+	out = make([]byte, 0, totalLen)
+	for len(out) < totalLen {
+		pair, err := dec.ReadNBytes(2)
+		if err != nil {
+			return nil, fmt.Errorf("rle: %w", err)
+		}
+		count, value := int(pair[0]), pair[1]
+		if len(out)+count > totalLen {
+			return nil, fmt.Errorf("rle: run of %d bytes overflows the total length %d by %d bytes", count, totalLen, len(out)+count-totalLen)
+		}
+		for i := 0; i < count; i++ {
+			out = append(out, value)
+		}
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read rle bytes", zap.Int("len", totalLen))
+	}
+	return out, nil
+}
+
+// ReadTLV reads a type-length-value record: a 1-byte tag, a length
+// encoded according to the decoder's encoding (see ReadLength), and that many value bytes.
+// The returned value is a copy and does not share the data of the decoder.
+func (dec *Decoder) ReadTLV() (tag uint8, value []byte, err error) {
+	tag, err = dec.ReadUint8()
+	if err != nil {
+		return 0, nil, fmt.Errorf("tlv: tag: %w", err)
+	}
+	length, err := dec.ReadLength()
+	if err != nil {
+		return 0, nil, fmt.Errorf("tlv: length: %w", err)
+	}
+	value, err = dec.ReadNBytes(length)
+	if err != nil {
+		return 0, nil, fmt.Errorf("tlv: value of tag %d: %w", tag, err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read tlv", zap.Uint8("tag", tag), zap.Stringer("hex", HexBytes(value)))
+	}
+	return tag, value, nil
+}
+
+// DecodeTLVMap reads type-length-value records (see ReadTLV) until the end
+// of the data, and calls the handler of the tag of each record with its value.
+// It returns an error if a record has a tag without handler.
+func (dec *Decoder) DecodeTLVMap(handlers map[uint8]func(value []byte) error) error {
+	for dec.HasRemaining() {
+		tag, value, err := dec.ReadTLV()
+		if err != nil {
+			return err
+		}
+		handler, found := handlers[tag]
+		if !found {
+			return fmt.Errorf("tlv: no handler for tag %d", tag)
+		}
+		if err := handler(value); err != nil {
+			return fmt.Errorf("tlv: tag %d: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads a length-prefixed byte slice and unmarshals it
+// as a JSON document into v.
+func (dec *Decoder) ReadJSON(v interface{}) (err error) {
+	data, err := dec.ReadByteSlice()
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("read json", zap.ByteString("val", data))
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	return nil
+}
+
+// ReadRustString reads a string prefixed by its length in bytes as a
+// little-endian u64, as encoded by Bincode, regardless of the decoder's encoding.
+// This is NOT the string encoding of Borsh (u32 length) nor CompactU16: use ReadString
+// to read a string prefixed by the length encoding of the decoder.
 func (dec *Decoder) ReadRustString() (out string, err error) {
+	return dec.readRustString(nil)
+}
+
+// readRustString reads a string like ReadRustString, checking its length
+// against the minlen and maxlen tags of opt before reading it.
+func (dec *Decoder) readRustString(opt *option) (out string, err error) {
 	length, err := dec.ReadUint64(binary.LittleEndian)
 	if err != nil {
 		return "", err
 	}
-	bytes, err := dec.ReadNBytes(int(length))
-	if err != nil {
+	if err := dec.checkStringLen(length, opt); err != nil {
 		return "", err
 	}
-	out = string(bytes)
+	// Slice the data directly: the string conversion copies it anyway.
+	if length > uint64(dec.Remaining()) {
+		return "", errorf(ErrShortBuffer, "required [%d] bytes, remaining [%d]", length, dec.Remaining())
+	}
+	out = string(dec.data[dec.pos : dec.pos+int(length)])
+	dec.pos += int(length)
 	if traceEnabled {
 		zlog.Debug("read Rust string", zap.String("val", out))
 	}
@@ -564,14 +1479,189 @@ func (dec *Decoder) ReadCompactU16Length() (int, error) {
 	return val, err
 }
 
+// readPackedBools reads n bit-packed booleans (least significant bit first)
+// into the provided array or slice of bools.
+func (dec *Decoder) readPackedBools(rv reflect.Value, n int) error {
+	data, err := dec.ReadNBytes((n + 7) / 8)
+	if err != nil {
+		return fmt.Errorf("packed bools: %w", err)
+	}
+	for i := 0; i < n; i++ {
+		rv.Index(i).SetBool(data[i/8]&(1<<uint(i%8)) != 0)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read packed bools", zap.Int("count", n), zap.Stringer("hex", HexBytes(data)))
+	}
+	return nil
+}
+
+// isByteArrayType returns true if rt is a [N]byte array type
+// that is decoded as its raw bytes.
+func isByteArrayType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Array &&
+		rt.Elem().Kind() == reflect.Uint8 &&
+		!reflect.PtrTo(rt).Implements(unmarshalableType) &&
+		!rt.Implements(unmarshalableType)
+}
+
+// readByteArrays reads the n [N]byte arrays of the provided slice
+// with a single bounds check, instead of decoding each byte through reflection.
+func (dec *Decoder) readByteArrays(rv reflect.Value, n int) error {
+	size := rv.Type().Elem().Len()
+	data, err := dec.ReadNBytes(n * size)
+	if err != nil {
+		return fmt.Errorf("byte arrays: %w", err)
+	}
+	for i := 0; i < n; i++ {
+		reflect.Copy(rv.Index(i), reflect.ValueOf(data[i*size:(i+1)*size]))
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read byte arrays", zap.Int("count", n), zap.Int("size", size))
+	}
+	dec.reportProgress()
+	return nil
+}
+
 func (dec *Decoder) SkipBytes(count uint) error {
 	if uint(dec.Remaining()) < count {
-		return fmt.Errorf("request to skip %d but only %d bytes remain", count, dec.Remaining())
+		return errorf(ErrShortBuffer, "request to skip %d but only %d bytes remain", count, dec.Remaining())
 	}
 	dec.pos += int(count)
 	return nil
 }
 
+// SkipValue advances past a value of type rt, including its length prefixes
+// if it has variable-length parts, without storing it.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) SkipValue(rt reflect.Type) error {
+	if size, ok := dec.fixedSizeOf(rt); ok {
+		return dec.SkipBytes(uint(size))
+	}
+	start := dec.pos
+	if err := dec.Decode(reflect.New(rt).Interface()); err != nil {
+		dec.pos = start
+		return fmt.Errorf("skip %s: %w", rt, err)
+	}
+	return nil
+}
+
+// ReadPadded reads a value stored in a fixed-width slot of width bytes:
+// fn reads the actual value, which cannot extend past the slot,
+// then the decoder advances to the end of the slot,
+// verifying that the skipped padding bytes are all zero.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadPadded(width int, fn func(*Decoder) error) (err error) {
+	if width < 0 {
+		return errorf(ErrInvalidLength, "padded: width not valid: %d", width)
+	}
+	if width > dec.Remaining() {
+		return errorf(ErrShortBuffer, "padded: required [%d] bytes, remaining [%d]", width, dec.Remaining())
+	}
+	start, end := dec.pos, dec.pos+width
+	data := dec.data
+	defer func() {
+		dec.data = data
+		if err != nil {
+			dec.pos = start
+		}
+	}()
+
+	// Hide the bytes after the slot from fn.
+	dec.data = data[:end]
+	if err = fn(dec); err != nil {
+		return fmt.Errorf("padded: %w", err)
+	}
+	for i := dec.pos; i < end; i++ {
+		if data[i] != 0 {
+			return fmt.Errorf("padded: non-zero padding byte 0x%02x at offset %d", data[i], i-start)
+		}
+	}
+	dec.pos = end
+	return nil
+}
+
+// ExpectMagic reads len(magic) bytes and verifies that they match magic,
+// like the signature at the start of many file formats; the error shows
+// the expected and actual bytes in hex, and the offset of the first difference.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ExpectMagic(magic []byte) error {
+	start := dec.pos
+	got, err := dec.ReadNBytes(len(magic))
+	if err != nil {
+		return fmt.Errorf("magic: %w", err)
+	}
+	for i := range magic {
+		if got[i] != magic[i] {
+			dec.pos = start
+			return fmt.Errorf("magic: expected %s, got %s (first difference at offset %d)", hex.EncodeToString(magic), hex.EncodeToString(got), i)
+		}
+	}
+	return nil
+}
+
+// SplitElements reads the length prefix of a slice of elements of type elem,
+// and returns one decoder bounded to each element, so that they can be decoded
+// concurrently, e.g. in separate goroutines; the position of dec is advanced past the slice.
+// The element type must have a fixed, non-zero size (see fixedSizeOf), since the boundaries
+// of variable-size elements cannot be known without decoding them sequentially.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) SplitElements(elem reflect.Type) ([]*Decoder, error) {
+	size, ok := dec.fixedSizeOf(elem)
+	if !ok || (elem.Kind() == reflect.Struct && hasCLayout(elem)) {
+		return nil, fmt.Errorf("split: element type %s doesn't have a fixed size", elem)
+	}
+	if size == 0 {
+		// The count of zero-size elements isn't bounded by the remaining data.
+		return nil, fmt.Errorf("split: element type %s has a zero size", elem)
+	}
+	start := dec.pos
+	count, err := dec.ReadLength()
+	if err != nil {
+		dec.pos = start
+		return nil, fmt.Errorf("split: %w", err)
+	}
+	if count > dec.Remaining()/size {
+		remaining := dec.Remaining()
+		dec.pos = start
+		return nil, errorf(ErrShortBuffer, "split: %d elements of %d bytes exceed the %d remaining bytes", count, size, remaining)
+	}
+
+	out := make([]*Decoder, count)
+	for i := range out {
+		out[i] = dec.subDecoder(dec.data[dec.pos : dec.pos+size])
+		dec.pos += size
+	}
+	return out, nil
+}
+
+// DecodeArrayElement decodes into elem (a pointer) the element at index of an array
+// of fixed-size elements that starts at basePos, without decoding the preceding ones:
+// the element is at basePos + index*size, where size is the FixedSize of the element type.
+// This gives constant-time access into large arrays, e.g. in indexed or columnar formats.
+// The position of the decoder is left unchanged.
+func (dec *Decoder) DecodeArrayElement(basePos uint, index int, elem interface{}) error {
+	rv := reflect.ValueOf(elem)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("array element: expected a non-nil pointer, got %T", elem)
+	}
+	rt := rv.Type().Elem()
+	size, ok := dec.fixedSizeOf(rt)
+	if !ok || (rt.Kind() == reflect.Struct && hasCLayout(rt)) {
+		return fmt.Errorf("array element: element type %s doesn't have a fixed size", rt)
+	}
+	if index < 0 {
+		return errorf(ErrInvalidLength, "array element: negative index %d", index)
+	}
+	if basePos > uint(len(dec.data)) || size > 0 && index > (len(dec.data)-int(basePos))/size-1 {
+		return errorf(ErrShortBuffer, "array element: element %d of %d bytes at %d exceeds the %d bytes of data", index, size, basePos, len(dec.data))
+	}
+	offset := int(basePos) + index*size
+	if err := dec.subDecoder(dec.data[offset : offset+size]).Decode(elem); err != nil {
+		return fmt.Errorf("array element %d: %w", index, err)
+	}
+	return nil
+}
+
 func (dec *Decoder) SetPosition(idx uint) error {
 	if int(idx) < len(dec.data) {
 		dec.pos = int(idx)
@@ -588,10 +1678,134 @@ func (dec *Decoder) Remaining() int {
 	return len(dec.data) - dec.pos
 }
 
+// Progress returns the fraction of the data that has been consumed so far,
+// in the [0, 1] range.
+func (dec *Decoder) Progress() float64 {
+	if len(dec.data) == 0 {
+		return 1
+	}
+	return float64(dec.pos) / float64(len(dec.data))
+}
+
+// WithProgressCallback sets a function that is called with the current position
+// and the total length of the data after each decoded slice element,
+// so that long decodes can report their progress.
+func (dec *Decoder) WithProgressCallback(fn func(pos, total int)) *Decoder {
+	dec.onProgress = fn
+	return dec
+}
+
+// WithEnumNameNormalizer sets a function applied to the decoded type names of
+// named interfaces (see SetNamedInterfaces) and to the decoded string discriminants
+// of unions, before they are looked up in the registry, so that producers that vary
+// the case or the spelling of the names map to the same variant, e.g. with strings.ToLower.
+// The names must be registered in their normalized form.
+func (dec *Decoder) WithEnumNameNormalizer(fn func(string) string) *Decoder {
+	dec.enumNameNormalizer = fn
+	return dec
+}
+
+// WithLenientTrailingOptional makes the decoder treat an optional value whose presence
+// flag is missing because the data ends exactly where it would be as absent, rather than
+// returning an error, for compatibility with the producers that omit trailing absent
+// optionals. The default is strict, since the data may as well be truncated.
+//
+// Unlike the binary_extension tag, which leaves the fields missing at the end of the data
+// untouched, whatever their type, the lenient optionals are set absent (to their zero
+// or default value); a field tagged with both is left untouched.
+func (dec *Decoder) WithLenientTrailingOptional() *Decoder {
+	dec.lenientTrailingOptional = true
+	return dec
+}
+
+// absentAtEOF reports whether an optional value whose presence flag
+// is missing at the end of the data is absent; see WithLenientTrailingOptional.
+func (dec *Decoder) absentAtEOF() bool {
+	return dec.lenientTrailingOptional && !dec.HasRemaining()
+}
+
+// normalizeEnumName applies the normalizer set by WithEnumNameNormalizer, if any.
+func (dec *Decoder) normalizeEnumName(name string) string {
+	if dec.enumNameNormalizer == nil {
+		return name
+	}
+	return dec.enumNameNormalizer(name)
+}
+
+func (dec *Decoder) reportProgress() {
+	if dec.onProgress != nil {
+		dec.onProgress(dec.pos, len(dec.data))
+	}
+}
+
 func (dec *Decoder) HasRemaining() bool {
 	return dec.Remaining() > 0
 }
 
+// String returns a short description of the state of the decoder.
+func (dec *Decoder) String() string {
+	return fmt.Sprintf("Decoder(enc=%s pos=%d/%d remaining=%d)", dec.encoding, dec.pos, len(dec.data), dec.Remaining())
+}
+
+// DumpRemaining returns the hex encoding of up to max of the remaining bytes
+// (all of them if max is negative), followed by the count of the bytes
+// that were left out, if any.
+func (dec *Decoder) DumpRemaining(max int) string {
+	remaining := dec.data[dec.pos:]
+	if max < 0 || max >= len(remaining) {
+		return HexBytes(remaining).String()
+	}
+	return fmt.Sprintf("%s...(+%d bytes)", HexBytes(remaining[:max]), len(remaining)-max)
+}
+
+// DumpContext returns the hex encoding of up to radius bytes before and after
+// the current position, which is marked with a "|", e.g. "(+3 bytes)...0a0b|0c0d...(+5 bytes)".
+// It's meant to give context to decoding errors.
+func (dec *Decoder) DumpContext(radius int) string {
+	from := dec.pos - radius
+	if from < 0 {
+		from = 0
+	}
+	to := dec.pos + radius
+	if to > len(dec.data) {
+		to = len(dec.data)
+	}
+
+	var out strings.Builder
+	if from > 0 {
+		fmt.Fprintf(&out, "(+%d bytes)...", from)
+	}
+	out.WriteString(HexBytes(dec.data[from:dec.pos]).String())
+	out.WriteString("|")
+	out.WriteString(HexBytes(dec.data[dec.pos:to]).String())
+	if to < len(dec.data) {
+		fmt.Fprintf(&out, "...(+%d bytes)", len(dec.data)-to)
+	}
+	return out.String()
+}
+
+// optionalTarget returns the value holding an optional value: when indirect
+// stopped at an unmarshaler, that's the value the unmarshaler points to.
+func optionalTarget(unmarshaler BinaryUnmarshaler, rv reflect.Value) reflect.Value {
+	if unmarshaler != nil {
+		return reflect.ValueOf(unmarshaler).Elem()
+	}
+	return rv
+}
+
+// setAbsentOptional sets the provided optional value to its zero value,
+// or to the provided default (see the default tag), if any.
+func setAbsentOptional(target reflect.Value, def *string) error {
+	if !target.IsValid() || !target.CanSet() {
+		return fmt.Errorf("cannot represent absent optional in non-settable %s value", target.Kind())
+	}
+	if def != nil {
+		return setDefaultValue(target, *def)
+	}
+	target.Set(reflect.Zero(target.Type()))
+	return nil
+}
+
 // indirect walks down v allocating pointers as needed,
 // until it gets to a non-pointer.
 // if it encounters an Unmarshaler, indirect stops and returns that.
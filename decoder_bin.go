@@ -27,7 +27,7 @@ import (
 
 func (dec *Decoder) decodeWithOptionBin(v interface{}, option *option) (err error) {
 	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr {
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &InvalidDecoderError{reflect.TypeOf(v)}
 	}
 
@@ -42,7 +42,7 @@ func (dec *Decoder) decodeWithOptionBin(v interface{}, option *option) (err erro
 
 func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
-		opt = newDefaultOption()
+		opt = newDefaultOption().setOrder(dec.order)
 	}
 	dec.currentFieldOpt = opt
 
@@ -57,23 +57,31 @@ func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 	}
 
 	if opt.isOptional() {
+		target := optionalTarget(unmarshaler, rv)
+		if dec.absentAtEOF() {
+			return setAbsentOptional(target, opt.Default)
+		}
 		isPresent, e := dec.ReadUint32(binary.LittleEndian)
 		if e != nil {
-			err = fmt.Errorf("decode: %s isPresent, %s", rv.Type().String(), e)
+			err = fmt.Errorf("decode: %s isPresent, %w", target.Type(), e)
 			return
 		}
 
 		if isPresent == 0 {
 			if traceEnabled {
-				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
+				zlog.Debug("decode: skipping optional value", zap.Stringer("type", target.Kind()))
 			}
 
-			rv.Set(reflect.Zero(rv.Type()))
-			return
+			return setAbsentOptional(target, opt.Default)
 		}
 
-		// we have ptr here we should not go get the element
-		unmarshaler, rv = indirect(rv, false)
+		if unmarshaler == nil {
+			// we have ptr here we should not go get the element
+			unmarshaler, rv = indirect(rv, false)
+		}
+		if unmarshaler == nil {
+			rv = setOptionalPresent(rv)
+		}
 	}
 
 	if unmarshaler != nil {
@@ -82,11 +90,21 @@ func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 		}
 		return unmarshaler.UnmarshalWithDecoder(dec)
 	}
+	if dec.lengthEncoding == LengthEncodingBincodeVarint && isBincodeVarintInt(rv) {
+		return dec.decodeBincodeVarintInt(rv)
+	}
+
 	rt := rv.Type()
 
 	switch rv.Kind() {
 	case reflect.String:
-		s, e := dec.ReadRustString()
+		var s string
+		var e error
+		if dec.IsBitcoin() {
+			s, e = dec.readString(opt)
+		} else {
+			s, e = dec.readRustString(opt)
+		}
 		if e != nil {
 			err = e
 			return
@@ -149,17 +167,29 @@ func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 		rv.SetBool(r)
 		return
 	case reflect.Interface:
+		if isUnionType(rt) {
+			return dec.decodeInlineUnion(rv, opt.Order)
+		}
+		if dec.namedInterfaces {
+			return dec.decodeNamedInterface(rv, opt.Order)
+		}
 		// skip
 		return nil
 	}
 	switch rt.Kind() {
 	case reflect.Array:
 		length := rt.Len()
+		if err = opt.checkLen(length); err != nil {
+			return
+		}
 		if traceEnabled {
 			zlog.Debug("decoding: reading array", zap.Int("length", length))
 		}
+		if opt.isPacked() && rt.Elem().Kind() == reflect.Bool {
+			return dec.readPackedBools(rv, length)
+		}
 		for i := 0; i < length; i++ {
-			if err = dec.decodeBin(rv.Index(i), nil); err != nil {
+			if err = dec.decodeBin(rv.Index(i), opt.elemOption()); err != nil {
 				return
 			}
 		}
@@ -168,48 +198,95 @@ func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 		var l int
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
-		} else {
-			// TODO: what type is length? Is it really Uvarint64?
-			length, err := dec.ReadUvarint64()
+		} else if opt.hasSVarintLen() {
+			length, err := dec.ReadVarint64()
 			if err != nil {
 				return err
 			}
+			if length < 0 {
+				// A negative length denotes a null slice.
+				rv.Set(reflect.Zero(rt))
+				return nil
+			}
 			l = int(length)
+		} else if opt.hasFill() {
+			length, err := dec.fillCount(rt.Elem())
+			if err != nil {
+				return err
+			}
+			l = length
+		} else if opt.hasByteLenFixed() {
+			length, err := dec.readByteLenFixedCount(rt.Elem())
+			if err != nil {
+				return err
+			}
+			l = length
+		} else {
+			length, err := dec.ReadLength()
+			if err != nil {
+				return err
+			}
+			l = length
+		}
+
+		if err = opt.checkLen(l); err != nil {
+			return
 		}
 
 		if traceEnabled {
 			zlog.Debug("reading slice", zap.Int("len", l), typeField("type", rv))
 		}
 
+		if dec.allocator != nil && isByteSliceType(rt) {
+			return dec.readAllocatedBytes(rv, l)
+		}
 		rv.Set(reflect.MakeSlice(rt, l, l))
+		if opt.isPacked() && rt.Elem().Kind() == reflect.Bool {
+			return dec.readPackedBools(rv, l)
+		}
+		if isByteArrayType(rt.Elem()) {
+			return dec.readByteArrays(rv, l)
+		}
 		for i := 0; i < l; i++ {
-			if err = dec.decodeBin(rv.Index(i), nil); err != nil {
+			if err = dec.decodeBin(rv.Index(i), opt.elemOption()); err != nil {
 				return
 			}
+			dec.reportProgress()
 		}
 
 	case reflect.Struct:
-		if err = dec.decodeStructBin(rt, rv); err != nil {
+		if err = dec.decodeStructBin(rt, rv, opt.Order); err != nil {
 			return
 		}
 
 	case reflect.Map:
-		// TODO: what type is length? Is it really Uvarint64?
-		l, err := dec.ReadUvarint64()
+		l, err := dec.ReadLength()
 		if err != nil {
 			return err
 		}
+		if err = opt.checkLen(l); err != nil {
+			return err
+		}
 		if l == 0 {
 			// If the map has no content, keep it nil.
 			return nil
 		}
 		rv.Set(reflect.MakeMap(rt))
+		var prevKey reflect.Value
 		for i := 0; i < int(l); i++ {
 			key := reflect.New(rt.Key())
 			err := dec.decodeBin(key.Elem(), nil)
 			if err != nil {
 				return err
 			}
+			if dec.strictMapOrder {
+				if i > 0 {
+					if err = checkMapKeyOrder(prevKey, key.Elem()); err != nil {
+						return err
+					}
+				}
+				prevKey = key.Elem()
+			}
 			val := reflect.New(rt.Elem())
 			err = dec.decodeBin(val.Elem(), nil)
 			if err != nil {
@@ -220,22 +297,57 @@ func (dec *Decoder) decodeBin(rv reflect.Value, opt *option) (err error) {
 		return nil
 
 	default:
-		return fmt.Errorf("decode: unsupported type %q", rt)
+		return errorf(ErrUnsupportedType, "decode: unsupported type %q", rt)
 	}
 
 	return
 }
 
-func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value) (err error) {
+// The fields without a byte order tag are decoded with order, the byte order
+// of the enclosing struct field (or of the decoder at the top level).
+func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value, order binary.ByteOrder) (err error) {
 	l := rv.NumField()
 
 	if traceEnabled {
 		zlog.Debug("decode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
 	}
 
+	if dec.canDecodeFlat() && rv.CanSet() {
+		if plan := flatStructOf(rt); plan != nil {
+			return dec.decodeFlatStruct(rv, plan, order)
+		}
+	}
+
+	pathDepth := len(dec.path)
+	defer func() { dec.path = dec.path[:pathDepth] }()
+
+	cLayout := dec.cLayout || hasCLayout(rt)
+	start := dec.pos
+
+	var presence *presenceBitmap
+	if hasPresenceBitmap(rt) {
+		if presence, err = dec.readPresenceBitmap(rt); err != nil {
+			return err
+		}
+	}
+
+	// remainingFields is the number of fields left to decode
+	// in a struct with a field count, or -1.
+	remainingFields := -1
+	if hasFieldCount(rt) {
+		if remainingFields, err = dec.readFieldCount(rt); err != nil {
+			return err
+		}
+	}
+
+	fieldOrder, err := wireFieldOrder(rt)
+	if err != nil {
+		return err
+	}
+
 	sizeOfMap := map[string]int{}
 	seenBinaryExtensionField := false
-	for i := 0; i < l; i++ {
+	for _, i := range fieldOrder {
 		structField := rt.Field(i)
 		fieldTag := parseFieldTag(structField.Tag)
 
@@ -248,6 +360,47 @@ func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value) (err erro
 			continue
 		}
 
+		if fieldTag.Unknown {
+			// The unknown bytes are decoded after the known fields.
+			continue
+		}
+
+		if presence != nil {
+			if i == 0 {
+				// The bitmap marker itself.
+				continue
+			}
+			if !presence.nextPresent() {
+				if v := rv.Field(i); v.CanSet() {
+					v.Set(reflect.Zero(v.Type()))
+				}
+				continue
+			}
+		}
+
+		if remainingFields >= 0 && structField.PkgPath == "" {
+			if remainingFields == 0 {
+				if v := rv.Field(i); v.CanSet() {
+					v.Set(reflect.Zero(v.Type()))
+				}
+				continue
+			}
+			remainingFields--
+		}
+
+		if fieldTag.If != "" {
+			present, err := evalFieldCondition(rv, i, fieldTag.If)
+			if err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+			if !present {
+				if v := rv.Field(i); v.CanSet() {
+					v.Set(reflect.Zero(v.Type()))
+				}
+				continue
+			}
+		}
+
 		if !fieldTag.BinaryExtension && seenBinaryExtensionField {
 			panic(fmt.Sprintf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.Name))
 		}
@@ -294,7 +447,22 @@ func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value) (err erro
 
 		option := &option{
 			OptionalField: fieldTag.Optional,
-			Order:         fieldTag.Order,
+			Default:       fieldTag.Default,
+			Order:         fieldTag.orderOr(order),
+			Packed:        fieldTag.Packed,
+			SVarintLen:    fieldTag.SVarintLen,
+			ByteLenFixed:  fieldTag.ByteLenFixed,
+			Fill:          fieldTag.Fill,
+			MinLen:        fieldTag.MinLen,
+			MaxLen:        fieldTag.MaxLen,
+		}
+
+		if fieldTag.PopcountLen != "" {
+			var n int
+			if n, err = popcountLen(rv, i, fieldTag.PopcountLen); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+			sizeOfMap[structField.Name] = n
 		}
 
 		if s, ok := sizeOfMap[structField.Name]; ok {
@@ -310,7 +478,94 @@ func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value) (err erro
 			)
 		}
 
-		if err = dec.decodeBin(v, option); err != nil {
+		dec.path = append(dec.path[:pathDepth], structField.Name)
+		// The previous field ends before the padding of this one.
+		dec.endSpan(pathDepth)
+
+		if cLayout {
+			if err = dec.skipCPadding(start, structField.Type); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		}
+
+		if dec.fieldHook != nil {
+			dec.fieldHook(dec.FieldPath(), structField.Type, dec.pos)
+		}
+		dec.startSpan(pathDepth, structField.Name)
+
+		if fieldTag.UnionTag != "" {
+			if err = dec.decodeUnionField(rt, rv, i, fieldTag.UnionTag, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Codec != "" {
+			if err = dec.decodeWithFieldCodec(fieldTag.Codec, v); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Rest {
+			if err = dec.decodeRestField(rt, i, v, option); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.FillRest {
+			if err = dec.decodeFillRestField(rt, i, v, option); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.TrailerLen {
+			if err = dec.decodeTrailerLenField(rt, i, v, option); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Continuation != "" {
+			if err = dec.decodeContinuation(v, fieldTag.Continuation, option); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.U24 {
+			if err = dec.decodeU24(v, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.IntN != 0 {
+			if err = dec.decodeIntN(v, fieldTag.IntN, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Q != nil {
+			if err = dec.decodeQ(v, fieldTag.Q, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.NoneIf != nil {
+			if err = dec.decodeNoneIf(v, *fieldTag.NoneIf, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.GUID {
+			if err = dec.decodeGUID(v); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.TriBool {
+			if err = dec.decodeTriBool(v); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.RFC3339 {
+			if err = dec.decodeRFC3339(v); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.BigFloat {
+			if err = dec.decodeBigFloat(v); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.ASCIIStr {
+			if err = dec.decodeASCIIStr(v, fieldTag.ASCIIStrLen); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.FixedInner != 0 {
+			if err = dec.decodeFixedInner(v, fieldTag.FixedInner); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Lazy != 0 {
+			if err = dec.decodeLazyNumber(v, fieldTag.Lazy, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if fieldTag.Runes != "" {
+			if err = dec.decodeRunes(v, fieldTag.Runes, option.Order); err != nil {
+				return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+			}
+		} else if err = dec.decodeBin(v, option); err != nil {
 			return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
 		}
 
@@ -325,5 +580,14 @@ func (dec *Decoder) decodeStructBin(rt reflect.Type, rv reflect.Value) (err erro
 			sizeOfMap[fieldTag.SizeOf] = size
 		}
 	}
-	return
+
+	dec.endSpan(pathDepth)
+
+	if cLayout {
+		// Trailing padding, up to the alignment of the struct itself.
+		if err = dec.skipCPadding(start, rt); err != nil {
+			return err
+		}
+	}
+	return dec.decodeUnknownField(rt, rv)
 }
@@ -18,10 +18,16 @@
 package bin
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -737,6 +743,88 @@ func TestDecoder_BinaryTestStructWithTags(t *testing.T) {
 	assert.Equal(t, i, s.F11)
 }
 
+func TestDecoder_PeekTail(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x01, 0x02, 0x03, 0x04})
+	tail, err := dec.PeekTail(2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x04}, tail)
+
+	tag, err := dec.PeekTailByte()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x04), tag)
+	assert.Equal(t, 4, dec.Remaining())
+
+	require.NoError(t, dec.SkipBytes(3))
+	_, err = dec.PeekTail(2)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	require.NoError(t, dec.SkipBytes(1))
+	_, err = dec.PeekTailByte()
+	assert.Error(t, err)
+}
+
+func TestDecoder_ReadOptionalByteSlice(t *testing.T) {
+	dec := NewBorshDecoder([]byte{
+		0x00,
+		0x01, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x02, 0x00, 0x00, 0x00, 0xaa, 0xbb,
+	})
+
+	out, present, err := dec.ReadOptionalByteSlice()
+	require.NoError(t, err)
+	assert.False(t, present)
+	assert.Nil(t, out)
+
+	out, present, err = dec.ReadOptionalByteSlice()
+	require.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, []byte{}, out)
+
+	out, present, err = dec.ReadOptionalByteSlice()
+	require.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, []byte{0xaa, 0xbb}, out)
+
+	out, present, err = NewBinDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x01, 0xcc}).ReadOptionalByteSlice()
+	require.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, []byte{0xcc}, out)
+
+	_, _, err = NewBorshDecoder(nil).ReadOptionalByteSlice()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
+
+func TestDecoder_Reset(t *testing.T) {
+	data := []byte{0x00, 0x01, 0x02, 0x00, 0x00, 0x00, 'a', 'b'}
+	dec := NewBinDecoderWithOrder(data, binary.BigEndian)
+	dec.SetMaxStringLen(1)
+	dec.SetLengthEncoding(LengthEncodingU32)
+	dec.SetFloatPolicy(FloatPolicy{RejectInf: true})
+	dec.SetNamedInterfaces(true)
+	dec.WithProgressCallback(func(pos, total int) {})
+	dec.WithEnumNameNormalizer(strings.ToLower)
+	dec.WithStrictMapOrder()
+	dec.WithLenientTrailingOptional()
+
+	n, err := dec.ReadUint16(dec.order)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(1), n)
+
+	dec.Reset()
+	assert.Equal(t, 0, int(dec.Position()))
+	assert.Equal(t, EncodingBin, dec.Encoding())
+	assert.Equal(t, NewBinDecoderWithOrder(data, binary.BigEndian), dec)
+
+	var m uint16
+	require.NoError(t, dec.Decode(&m))
+	assert.Equal(t, uint16(0x0001), m)
+
+	dec = NewBinDecoder(data)
+	dec.SetNamedInterfaces(true)
+	dec.Reset()
+	assert.Equal(t, NewBinDecoder(data), dec)
+}
+
 func TestDecoder_SkipBytes(t *testing.T) {
 	buf := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
 	decoder := NewBinDecoder(buf)
@@ -756,3 +844,2410 @@ func TestDecoder_SkipBytes(t *testing.T) {
 	require.Equal(t, 0, decoder.Remaining())
 
 }
+
+func TestDecoder_SkipValue(t *testing.T) {
+	type record struct {
+		ID   uint16
+		Name string
+		Tags []uint32
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf)
+	require.NoError(t, enc.Encode(record{ID: 1, Name: "abc", Tags: []uint32{1, 2}}))
+	require.NoError(t, enc.Encode(uint64(7)))
+	require.NoError(t, enc.Encode(uint8(42)))
+
+	decoder := NewBorshDecoder(buf.Bytes())
+	require.NoError(t, decoder.SkipValue(reflect.TypeOf(record{})))
+	require.NoError(t, decoder.SkipValue(reflect.TypeOf(uint64(0))))
+	last, err := decoder.ReadUint8()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(42), last)
+
+	decoder = NewBorshDecoder([]byte{0x01, 0x00, 0x05, 0x00, 0x00, 0x00, 'a'})
+	err = decoder.SkipValue(reflect.TypeOf(record{}))
+	require.Error(t, err)
+	assert.Equal(t, 0, int(decoder.Position()))
+}
+
+func TestDecoder_ReadPadded(t *testing.T) {
+	data := []byte{
+		0x02, 0x00, 0x00, 0x00, 'h', 'i', 0x00, 0x00, // 8-byte slot
+		0x2a,
+	}
+
+	var name string
+	decoder := NewBorshDecoder(data)
+	require.NoError(t, decoder.ReadPadded(8, func(dec *Decoder) (err error) {
+		name, err = dec.ReadString()
+		return err
+	}))
+	assert.Equal(t, "hi", name)
+	assert.Equal(t, 8, int(decoder.Position()))
+	last, err := decoder.ReadUint8()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(42), last)
+
+	// The value cannot extend past the slot.
+	decoder = NewBorshDecoder(data)
+	err = decoder.ReadPadded(5, func(dec *Decoder) error {
+		_, err := dec.ReadString()
+		return err
+	})
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 0, int(decoder.Position()))
+
+	decoder = NewBorshDecoder(data)
+	err = decoder.ReadPadded(9, func(dec *Decoder) error {
+		_, err := dec.ReadString()
+		return err
+	})
+	assert.EqualError(t, err, "padded: non-zero padding byte 0x2a at offset 8")
+	assert.Equal(t, 0, int(decoder.Position()))
+
+	err = NewBorshDecoder(data).ReadPadded(10, func(dec *Decoder) error { return nil })
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
+
+func TestDecoder_ExpectMagic(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G'}
+
+	dec := NewBinDecoder([]byte{0x89, 'P', 'N', 'G', 0x01})
+	require.NoError(t, dec.ExpectMagic(png))
+	assert.Equal(t, 4, int(dec.Position()))
+
+	dec = NewBinDecoder([]byte{0x89, 'P', 'N', 'J', 0x01})
+	err := dec.ExpectMagic(png)
+	assert.EqualError(t, err, "magic: expected 89504e47, got 89504e4a (first difference at offset 3)")
+	assert.Equal(t, 0, int(dec.Position()))
+
+	dec = NewBinDecoder([]byte{0x89, 'P'})
+	err = dec.ExpectMagic(png)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 0, int(dec.Position()))
+}
+
+func TestDecoder_OrderIndex(t *testing.T) {
+	type reordered struct {
+		C uint64 `bin:"order_index=2"`
+		A uint8  `bin:"order_index=0"`
+		B string `bin:"order_index=1"`
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			in := reordered{A: 1, B: "b", C: 3}
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			decoder := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			a, err := decoder.ReadUint8()
+			require.NoError(t, err)
+			assert.Equal(t, uint8(1), a)
+
+			var out reordered
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+
+	type duplicate struct {
+		A uint8 `bin:"order_index=1"`
+		B uint8 `bin:"order_index=1"`
+	}
+	var out duplicate
+	err := NewBinDecoder([]byte{0x01, 0x02}).Decode(&out)
+	assert.EqualError(t, err, `order_index: "A" and "B" fields have the same wire position 1`)
+}
+
+func TestDecoder_DecodeMulti(t *testing.T) {
+	var (
+		a uint8
+		b uint16
+		c string
+	)
+	decoder := NewBorshDecoder([]byte{0x01, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 'c'})
+	require.NoError(t, decoder.DecodeMulti(&a, &b, &c))
+	assert.Equal(t, uint8(1), a)
+	assert.Equal(t, uint16(2), b)
+	assert.Equal(t, "c", c)
+
+	decoder = NewBorshDecoder([]byte{0x01, 0x02, 0x00, 0x05})
+	err := decoder.DecodeMulti(&a, &b, &c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decode value 2 (*string)")
+	assert.True(t, decoder.Position() >= 3)
+}
+
+func TestDecoder_SetFieldHook(t *testing.T) {
+	type inner struct {
+		A uint8
+		B uint16
+	}
+	type outer struct {
+		ID     uint32
+		Name   string
+		Inner  inner
+		hidden uint8
+		Skip   uint8 `bin:"-"`
+	}
+
+	type event struct {
+		path  string
+		rt    reflect.Type
+		start int
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(outer{ID: 1, Name: "ab", Inner: inner{A: 2, B: 3}}))
+
+			var events []event
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			dec.SetFieldHook(func(path []string, rt reflect.Type, startPos int) {
+				events = append(events, event{strings.Join(path, "."), rt, startPos})
+			})
+			var out outer
+			require.NoError(t, dec.Decode(&out))
+
+			nameEnd := buf.Len() - 3
+			assert.Equal(t, []event{
+				{"ID", reflect.TypeOf(uint32(0)), 0},
+				{"Name", reflect.TypeOf(""), 4},
+				// inner is a flat struct: its fields are reported too.
+				{"Inner", reflect.TypeOf(inner{}), nameEnd},
+				{"Inner.A", reflect.TypeOf(uint8(0)), nameEnd},
+				{"Inner.B", reflect.TypeOf(uint16(0)), nameEnd + 1},
+			}, events)
+
+			// Decoding a flat struct directly reports its own fields.
+			events = nil
+			dec = NewDecoderWithEncoding([]byte{0x02, 0x03, 0x00}, encoding)
+			dec.SetFieldHook(func(path []string, rt reflect.Type, startPos int) {
+				events = append(events, event{strings.Join(path, "."), rt, startPos})
+			})
+			var flat inner
+			require.NoError(t, dec.Decode(&flat))
+			assert.Equal(t, inner{A: 2, B: 3}, flat)
+			assert.Equal(t, []event{
+				{"A", reflect.TypeOf(uint8(0)), 0},
+				{"B", reflect.TypeOf(uint16(0)), 1},
+			}, events)
+		})
+	}
+}
+
+type halfUnmarshaler struct{}
+
+func (halfUnmarshaler) UnmarshalWithDecoder(dec *Decoder) error {
+	if _, err := dec.ReadUint16(LE); err != nil {
+		return err
+	}
+	return errors.New("half: unexpected value")
+}
+
+func TestDecoder_DecodeTx(t *testing.T) {
+	decoder := NewBorshDecoder([]byte{0x01, 0x02, 0x03, 0x04})
+	_, err := decoder.ReadUint8()
+	require.NoError(t, err)
+
+	err = decoder.DecodeTx(&halfUnmarshaler{})
+	assert.EqualError(t, err, "half: unexpected value")
+	assert.Equal(t, 1, int(decoder.Position()))
+
+	var retry [3]byte
+	require.NoError(t, decoder.DecodeTx(&retry))
+	assert.Equal(t, [3]byte{0x02, 0x03, 0x04}, retry)
+	assert.False(t, decoder.HasRemaining())
+}
+
+func TestDecoder_DecodeThen(t *testing.T) {
+	type header struct {
+		Kind uint8
+	}
+
+	data := []byte{
+		0x02,                   // header
+		0x2a, 0x00, 0x00, 0x00, // inner of kind 2: uint32
+	}
+	decoder := NewBorshDecoder(data)
+	decoder.SetMaxStringLen(8)
+
+	var outer header
+	inner, err := decoder.DecodeThen(&outer)
+	require.NoError(t, err)
+	assert.Equal(t, header{Kind: 2}, outer)
+	assert.Equal(t, 1, int(decoder.Position()))
+	assert.Equal(t, 4, inner.Remaining())
+	assert.Equal(t, 8, inner.maxStringLen)
+
+	require.Equal(t, uint8(2), outer.Kind)
+	var value uint32
+	require.NoError(t, inner.Decode(&value))
+	assert.Equal(t, uint32(42), value)
+
+	var big struct {
+		A uint64
+	}
+	inner, err = NewBorshDecoder(data[:3]).DecodeThen(&big)
+	assert.Nil(t, inner)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
+
+func TestDecoder_FieldCount(t *testing.T) {
+	type recordV1 struct {
+		_  struct{} `bin:"field_count"`
+		ID uint32
+	}
+	type recordV2 struct {
+		_     struct{} `bin:"field_count"`
+		ID    uint32
+		Label string
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(recordV1{ID: 7}))
+
+			out := recordV2{Label: "stale"}
+			decoder := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, decoder.Decode(&out))
+			assert.Equal(t, recordV2{ID: 7}, out)
+			assert.Equal(t, 0, decoder.Remaining())
+
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(recordV2{ID: 8, Label: "b"}))
+
+			out = recordV2{}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, recordV2{ID: 8, Label: "b"}, out)
+
+			var old recordV1
+			err := NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&old)
+			assert.EqualError(t, err, "field_count: 2 fields present but bin.recordV1 only has 1")
+		})
+	}
+}
+
+func TestDecoder_SplitElements(t *testing.T) {
+	type point struct {
+		X, Y int32
+	}
+	in := make([]point, 100)
+	for i := range in {
+		in[i] = point{X: int32(i), Y: -int32(i)}
+	}
+	data, err := MarshalBorsh(append(in, point{}))
+	require.NoError(t, err)
+	data = append(data, 0xff)
+
+	dec := NewBorshDecoder(data)
+	decoders, err := dec.SplitElements(reflect.TypeOf(point{}))
+	require.NoError(t, err)
+	require.Len(t, decoders, len(in)+1)
+	assert.Equal(t, 1, dec.Remaining())
+
+	out := make([]point, len(decoders))
+	errs := make(chan error, len(decoders))
+	for i, elemDec := range decoders {
+		go func(i int, elemDec *Decoder) {
+			errs <- elemDec.Decode(&out[i])
+		}(i, elemDec)
+	}
+	for range decoders {
+		require.NoError(t, <-errs)
+	}
+	assert.Equal(t, append(in, point{}), out)
+
+	_, err = NewBorshDecoder(data).SplitElements(reflect.TypeOf(""))
+	assert.EqualError(t, err, "split: element type string doesn't have a fixed size")
+
+	_, err = NewBorshDecoder([]byte{0xff, 0xff, 0xff, 0xff}).SplitElements(reflect.TypeOf(struct{}{}))
+	assert.EqualError(t, err, "split: element type struct {} has a zero size")
+
+	short := NewBorshDecoder([]byte{0x02, 0x00, 0x00, 0x00, 0x01})
+	_, err = short.SplitElements(reflect.TypeOf(point{}))
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 0, int(short.Position()))
+}
+
+func TestDecoder_DecodeArrayElement(t *testing.T) {
+	type point struct {
+		X, Y int32
+	}
+	var in [100]point
+	for i := range in {
+		in[i] = point{X: int32(i), Y: -int32(i)}
+	}
+	data, err := MarshalBorsh(struct {
+		Magic  uint16
+		Points [100]point
+	}{Magic: 0xcafe, Points: in})
+	require.NoError(t, err)
+
+	dec := NewBorshDecoder(data)
+	for _, index := range []int{0, 42, 99} {
+		var out point
+		require.NoError(t, dec.DecodeArrayElement(2, index, &out))
+		assert.Equal(t, in[index], out)
+	}
+	assert.Equal(t, 0, int(dec.Position()))
+
+	var out point
+	err = dec.DecodeArrayElement(2, 100, &out)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	err = dec.DecodeArrayElement(2, -1, &out)
+	assert.EqualError(t, err, "array element: negative index -1")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	// A base position that overflows an int is out of the data.
+	err = dec.DecodeArrayElement(math.MaxUint64, 0, &out)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	var str string
+	err = dec.DecodeArrayElement(2, 0, &str)
+	assert.EqualError(t, err, "array element: element type string doesn't have a fixed size")
+	err = dec.DecodeArrayElement(2, 0, out)
+	assert.EqualError(t, err, "array element: expected a non-nil pointer, got bin.point")
+}
+
+func TestDecoder_ReadLenPrefixedAt(t *testing.T) {
+	// An offset table of two u32, then the blobs.
+	data := []byte{
+		0x08, 0x00, 0x00, 0x00,
+		0x0e, 0x00, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x00, 0xaa, 0xbb,
+		0x01, 0x00, 0x00, 0x00, 0xcc,
+	}
+	dec := NewBorshDecoder(data)
+	first, err := dec.ReadUint32(LE)
+	require.NoError(t, err)
+
+	blob, err := dec.ReadLenPrefixedAt(uint(first))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xaa, 0xbb}, blob)
+	assert.Equal(t, 4, int(dec.Position()))
+
+	second, err := dec.ReadUint32(LE)
+	require.NoError(t, err)
+	blob, err = dec.ReadLenPrefixedAt(uint(second))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xcc}, blob)
+	assert.Equal(t, 8, int(dec.Position()))
+
+	_, err = dec.ReadLenPrefixedAt(16)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	_, err = dec.ReadLenPrefixedAt(100)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 8, int(dec.Position()))
+
+	peek, err := dec.PeekAt(12, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xaa, 0xbb}, peek)
+	_, err = dec.PeekAt(18, 2)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
+
+func TestDecoder_LenBounds(t *testing.T) {
+	type signed struct {
+		Signatures [][4]byte `bin:"minlen=1"`
+		Memo       string    `bin:"maxlen=3"`
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			in := signed{Signatures: [][4]byte{{1, 2, 3, 4}}, Memo: "abc"}
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			var out signed
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(signed{Memo: "abc"}))
+			out = signed{}
+			err := NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out)
+			assert.EqualError(t, err, `error while decoding "Signatures" field: minlen: length 0 is less than 1`)
+			assert.True(t, errors.Is(err, ErrInvalidLength))
+
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(signed{Signatures: in.Signatures, Memo: "abcd"}))
+			err = NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out)
+			assert.EqualError(t, err, `error while decoding "Memo" field: maxlen: length 4 is greater than 3`)
+
+			// The length is rejected before the elements are read.
+			type bounded struct {
+				Items  []uint64        `bin:"maxlen=2"`
+				Counts map[uint8]uint8 `bin:"maxlen=1"`
+			}
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).WriteLength(1000))
+			err = NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&bounded{})
+			assert.EqualError(t, err, `error while decoding "Items" field: maxlen: length 1000 is greater than 2`)
+			buf.Reset()
+			enc := NewEncoderWithEncoding(buf, encoding)
+			require.NoError(t, enc.WriteLength(0))
+			require.NoError(t, enc.WriteLength(1000))
+			err = NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&bounded{})
+			assert.EqualError(t, err, `error while decoding "Counts" field: maxlen: length 1000 is greater than 1`)
+
+			type records struct {
+				Records []uint16 `bin:"fill_rest maxlen=2"`
+			}
+			err = NewDecoderWithEncoding([]byte{1, 0, 2, 0, 3, 0}, encoding).Decode(&records{})
+			assert.EqualError(t, err, `error while decoding "Records" field: maxlen: length 3 is greater than 2`)
+
+			// The length of a string is rejected before its bytes are read.
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(signed{Signatures: in.Signatures, Memo: strings.Repeat("a", 1000)}))
+			err = NewDecoderWithEncoding(buf.Bytes()[:buf.Len()-1000], encoding).Decode(&out)
+			assert.EqualError(t, err, `error while decoding "Memo" field: maxlen: length 1000 is greater than 3`)
+
+			var invalid struct {
+				Memo string `bin:"maxlen=x"`
+			}
+			err = NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&invalid)
+			assert.EqualError(t, err, `error while decoding "Memo" field: maxlen: invalid bound, expected a non-negative integer`)
+		})
+	}
+}
+
+func TestDecoder_PackedBools(t *testing.T) {
+	type packedBools struct {
+		Arr      [10]bool `bin:"packed"`
+		Slice    []bool   `bin:"packed"`
+		Unpacked [2]bool
+	}
+
+	in := packedBools{
+		Arr:      [10]bool{true, false, true, false, false, false, false, true, false, true},
+		Slice:    []bool{false, true, true},
+		Unpacked: [2]bool{true, true},
+	}
+	expected := []byte{
+		0x85, 0x02, // Arr
+		0x03, 0x06, // Slice (len=3)
+		0x01, 0x01, // Unpacked
+	}
+
+	data, err := MarshalBin(in)
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+
+	var out packedBools
+	require.NoError(t, UnmarshalBin(&out, data))
+	assert.Equal(t, in, out)
+
+	data, err = MarshalBorsh(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x85, 0x02, 0x03, 0x00, 0x00, 0x00, 0x06, 0x01, 0x01}, data)
+
+	out = packedBools{}
+	require.NoError(t, UnmarshalBorsh(&out, data))
+	assert.Equal(t, in, out)
+
+	err = UnmarshalBin(&out, []byte{0x85})
+	assert.Error(t, err)
+}
+
+func TestDecoder_ReadJSON(t *testing.T) {
+	type doc struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf)
+	require.NoError(t, enc.WriteJSON(doc{Name: "abc", Count: 3}))
+	require.NoError(t, enc.WriteUint8(0xff))
+
+	payload := `{"name":"abc","count":3}`
+	expected := append([]byte{byte(len(payload)), 0x00, 0x00, 0x00}, payload...)
+	expected = append(expected, 0xff)
+	assert.Equal(t, expected, buf.Bytes())
+
+	dec := NewBorshDecoder(buf.Bytes())
+	var out doc
+	require.NoError(t, dec.ReadJSON(&out))
+	assert.Equal(t, doc{Name: "abc", Count: 3}, out)
+	assert.Equal(t, 1, dec.Remaining())
+
+	err := NewBinDecoder([]byte{0x02, '{', '{'}).ReadJSON(&out)
+	assert.Error(t, err)
+}
+
+func TestDecoder_WithOrder(t *testing.T) {
+	type orderTest struct {
+		A uint16
+		B uint32 `bin:"little"`
+		C []int16
+	}
+
+	buf := []byte{
+		0x00, 0x01,
+		0x02, 0x00, 0x00, 0x00,
+		0x02, 0xff, 0xfe, 0x00, 0x03,
+	}
+
+	var out orderTest
+	require.NoError(t, NewBinDecoderWithOrder(buf, BE).Decode(&out))
+	assert.Equal(t, orderTest{A: 1, B: 2, C: []int16{-2, 3}}, out)
+
+	var top uint32
+	require.NoError(t, NewBinDecoderWithOrder([]byte{0x00, 0x00, 0x00, 0x05}, BE).Decode(&top))
+	assert.Equal(t, uint32(5), top)
+
+	encoded := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoderWithOrder(encoded, BE).Encode(out))
+	assert.Equal(t, buf, encoded.Bytes())
+}
+
+func TestDecoder_SVarintLenSlice(t *testing.T) {
+	type svarintLen struct {
+		Null  []uint8 `bin:"svarint_len"`
+		Empty []uint8 `bin:"svarint_len"`
+		Full  []uint8 `bin:"svarint_len"`
+	}
+
+	in := svarintLen{
+		Null:  nil,
+		Empty: []uint8{},
+		Full:  []uint8{7, 8},
+	}
+	expected := []byte{
+		0x01,             // -1 (null)
+		0x00,             // 0 (empty)
+		0x04, 0x07, 0x08, // 2
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out svarintLen
+			require.NoError(t, NewDecoderWithEncoding(expected, encoding).Decode(&out))
+			assert.Nil(t, out.Null)
+			assert.NotNil(t, out.Empty)
+			assert.Len(t, out.Empty, 0)
+			assert.Equal(t, []uint8{7, 8}, out.Full)
+		})
+	}
+}
+
+func TestDecoder_ReadVarintN(t *testing.T) {
+	var buf []byte
+	for _, v := range []int64{math.MaxInt32, math.MinInt32, math.MaxInt32 + 1} {
+		tmp := make([]byte, binary.MaxVarintLen64)
+		buf = append(buf, tmp[:binary.PutVarint(tmp, v)]...)
+	}
+
+	decoder := NewBinDecoder(buf)
+	n, err := decoder.ReadVarintN(32)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt32), n)
+
+	n, err = decoder.ReadVarintN(32)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt32), n)
+
+	pos := decoder.Position()
+	_, err = decoder.ReadVarintN(32)
+	assert.EqualError(t, err, "varint: value 2147483648 overflows 32 bits")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	assert.Equal(t, pos, decoder.Position())
+
+	n, err = decoder.ReadVarintN(64)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt32+1), n)
+
+	_, err = decoder.ReadVarintN(0)
+	assert.Error(t, err)
+}
+
+func TestDecoder_UvarintBE(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{300, []byte{0x82, 0x2c}},
+		{math.MaxUint64, []byte{0x81, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteUVarIntBE(test.value))
+		assert.Equal(t, test.encoded, buf.Bytes())
+
+		dec := NewBinDecoder(test.encoded)
+		out, err := dec.ReadUvarintBE()
+		require.NoError(t, err)
+		assert.Equal(t, test.value, out)
+		assert.Equal(t, 0, dec.Remaining())
+	}
+
+	_, err := NewBinDecoder([]byte{0x81, 0x80}).ReadUvarintBE()
+	assert.Equal(t, ErrVarIntBufferSize, err)
+
+	_, err = NewBinDecoder([]byte{0x82, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}).ReadUvarintBE()
+	assert.EqualError(t, err, "varint BE: overflows a 64-bit integer")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+}
+
+func TestDecoder_ReadBytesInto(t *testing.T) {
+	dec := NewBinDecoder([]byte{1, 2, 3, 4, 5})
+
+	dst := make([]byte, 3)
+	n, err := dec.ReadBytesInto(dst)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []byte{1, 2, 3}, dst)
+	assert.Equal(t, 2, dec.Remaining())
+
+	n, err = dec.ReadBytesInto(dst)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 2, dec.Remaining())
+
+	n, err = dec.ReadBytesInto(dst[:2])
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{4, 5, 3}, dst)
+	assert.False(t, dec.HasRemaining())
+}
+
+func TestDecoder_ReadNBytes(t *testing.T) {
+	dec := NewBinDecoder([]byte{1, 2, 3})
+
+	_, err := dec.ReadNBytes(-1)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	_, err = dec.ReadNBytes(math.MaxInt32)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 3, dec.Remaining())
+
+	data, err := dec.ReadNBytes(3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, data)
+}
+
+func TestDecoder_ReadUvarint64Slice(t *testing.T) {
+	buf := []byte{
+		0x01,
+		0xac, 0x02, // 300
+		0x00,
+		0x05,
+	}
+
+	dec := NewBinDecoder(buf)
+	out, err := dec.ReadUvarint64Slice(3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 300, 0}, out)
+	assert.Equal(t, 1, dec.Remaining())
+
+	dec = NewBinDecoder(buf)
+	out, err = dec.ReadDeltaUvarint64Slice(4)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 301, 301, 306}, out)
+	assert.False(t, dec.HasRemaining())
+
+	_, err = NewBinDecoder(buf).ReadUvarint64Slice(6)
+	assert.Error(t, err)
+
+	_, err = NewBinDecoder([]byte{0x01, 0x80}).ReadUvarint64Slice(2)
+	assert.Error(t, err)
+}
+
+func TestDecoder_ReadVarint64Slice(t *testing.T) {
+	buf := []byte{
+		0x14,       // 10
+		0x03,       // -2
+		0xd7, 0x04, // -300
+		0x00,
+		0x01, // -1
+	}
+
+	dec := NewBinDecoder(buf)
+	out, err := dec.ReadVarint64Slice(3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, -2, -300}, out)
+	assert.Equal(t, 2, dec.Remaining())
+
+	dec = NewBinDecoder(buf)
+	out, err = dec.ReadDeltaVarint64Slice(5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 8, -292, -292, -293}, out)
+	assert.False(t, dec.HasRemaining())
+
+	_, err = NewBinDecoder(buf).ReadVarint64Slice(7)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	_, err = NewBinDecoder([]byte{0x01, 0x80}).ReadDeltaVarint64Slice(2)
+	assert.Error(t, err)
+}
+
+func TestDecoder_OptionalDefault(t *testing.T) {
+	type config struct {
+		Retries uint8          `bin:"optional default=3"`
+		Offset  int16          `bin:"optional default=-0x10"`
+		Enabled bool           `bin:"optional default=true"`
+		Ratio   float64        `bin:"optional default=0.5"`
+		Label   *string        `bin:"optional default=none"`
+		Name    sql.NullString `bin:"optional default=anon"`
+		Plain   uint32         `bin:"optional"`
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			absent := []byte{0x00}
+			if encoding == EncodingBin {
+				absent = []byte{0x00, 0x00, 0x00, 0x00}
+			}
+			data := bytes.Repeat(absent, 7)
+
+			var got config
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&got))
+			label := "none"
+			assert.Equal(t, config{
+				Retries: 3,
+				Offset:  -16,
+				Enabled: true,
+				Ratio:   0.5,
+				Label:   &label,
+				Name:    sql.NullString{String: "anon", Valid: true},
+			}, got)
+
+			// A present value takes precedence over the default.
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(config{Retries: 7}))
+			got = config{}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&got))
+			assert.Equal(t, uint8(7), got.Retries)
+
+			// A zero value with a default is encoded as present,
+			// so that it doesn't decode as the default.
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(config{}))
+			got = config{}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&got))
+			assert.Equal(t, uint8(0), got.Retries)
+			assert.Equal(t, int16(0), got.Offset)
+			assert.False(t, got.Enabled)
+			assert.Equal(t, float64(0), got.Ratio)
+		})
+	}
+
+	var invalid struct {
+		A uint8 `bin:"optional default=300"`
+	}
+	err := NewBorshDecoder([]byte{0x00}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "A" field: default: invalid uint8 value "300": strconv.ParseUint: parsing "300": value out of range`)
+}
+
+func TestDecoder_WithLenientTrailingOptional(t *testing.T) {
+	type account struct {
+		ID      uint8
+		Owner   *uint16 `bin:"optional"`
+		Retries uint8   `bin:"optional default=3"`
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			// The producer omitted both trailing absent optionals.
+			data := []byte{0x07}
+
+			var got account
+			err := NewDecoderWithEncoding(data, encoding).Decode(&got)
+			assert.True(t, errors.Is(err, ErrShortBuffer))
+
+			got = account{Owner: new(uint16), Retries: 9}
+			dec := NewDecoderWithEncoding(data, encoding).WithLenientTrailingOptional()
+			require.NoError(t, dec.Decode(&got))
+			assert.Equal(t, account{ID: 7, Retries: 3}, got)
+
+			// Present trailing optionals are still decoded.
+			buf := new(bytes.Buffer)
+			owner := uint16(5)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(account{ID: 7, Owner: &owner, Retries: 1}))
+			got = account{}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).WithLenientTrailingOptional().Decode(&got))
+			assert.Equal(t, account{ID: 7, Owner: &owner, Retries: 1}, got)
+		})
+	}
+
+	// A partial presence flag is still an error.
+	var got account
+	err := NewBinDecoder([]byte{0x07, 0x00, 0x00}).WithLenientTrailingOptional().Decode(&got)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	// A binary extension is left untouched.
+	type extended struct {
+		ID    uint8
+		Label *uint8 `bin:"optional binary_extension"`
+	}
+	label := uint8(1)
+	ext := extended{Label: &label}
+	require.NoError(t, NewBorshDecoder([]byte{0x07}).WithLenientTrailingOptional().Decode(&ext))
+	assert.Equal(t, extended{ID: 7, Label: &label}, ext)
+
+	out, present, err := NewBorshDecoder(nil).WithLenientTrailingOptional().ReadOptionalByteSlice()
+	require.NoError(t, err)
+	assert.False(t, present)
+	assert.Nil(t, out)
+}
+
+func TestDecoder_OptionalNonPointerUnmarshaler(t *testing.T) {
+	type optionalUnmarshaler struct {
+		A Int64 `bin:"optional"`
+		B uint8 `bin:"optional"`
+	}
+
+	{
+		out := optionalUnmarshaler{A: 7, B: 8}
+		require.NoError(t, NewBinDecoder([]byte{
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		}).Decode(&out))
+		assert.Equal(t, optionalUnmarshaler{}, out)
+	}
+	{
+		var out optionalUnmarshaler
+		require.NoError(t, NewBinDecoder([]byte{
+			0x01, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x06,
+		}).Decode(&out))
+		assert.Equal(t, optionalUnmarshaler{A: 5, B: 6}, out)
+	}
+	{
+		out := optionalUnmarshaler{A: 7}
+		require.NoError(t, NewBorshDecoder([]byte{0x00, 0x01, 0x06}).Decode(&out))
+		assert.Equal(t, optionalUnmarshaler{B: 6}, out)
+	}
+	{
+		var out optionalUnmarshaler
+		err := NewBorshDecoder([]byte{}).Decode(&out)
+		assert.EqualError(t, err, `error while decoding "A" field: decode: bin.Int64 isPresent, required [1] byte, remaining [0]`)
+	}
+	for _, dec := range []*Decoder{
+		NewBinDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x05}),
+		NewBorshDecoder([]byte{0x01, 0x05}),
+		NewCompactU16Decoder([]byte{0x01, 0x05}),
+	} {
+		// The unmarshaler of a present value fails.
+		var out optionalUnmarshaler
+		err := dec.Decode(&out)
+		assert.EqualError(t, err, `error while decoding "A" field: decode: uint64 required [8] bytes, remaining [1]`)
+		assert.True(t, errors.Is(err, ErrShortBuffer))
+	}
+}
+
+func TestDecoder_ByteLenFixedSlice(t *testing.T) {
+	type point struct {
+		X int16
+		Y int16
+	}
+	type byteLenFixed struct {
+		Values []uint16 `bin:"bytelen_fixed"`
+		Points []point  `bin:"bytelen_fixed"`
+	}
+
+	in := byteLenFixed{
+		Values: []uint16{1, 2, 3},
+		Points: []point{{X: -1, Y: 1}},
+	}
+
+	data, err := MarshalBorsh(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x06, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+		0x04, 0x00, 0x00, 0x00, 0xff, 0xff, 0x01, 0x00,
+	}, data)
+
+	var out byteLenFixed
+	require.NoError(t, UnmarshalBorsh(&out, data))
+	assert.Equal(t, in, out)
+
+	data, err = MarshalBin(in)
+	require.NoError(t, err)
+	out = byteLenFixed{}
+	require.NoError(t, UnmarshalBin(&out, data))
+	assert.Equal(t, in, out)
+
+	err = UnmarshalBin(&out, []byte{0x03, 0x01, 0x00, 0x02})
+	assert.EqualError(t, err, `error while decoding "Values" field: bytelen_fixed: byte length 3 is not a multiple of the element size 2`)
+
+	var strings struct {
+		Values []string `bin:"bytelen_fixed"`
+	}
+	err = UnmarshalBin(&strings, []byte{0x00})
+	assert.EqualError(t, err, `error while decoding "Values" field: bytelen_fixed: element type string doesn't have a fixed size`)
+}
+
+func TestDecoder_String(t *testing.T) {
+	dec := NewBorshDecoder([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	require.NoError(t, dec.SkipBytes(1))
+
+	assert.Equal(t, "Decoder(enc=Borsh pos=1/5 remaining=4)", dec.String())
+	assert.Equal(t, "02030405", dec.DumpRemaining(-1))
+	assert.Equal(t, "02030405", dec.DumpRemaining(4))
+	assert.Equal(t, "0203...(+2 bytes)", dec.DumpRemaining(2))
+	assert.Equal(t, "...(+4 bytes)", dec.DumpRemaining(0))
+
+	require.NoError(t, dec.SkipBytes(4))
+	assert.Equal(t, "", dec.DumpRemaining(10))
+}
+
+func TestDecoder_DumpContext(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	assert.Equal(t, "|0102...(+4 bytes)", dec.DumpContext(2))
+
+	require.NoError(t, dec.SkipBytes(3))
+	assert.Equal(t, "(+1 bytes)...0203|0405...(+1 bytes)", dec.DumpContext(2))
+	assert.Equal(t, "010203|040506", dec.DumpContext(10))
+	assert.Equal(t, "(+3 bytes)...|...(+3 bytes)", dec.DumpContext(0))
+
+	require.NoError(t, dec.SkipBytes(3))
+	assert.Equal(t, "(+5 bytes)...06|", dec.DumpContext(1))
+}
+
+func TestDecoder_DecodeCanonical(t *testing.T) {
+	type canonical struct {
+		Flag   bool
+		Values []uint8
+	}
+
+	{
+		var out canonical
+		dec := NewBinDecoder([]byte{0x01, 0x02, 0x07, 0x08, 0xff})
+		require.NoError(t, dec.DecodeCanonical(&out))
+		assert.Equal(t, canonical{Flag: true, Values: []uint8{7, 8}}, out)
+		assert.Equal(t, 1, dec.Remaining())
+	}
+	{
+		var out canonical
+		err := NewBinDecoder([]byte{0x02, 0x00}).DecodeCanonical(&out)
+		assert.True(t, errors.Is(err, ErrNonCanonical))
+		assert.EqualError(t, err, "non-canonical encoding: consumed 2 bytes, re-encoded to 2 bytes, first difference at offset 0")
+	}
+	{
+		// Non-minimal uvarint length.
+		var out canonical
+		err := NewBinDecoder([]byte{0x00, 0x81, 0x00, 0x07}).DecodeCanonical(&out)
+		assert.True(t, errors.Is(err, ErrNonCanonical))
+		assert.Equal(t, canonical{Values: []uint8{7}}, out)
+	}
+	{
+		// The length encoding of the decoder is used for re-encoding.
+		var out []uint8
+		dec := NewBinDecoder([]byte{0x02, 0x00, 0x01, 0x02})
+		dec.SetLengthEncoding(LengthEncodingU16)
+		require.NoError(t, dec.DecodeCanonical(&out))
+		assert.Equal(t, []uint8{1, 2}, out)
+	}
+	{
+		// So is the C layout, e.g. within ReadAlignedStruct.
+		type aligned struct {
+			A uint8
+			B uint32
+		}
+		var out aligned
+		dec := NewBinDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+		dec.cLayout = true
+		require.NoError(t, dec.DecodeCanonical(&out))
+		assert.Equal(t, aligned{A: 1, B: 2}, out)
+		assert.Equal(t, 0, dec.Remaining())
+	}
+}
+
+func TestDecoder_NonOptionalPointerFields(t *testing.T) {
+	type inner struct {
+		A uint16
+	}
+	type pointers struct {
+		P *inner
+		Q *Int64
+		R *inner `bin:"optional"`
+		B uint8
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			// Non-optional pointers are always allocated when decoding.
+			absent := []byte{0x00}
+			if encoding == EncodingBin {
+				absent = []byte{0x00, 0x00, 0x00, 0x00}
+			}
+			data := append(make([]byte, 2+8), absent...)
+			data = append(data, 0x09)
+
+			var out pointers
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			require.NotNil(t, out.P)
+			require.NotNil(t, out.Q)
+			assert.Nil(t, out.R)
+			assert.Equal(t, inner{}, *out.P)
+			assert.Equal(t, Int64(0), *out.Q)
+			assert.Equal(t, uint8(9), out.B)
+
+			q := Int64(-3)
+			in := pointers{P: &inner{A: 1}, Q: &q, R: &inner{A: 2}, B: 9}
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			out = pointers{}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+}
+
+// threeBytes marshals itself as 3 bytes, while its fields take 1 byte.
+type threeBytes struct {
+	V uint8
+}
+
+func (b *threeBytes) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteBytes([]byte{b.V, 0xff, 0xff}, false)
+}
+
+func (b *threeBytes) UnmarshalWithDecoder(dec *Decoder) error {
+	data, err := dec.ReadNBytes(3)
+	if err != nil {
+		return err
+	}
+	b.V = data[0]
+	return nil
+}
+
+func TestDecoder_PointerFieldWithUnmarshaler(t *testing.T) {
+	type pointers struct {
+		P *threeBytes
+		B uint8
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			// The allocated pointer is decoded through its pointer-receiver unmarshaler.
+			data := []byte{0x05, 0xff, 0xff, 0x09}
+			var out pointers
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, pointers{P: &threeBytes{V: 5}, B: 9}, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(out))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+}
+
+func TestDecoder_Progress(t *testing.T) {
+	assert.Equal(t, float64(1), NewBinDecoder(nil).Progress())
+
+	data, err := MarshalBorsh([]uint16{1, 2, 3})
+	require.NoError(t, err)
+
+	var calls [][2]int
+	dec := NewBorshDecoder(data).WithProgressCallback(func(pos, total int) {
+		calls = append(calls, [2]int{pos, total})
+	})
+	assert.Equal(t, float64(0), dec.Progress())
+
+	var out []uint16
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, float64(1), dec.Progress())
+	assert.Equal(t, [][2]int{{6, 10}, {8, 10}, {10, 10}}, calls)
+}
+
+func TestDecoder_ASCIIStr(t *testing.T) {
+	type token struct {
+		Symbol string `bin:"asciistr=8"`
+		Supply uint16
+	}
+
+	data := []byte{'U', 'S', 'D', 'C', ' ', 0x00, 0x00, 0x00, 0x01, 0x00}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var got token
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&got))
+			assert.Equal(t, token{Symbol: "USDC", Supply: 1}, got)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(got))
+			assert.Equal(t, []byte{'U', 'S', 'D', 'C', 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, buf.Bytes())
+
+			err := NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(token{Symbol: "TOOLONGSYMBOL"})
+			assert.EqualError(t, err, `error while encoding "Symbol" field: asciistr: string of 13 bytes doesn't fit in 8 bytes`)
+		})
+	}
+
+	var missing struct {
+		Symbol string `bin:"asciistr"`
+	}
+	err := NewBinDecoder(data).Decode(&missing)
+	assert.EqualError(t, err, `error while decoding "Symbol" field: asciistr: the tag must specify a positive byte count, like asciistr=32`)
+}
+
+func TestDecoder_ByteArraySlice(t *testing.T) {
+	in := [][4]byte{{1, 2, 3, 4}, {5, 6, 7, 8}}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out [][4]byte
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			out = nil
+			err := NewDecoderWithEncoding(buf.Bytes()[:buf.Len()-1], encoding).Decode(&out)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecoder_SliceOfSlices(t *testing.T) {
+	type keys struct {
+		Arrays [][2]byte
+		Slices [][]byte
+		Fixed  [][]byte `bin:"fixed_inner=2"`
+	}
+
+	in := keys{
+		Arrays: [][2]byte{{1, 2}},
+		Slices: [][]byte{{3, 4}},
+		Fixed:  [][]byte{{5, 6}, {7, 8}},
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			length := func(l int) []byte {
+				if encoding == EncodingBorsh {
+					return []byte{byte(l), 0x00, 0x00, 0x00}
+				}
+				return []byte{byte(l)}
+			}
+			var expected []byte
+			// [][N]byte: only the outer length.
+			expected = append(expected, length(1)...)
+			expected = append(expected, 1, 2)
+			// [][]byte: the outer length, then a length per element.
+			expected = append(expected, length(1)...)
+			expected = append(expected, length(2)...)
+			expected = append(expected, 3, 4)
+			// fixed_inner: only the outer length.
+			expected = append(expected, length(2)...)
+			expected = append(expected, 5, 6, 7, 8)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out keys
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			err := NewDecoderWithEncoding(buf.Bytes()[:buf.Len()-1], encoding).Decode(&keys{})
+			assert.True(t, errors.Is(err, ErrShortBuffer))
+
+			err = NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(keys{Fixed: [][]byte{{1, 2, 3}}})
+			assert.EqualError(t, err, `error while encoding "Fixed" field: fixed_inner: element 0 has 3 bytes, expected 2`)
+		})
+	}
+
+	var invalid struct {
+		Fixed []string `bin:"fixed_inner=2"`
+	}
+	err := NewBinDecoder([]byte{0x00}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "Fixed" field: fixed_inner: expected a [][]byte field, got []string`)
+}
+
+func TestDecoder_Runes(t *testing.T) {
+	type text struct {
+		UTF8  []rune `bin:"runes=utf8"`
+		UTF32 []rune `bin:"runes=utf32"`
+		Plain []rune
+	}
+
+	in := text{UTF8: []rune("hé"), UTF32: []rune("é€"), Plain: []rune("a")}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out text
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			// utf8 is encoded like the string, and utf32 like the untagged []rune.
+			expected := new(bytes.Buffer)
+			enc := NewEncoderWithEncoding(expected, encoding)
+			require.NoError(t, enc.Encode("hé"))
+			require.NoError(t, enc.Encode([]rune("é€")))
+			require.NoError(t, enc.Encode([]rune("a")))
+			assert.Equal(t, expected.Bytes(), buf.Bytes())
+		})
+	}
+
+	var utf8Only struct {
+		R []rune `bin:"runes=utf8"`
+	}
+	err := NewBorshDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0xff}).Decode(&utf8Only)
+	assert.EqualError(t, err, `error while decoding "R" field: runes: invalid UTF-8 string "\xff"`)
+
+	var utf32Only struct {
+		R []rune `bin:"runes=utf32"`
+	}
+	err = NewBorshDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0xd8, 0x00, 0x00}).Decode(&utf32Only)
+	assert.EqualError(t, err, `error while decoding "R" field: runes: invalid code point 0xd800 at index 0`)
+
+	err = NewBorshEncoder(new(bytes.Buffer)).Encode(struct {
+		R []rune `bin:"runes=utf16"`
+	}{})
+	assert.EqualError(t, err, `error while encoding "R" field: runes: unknown encoding "utf16", expected runes=utf8 or runes=utf32`)
+}
+
+func TestDecoder_PopcountLen(t *testing.T) {
+	type sparse struct {
+		Mask   uint8
+		Values []uint16 `bin:"popcount_len=Mask"`
+		Flags  []byte
+		Names  []string `bin:"popcount_len=Flags"`
+	}
+
+	in := sparse{
+		Mask:   0b1010_0001,
+		Values: []uint16{1, 2, 3},
+		Flags:  []byte{0x01, 0x80},
+		Names:  []string{"a", "b"},
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			// The values follow the mask without a length prefix.
+			assert.Equal(t, []byte{0b1010_0001, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00}, buf.Bytes()[:7])
+
+			var out sparse
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			err := NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(sparse{Mask: 0x03, Values: []uint16{1}})
+			assert.EqualError(t, err, `error while encoding "Values" field: popcount_len: 1 elements, but the mask "Mask" has 2 bits set`)
+		})
+	}
+
+	var missing struct {
+		Values []uint16 `bin:"popcount_len=Mask"`
+	}
+	err := NewBorshDecoder([]byte{0x00}).Decode(&missing)
+	assert.EqualError(t, err, `error while decoding "Values" field: popcount_len: no field "Mask" in struct { Values []uint16 "bin:\"popcount_len=Mask\"" }`)
+
+	var invalid struct {
+		Mask   string
+		Values []uint16 `bin:"popcount_len=Mask"`
+	}
+	err = NewBorshDecoder([]byte{0x00, 0x00, 0x00, 0x00}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "Values" field: popcount_len: mask field "Mask" must be an integer or bytes, got string`)
+
+	var following struct {
+		Values []uint8 `bin:"popcount_len=Mask"`
+		Mask   uint8
+	}
+	err = NewBorshDecoder([]byte{0x01, 0x02}).Decode(&following)
+	assert.EqualError(t, err, `error while decoding "Values" field: popcount_len: mask "Mask" is not a field preceding "Values"`)
+
+	var negative struct {
+		Mask   int8
+		Values []uint8 `bin:"popcount_len=Mask"`
+	}
+	require.NoError(t, NewBorshDecoder([]byte{0xff, 1, 2, 3, 4, 5, 6, 7, 8}).Decode(&negative))
+	assert.Len(t, negative.Values, 8)
+}
+
+func TestDecoder_ConditionalField(t *testing.T) {
+	type record struct {
+		Flags   uint8
+		ID      uint32 `bin:"if=Flags&0x01"`
+		Name    string `bin:"if=Flags&0x02"`
+		Version uint16
+		Extra   uint8 `bin:"if=Version==2"`
+		Legacy  uint8 `bin:"if=Flags&0xf0!=0x10"`
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			in := record{Flags: 0x11, ID: 7, Version: 2, Extra: 9}
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			// Name and Legacy are absent.
+			assert.Equal(t, []byte{0x11, 0x07, 0x00, 0x00, 0x00, 0x02, 0x00, 0x09}, buf.Bytes())
+
+			out := record{Name: "stale", Legacy: 1}
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(record{Flags: 0x02, Name: "a", Version: 1, Legacy: 3}))
+	var out record
+	require.NoError(t, NewBinDecoder(buf.Bytes()).Decode(&out))
+	assert.Equal(t, record{Flags: 0x02, Name: "a", Version: 1, Legacy: 3}, out)
+
+	var missing struct {
+		Value uint8 `bin:"if=Flags&1"`
+	}
+	err := NewBorshDecoder([]byte{0x00}).Decode(&missing)
+	assert.EqualError(t, err, `error while decoding "Value" field: if: no field "Flags" in struct { Value uint8 "bin:\"if=Flags&1\"" }`)
+
+	var invalid struct {
+		Flags uint8
+		Value uint8 `bin:"if=Flags&x"`
+	}
+	err = NewBorshDecoder([]byte{0x01, 0x02}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "Value" field: if: invalid mask in "Flags&x"`)
+
+	var following struct {
+		Value uint8 `bin:"if=Flags&1"`
+		Flags uint8
+	}
+	err = NewBorshDecoder([]byte{0x01, 0x02}).Decode(&following)
+	assert.EqualError(t, err, `error while decoding "Value" field: if: "Flags" is not a field preceding "Value"`)
+
+	// The fields are compared by wire position.
+	var reordered struct {
+		Value uint8 `bin:"if=Flags&1 order_index=2"`
+		Flags uint8
+	}
+	require.NoError(t, NewBorshDecoder([]byte{0x01, 0x02}).Decode(&reordered))
+	assert.Equal(t, uint8(2), reordered.Value)
+	_, err = MarshalBorsh(&following)
+	assert.EqualError(t, err, `error while encoding "Value" field: if: "Flags" is not a field preceding "Value"`)
+
+	_, ok := FixedSize(reflect.TypeOf(record{}), EncodingBin)
+	assert.False(t, ok)
+}
+
+func TestDecoder_TriBool(t *testing.T) {
+	type answers struct {
+		Yes     *bool `bin:"tribool"`
+		No      *bool `bin:"tribool"`
+		Unknown *bool `bin:"tribool"`
+	}
+
+	yes, no := true, false
+	in := answers{Yes: &yes, No: &no}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, []byte{0x01, 0x00, 0x02}, buf.Bytes())
+
+			var out answers
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			err := NewDecoderWithEncoding([]byte{0x01, 0x03, 0x02}, encoding).Decode(&out)
+			assert.EqualError(t, err, `error while decoding "No" field: tribool: invalid value 3`)
+		})
+	}
+
+	dec := NewBinDecoder([]byte{0x03})
+	_, err := dec.ReadTriBool()
+	assert.Error(t, err)
+	assert.Equal(t, 0, int(dec.Position()))
+
+	var invalid struct {
+		A bool `bin:"tribool"`
+	}
+	err = NewBinDecoder([]byte{0x01}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "A" field: tribool: expected a *bool field, got bool`)
+}
+
+func TestDecoder_SetLengthEncoding(t *testing.T) {
+	type lists struct {
+		A []uint8
+		M map[uint8]uint8
+	}
+
+	dec := NewBinDecoder([]byte{
+		0x02, 0x00, 0x01, 0x02, // A
+		0x01, 0x00, 0x03, 0x04, // M
+	})
+	dec.SetLengthEncoding(LengthEncodingU16)
+
+	var got lists
+	require.NoError(t, dec.Decode(&got))
+	assert.Equal(t, lists{A: []uint8{1, 2}, M: map[uint8]uint8{3: 4}}, got)
+	assert.Equal(t, 0, dec.Remaining())
+
+	dec = NewBorshDecoder([]byte{0x03, 0x01, 0x02, 0x03})
+	dec.SetLengthEncoding(LengthEncodingUvarint)
+	var out []uint8
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, []uint8{1, 2, 3}, out)
+
+	dec = NewBinDecoder([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x02})
+	dec.SetLengthEncoding(LengthEncodingU32)
+	length, err := dec.ReadLength()
+	require.NoError(t, err)
+	assert.Equal(t, 2, length)
+
+	dec.SetLengthEncoding(LengthEncodingDefault)
+	length, err = dec.ReadLength()
+	require.NoError(t, err)
+	assert.Equal(t, 1, length)
+}
+
+func TestDecoder_SetFloatPolicy(t *testing.T) {
+	nan := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x7f}
+	inf := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x7f}
+
+	_, err := NewBorshDecoder(nan).ReadFloat64(LE)
+	assert.EqualError(t, err, "NaN for float not allowed")
+	_, err = NewBorshDecoder(inf).ReadFloat64(LE)
+	assert.NoError(t, err)
+	_, err = NewBinDecoder(nan).ReadFloat64(LE)
+	assert.NoError(t, err)
+
+	dec := NewBorshDecoder(nan)
+	dec.SetFloatPolicy(FloatPolicy{})
+	_, err = dec.ReadFloat64(LE)
+	assert.NoError(t, err)
+
+	dec = NewBinDecoder(inf)
+	dec.SetFloatPolicy(FloatPolicy{RejectNaN: true, RejectInf: true})
+	_, err = dec.ReadFloat64(LE)
+	assert.EqualError(t, err, "Inf for float not allowed")
+	assert.True(t, errors.Is(err, ErrInf))
+	assert.False(t, errors.Is(err, ErrNaN))
+
+	dec = NewBinDecoder([]byte{0x00, 0x00, 0xc0, 0x7f})
+	dec.SetFloatPolicy(FloatPolicy{RejectNaN: true})
+	var f float32
+	assert.EqualError(t, dec.Decode(&f), "NaN for float not allowed")
+}
+
+func TestDecoder_ReadLenString(t *testing.T) {
+	tests := []struct {
+		encoding Encoding
+		data     []byte
+	}{
+		{EncodingBin, []byte{0x02, 'h', 'i'}},
+		{EncodingBorsh, []byte{0x02, 0x00, 0x00, 0x00, 'h', 'i'}},
+		{EncodingCompactU16, []byte{0x02, 'h', 'i'}},
+	}
+	for _, test := range tests {
+		t.Run(test.encoding.String(), func(t *testing.T) {
+			dec := NewDecoderWithEncoding(test.data, test.encoding)
+			s, err := dec.ReadLenString()
+			require.NoError(t, err)
+			assert.Equal(t, "hi", s)
+			assert.Equal(t, 0, dec.Remaining())
+
+			_, err = NewDecoderWithEncoding(test.data[:len(test.data)-1], test.encoding).ReadLenString()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecoder_RestField(t *testing.T) {
+	type envelope struct {
+		Kind uint8
+		Body []byte `bin:"rest"`
+	}
+
+	data := []byte{0x07, 0x01, 0x02, 0x03}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var got envelope
+			dec := NewDecoderWithEncoding(data, encoding)
+			require.NoError(t, dec.Decode(&got))
+			assert.Equal(t, envelope{Kind: 7, Body: []byte{1, 2, 3}}, got)
+			assert.Equal(t, 0, dec.Remaining())
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(got))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+
+	var notLast struct {
+		Body []byte `bin:"rest"`
+		Kind uint8
+	}
+	err := NewBinDecoder(data).Decode(&notLast)
+	assert.EqualError(t, err, `error while decoding "Body" field: rest: must be the last field of struct { Body []uint8 "bin:\"rest\""; Kind uint8 }`)
+
+	// Last on the wire, followed only by skipped fields.
+	var reordered struct {
+		Body  []byte `bin:"rest order_index=2"`
+		Kind  uint8
+		Cache []byte `bin:"-"`
+	}
+	require.NoError(t, NewBinDecoder(data).Decode(&reordered))
+	assert.Equal(t, uint8(7), reordered.Kind)
+	assert.Equal(t, []byte{1, 2, 3}, reordered.Body)
+}
+
+func TestDecoder_FillRestField(t *testing.T) {
+	type record struct {
+		Tag  uint8
+		Name string
+	}
+	type frame struct {
+		Version uint8
+		Records []record `bin:"fill_rest"`
+	}
+
+	in := frame{Version: 1, Records: []record{{Tag: 1, Name: "a"}, {Tag: 2, Name: "bc"}}}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out frame
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			// The records stop at the end of the frame.
+			data := append(buf.Bytes(), 0xaa)
+			dec = NewDecoderWithEncoding(data, encoding)
+			require.NoError(t, dec.ReadPadded(buf.Len(), func(dec *Decoder) error {
+				out = frame{}
+				return dec.Decode(&out)
+			}))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 1, dec.Remaining())
+		})
+	}
+
+	var empty frame
+	require.NoError(t, NewBinDecoder([]byte{0x01}).Decode(&empty))
+	assert.Equal(t, frame{Version: 1}, empty)
+
+	// A truncated record is an error, rather than being dropped.
+	err := NewBorshDecoder([]byte{0x01, 0x01, 0x01, 0x00}).Decode(&empty)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	var notSlice struct {
+		Records record `bin:"fill_rest"`
+	}
+	err = NewBinDecoder([]byte{0x01}).Decode(&notSlice)
+	assert.EqualError(t, err, `error while decoding "Records" field: fill_rest: expected a slice field, got bin.record`)
+
+	var zeroSize struct {
+		Records []struct{} `bin:"fill_rest"`
+	}
+	err = NewBinDecoder([]byte{0x01}).Decode(&zeroSize)
+	assert.EqualError(t, err, `error while decoding "Records" field: fill_rest: element 0 of type struct {} consumed no bytes`)
+}
+
+func TestDecoder_TrailerLenField(t *testing.T) {
+	type record struct {
+		Tag  uint8
+		Name string
+	}
+	type frame struct {
+		Version uint8
+		Records []record `bin:"trailer_len"`
+	}
+
+	in := frame{Version: 1, Records: []record{{Tag: 1, Name: "a"}, {Tag: 2, Name: "bc"}}}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, []byte{0x02, 0x00, 0x00, 0x00}, buf.Bytes()[buf.Len()-4:])
+
+			var out frame
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+
+			// The trailer is at the end of the frame.
+			data := append(buf.Bytes(), 0xaa)
+			dec = NewDecoderWithEncoding(data, encoding)
+			require.NoError(t, dec.ReadPadded(buf.Len(), func(dec *Decoder) error {
+				out = frame{}
+				return dec.Decode(&out)
+			}))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 1, dec.Remaining())
+		})
+	}
+
+	type big struct {
+		Values []uint16 `bin:"trailer_len big"`
+	}
+	var values big
+	// The byte order tag applies to the elements and the trailer.
+	bigData := []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02}
+	require.NoError(t, NewBinDecoder(bigData).Decode(&values))
+	assert.Equal(t, big{Values: []uint16{1, 2}}, values)
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(values))
+	assert.Equal(t, bigData, buf.Bytes())
+
+	// So does the byte order inherited from an enclosing struct field.
+	var inherited struct {
+		Frame struct {
+			Values []uint16 `bin:"trailer_len"`
+		} `bin:"order=be"`
+	}
+	require.NoError(t, NewBinDecoder(bigData).Decode(&inherited))
+	assert.Equal(t, []uint16{1, 2}, inherited.Frame.Values)
+
+	err := NewBinDecoder([]byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}).Decode(&values)
+	assert.EqualError(t, err, `error while decoding "Values" field: trailer_len: 2 bytes left between the 1 elements and the trailer`)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	err = NewBinDecoder([]byte{0x00, 0x00, 0x00, 0xff}).Decode(&values)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	err = NewBinDecoder([]byte{0x01, 0x00}).Decode(&values)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	// A count of elements that take no bytes is bounded too.
+	var empty struct {
+		Values []struct{} `bin:"trailer_len"`
+	}
+	err = NewBinDecoder([]byte{0xff, 0xff, 0xff, 0xff}).Decode(&empty)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	var notLast struct {
+		Values []uint8 `bin:"trailer_len"`
+		Flag   bool
+	}
+	err = NewBinDecoder([]byte{0x00, 0x00, 0x00, 0x00, 0x00}).Decode(&notLast)
+	assert.EqualError(t, err, `error while decoding "Values" field: trailer_len: must be the last field of struct { Values []uint8 "bin:\"trailer_len\""; Flag bool }`)
+}
+
+func TestDecoder_Continuation(t *testing.T) {
+	type chain struct {
+		Names []string   `bin:"continuation=prefix_byte"`
+		Codes []uint8    `bin:"continuation=first_byte"`
+		Pairs [][2]uint8 `bin:"continuation=first_byte"`
+		Tail  uint8
+	}
+
+	in := chain{
+		Names: []string{"a", "bc"},
+		Codes: []uint8{0x81, 0xff, 0x03},
+		Pairs: [][2]uint8{{0x05, 0x06}},
+		Tail:  9,
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out chain
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, dec.Remaining())
+		})
+	}
+
+	data, err := MarshalBorsh(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x80, 0x01, 0x00, 0x00, 0x00, 'a',
+		0x00, 0x02, 0x00, 0x00, 0x00, 'b', 'c',
+		0x81, 0xff, 0x03,
+		0x05, 0x06,
+		0x09,
+	}, data)
+
+	_, err = MarshalBorsh(chain{Codes: []uint8{0x01}, Pairs: [][2]uint8{{}}})
+	assert.EqualError(t, err, `error while encoding "Names" field: continuation: cannot encode an empty slice, which has no last element`)
+	_, err = MarshalBorsh(chain{Names: []string{""}, Codes: []uint8{0x01, 0x02}, Pairs: [][2]uint8{{}}})
+	assert.EqualError(t, err, `error while encoding "Codes" field: continuation: element 0 of 2 doesn't have the expected continuation bit in its first byte`)
+
+	var out chain
+	err = NewBorshDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x00}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "Names" field: continuation: element 0: invalid continuation byte 0x01`)
+	err = NewBorshDecoder([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x81}).Decode(&out)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	var invalid struct {
+		Values []uint8 `bin:"continuation=last_bit"`
+	}
+	err = NewBorshDecoder([]byte{0x00}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "Values" field: continuation: unknown position "last_bit", expected "first_byte" or "prefix_byte"`)
+
+	// The elements are encoded with the settings of the encoder.
+	type element struct {
+		Flag uint8
+		Data []uint8
+	}
+	var elements struct {
+		Elements []element `bin:"continuation=first_byte"`
+	}
+	elements.Elements = []element{{Flag: 0x80, Data: []uint8{0xaa}}, {}}
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	enc.SetLengthEncoding(LengthEncodingU16)
+	require.NoError(t, enc.Encode(elements))
+	assert.Equal(t, []byte{0x80, 0x01, 0x00, 0xaa, 0x00, 0x00, 0x00}, buf.Bytes())
+}
+
+func TestDecoder_UnknownField(t *testing.T) {
+	type accountV2 struct {
+		ID    uint32
+		Name  string
+		Flags uint16
+	}
+	type accountV1 struct {
+		Unknown []byte `bin:"unknown"`
+		ID      uint32
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(accountV2{ID: 1, Name: "alice", Flags: 7}))
+
+			// An older reader captures the fields it doesn't know.
+			var old accountV1
+			dec := NewDecoderWithEncoding(buf.Bytes(), encoding)
+			require.NoError(t, dec.Decode(&old))
+			assert.Equal(t, uint32(1), old.ID)
+			assert.Equal(t, buf.Bytes()[4:], old.Unknown)
+			assert.Equal(t, 0, dec.Remaining())
+
+			// ... and preserves them when re-encoding.
+			old.ID = 2
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(old))
+			var out accountV2
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, accountV2{ID: 2, Name: "alice", Flags: 7}, out)
+		})
+	}
+
+	// The unknown bytes stop at the end of the frame.
+	dec := NewBinDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0xaa, 0xbb})
+	var old accountV1
+	require.NoError(t, dec.ReadPadded(5, func(dec *Decoder) error { return dec.Decode(&old) }))
+	assert.Equal(t, accountV1{ID: 1, Unknown: []byte{0xaa}}, old)
+	assert.Equal(t, 1, dec.Remaining())
+
+	require.NoError(t, NewBinDecoder([]byte{0x01, 0x00, 0x00, 0x00}).Decode(&old))
+	assert.Equal(t, accountV1{ID: 1}, old)
+
+	// The unknown field isn't counted by a field count.
+	type counted struct {
+		_       struct{} `bin:"field_count"`
+		ID      uint8
+		Unknown []byte `bin:"unknown"`
+	}
+	data, err := MarshalBorsh(counted{ID: 3, Unknown: []byte{0x09}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x00, 0x03, 0x09}, data)
+
+	var twice struct {
+		A []byte `bin:"unknown"`
+		B []byte `bin:"unknown"`
+	}
+	err = NewBinDecoder(nil).Decode(&twice)
+	assert.EqualError(t, err, `unknown: struct { A []uint8 "bin:\"unknown\""; B []uint8 "bin:\"unknown\"" } has more than one unknown field`)
+
+	var invalid struct {
+		Unknown string `bin:"unknown"`
+	}
+	err = NewBinDecoder(nil).Decode(&invalid)
+	assert.EqualError(t, err, "unknown: expected a []byte field, got string")
+}
+
+func TestDecoder_ReadDelimited(t *testing.T) {
+	type record struct {
+		A uint16
+		B uint8
+	}
+
+	dec := NewBinDecoder([]byte{
+		0x03, 0x01, 0x00, 0x02, // record{1, 2}
+		0x04, 0x03, 0x00, 0x04, 0xff, // record{3, 4}, with a leftover byte
+		0x02, 0x05, 0x00, // record{5, ?}: overruns its length
+	})
+
+	var got record
+	require.NoError(t, dec.ReadDelimited(&got))
+	assert.Equal(t, record{A: 1, B: 2}, got)
+	require.NoError(t, dec.ReadDelimited(&got))
+	assert.Equal(t, record{A: 3, B: 4}, got)
+	assert.Equal(t, 3, dec.Remaining())
+
+	err := dec.ReadDelimited(&got)
+	assert.Error(t, err)
+	assert.Equal(t, 3, dec.Remaining())
+
+	err = NewBinDecoder([]byte{0x05, 0x01}).ReadDelimited(&got)
+	assert.EqualError(t, err, "delimited: message length 5 exceeds the 1 remaining bytes")
+}
+
+type currentOptionRecorder struct {
+	encoding Encoding
+	option   Option
+}
+
+func (r *currentOptionRecorder) UnmarshalWithDecoder(dec *Decoder) error {
+	r.encoding = dec.Encoding()
+	r.option = dec.CurrentOption()
+	return nil
+}
+
+func TestDecoder_CurrentOption(t *testing.T) {
+	dec := NewBorshDecoder(nil)
+	assert.Equal(t, EncodingBorsh, dec.Encoding())
+	assert.Equal(t, Option{Order: LE, SizeOfSlice: -1}, dec.CurrentOption())
+
+	var out struct {
+		R currentOptionRecorder `bin:"big"`
+	}
+	require.NoError(t, NewCompactU16Decoder(nil).Decode(&out))
+	assert.Equal(t, EncodingCompactU16, out.R.encoding)
+	assert.Equal(t, Option{Order: BE, SizeOfSlice: -1}, out.R.option)
+}
+
+func TestDecoder_ReadTLV(t *testing.T) {
+	data := []byte{
+		0x01, 0x02, 'h', 'i',
+		0x02, 0x01, 0x07,
+		0x01, 0x00,
+	}
+
+	dec := NewBinDecoder(data)
+	tag, value, err := dec.ReadTLV()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), tag)
+	assert.Equal(t, []byte("hi"), value)
+
+	var names []string
+	var numbers []byte
+	handlers := map[uint8]func([]byte) error{
+		1: func(value []byte) error {
+			names = append(names, string(value))
+			return nil
+		},
+		2: func(value []byte) error {
+			numbers = append(numbers, value...)
+			return nil
+		},
+	}
+	require.NoError(t, NewBinDecoder(data).DecodeTLVMap(handlers))
+	assert.Equal(t, []string{"hi", ""}, names)
+	assert.Equal(t, []byte{7}, numbers)
+
+	err = NewBinDecoder([]byte{0x03, 0x00}).DecodeTLVMap(handlers)
+	assert.EqualError(t, err, "tlv: no handler for tag 3")
+
+	_, _, err = NewBorshDecoder([]byte{0x01, 0x05, 0x00, 0x00, 0x00, 'a'}).ReadTLV()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tlv: value of tag 1: ")
+}
+
+func TestDecoder_RFC3339(t *testing.T) {
+	type event struct {
+		ID uint8
+		At time.Time `bin:"rfc3339"`
+	}
+
+	in := event{ID: 1, At: time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC)}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Contains(t, buf.String(), "2021-03-04T05:06:07.0000008Z")
+
+			var out event
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.True(t, in.At.Equal(out.At))
+			assert.Equal(t, in.ID, out.ID)
+		})
+	}
+
+	data, err := MarshalBorsh(struct {
+		ID uint8
+		At string
+	}{1, "yesterday"})
+	require.NoError(t, err)
+	var out event
+	err = NewBorshDecoder(data).Decode(&out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `error while decoding "At" field: rfc3339: parsing time "yesterday"`)
+}
+
+func TestDecoder_ReadRLEBytes(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x03, 0xaa, 0x00, 0xff, 0x02, 0xbb, 0x09})
+	out, err := dec.ReadRLEBytes(5)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xaa, 0xaa, 0xaa, 0xbb, 0xbb}, out)
+	assert.Equal(t, 1, dec.Remaining())
+
+	out, err = NewBinDecoder(nil).ReadRLEBytes(0)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+
+	_, err = NewBinDecoder([]byte{0x03, 0xaa, 0x04, 0xbb}).ReadRLEBytes(5)
+	assert.EqualError(t, err, "rle: run of 4 bytes overflows the total length 5 by 2 bytes")
+
+	_, err = NewBinDecoder([]byte{0x03, 0xaa}).ReadRLEBytes(5)
+	assert.Error(t, err)
+}
+
+func TestDecoder_SetMaxStringLen(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+
+	dec := NewBinDecoder(huge)
+	dec.SetMaxStringLen(4)
+	_, err := dec.ReadRustString()
+	assert.EqualError(t, err, "string length 9223372036854775807 exceeds the max of 4")
+
+	dec = NewBorshDecoder([]byte{0x05, 0x00, 0x00, 0x00, 'h', 'e', 'l', 'l', 'o'})
+	dec.SetMaxStringLen(4)
+	_, err = dec.ReadString()
+	assert.EqualError(t, err, "string length 5 exceeds the max of 4")
+
+	var s struct{ S string }
+	dec = NewBorshDecoder([]byte{0x05, 0x00, 0x00, 0x00, 'h', 'e', 'l', 'l', 'o'})
+	dec.SetMaxStringLen(4)
+	assert.EqualError(t, dec.Decode(&s), `error while decoding "S" field: string length 5 exceeds the max of 4`)
+
+	dec = NewCompactU16Decoder([]byte{0x04, 'a', 'b', 'c', 'd'})
+	dec.SetMaxStringLen(4)
+	out, err := dec.SafeReadUTF8String()
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", out)
+}
+
+func TestDecoder_FillSlice(t *testing.T) {
+	type frame struct {
+		Kind   uint8
+		Points [][2]uint16 `bin:"fill"`
+	}
+
+	data := []byte{0x01, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00}
+	in := frame{Kind: 1, Points: [][2]uint16{{1, 2}, {3, 4}}}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var out frame
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, data, buf.Bytes())
+
+			err := NewDecoderWithEncoding(data[:len(data)-1], encoding).Decode(&out)
+			assert.EqualError(t, err, `error while decoding "Points" field: fill: 7 remaining bytes are not a multiple of the element size 4`)
+		})
+	}
+
+	// Within a delimited message, only the remainder of the message is filled.
+	dec := NewBinDecoder(append(append([]byte{0x05}, data[:5]...), 0xff))
+	var out frame
+	require.NoError(t, dec.ReadDelimited(&out))
+	assert.Equal(t, frame{Kind: 1, Points: [][2]uint16{{1, 2}}}, out)
+	assert.Equal(t, 1, dec.Remaining())
+}
+
+func TestDecoder_ReadUintN(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x01, 0x02, 0x03, 0x01, 0x02, 0x03, 0x04, 0x05})
+	n, err := dec.ReadUint24(LE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x030201), n)
+	n, err = dec.ReadUint24(BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x010203), n)
+
+	v, err := dec.ReadUintN(2, BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x0405), v)
+
+	_, err = dec.ReadUintN(9, LE)
+	assert.EqualError(t, err, "uintN: invalid width of 9 bytes")
+	_, err = dec.ReadUintN(1, LE)
+	assert.EqualError(t, err, "uint8 required [1] bytes, remaining [0]")
+
+	type header struct {
+		Length uint32 `bin:"u24 big"`
+		Flags  uint32 `bin:"u24"`
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			data := []byte{0x01, 0x02, 0x03, 0x01, 0x02, 0x03}
+			var out header
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, header{Length: 0x010203, Flags: 0x030201}, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(out))
+			assert.Equal(t, data, buf.Bytes())
+
+			err := NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(header{Length: 1 << 24})
+			assert.EqualError(t, err, `error while encoding "Length" field: uintN: value 16777216 overflows 3 bytes`)
+		})
+	}
+}
+
+func TestDecoder_ReadIntN(t *testing.T) {
+	dec := NewBinDecoder([]byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0x85, 0x7f, 0xff, 0xff})
+	n, err := dec.ReadInt24(LE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(-2), n)
+	n, err = dec.ReadInt24(BE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(-123), n)
+
+	v, err := dec.ReadIntN(3, LE)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-129), v)
+
+	_, err = dec.ReadIntN(0, LE)
+	assert.EqualError(t, err, "intN: invalid width of 0 bytes")
+
+	for _, value := range []int64{0, 1, -1, 1<<39 - 1, -1 << 39} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteIntN(value, 5, BE))
+		assert.Len(t, buf.Bytes(), 5)
+		out, err := NewBinDecoder(buf.Bytes()).ReadIntN(5, BE)
+		require.NoError(t, err)
+		assert.Equal(t, value, out)
+	}
+	err = NewBinEncoder(new(bytes.Buffer)).WriteIntN(1<<39, 5, LE)
+	assert.EqualError(t, err, "intN: value 549755813888 overflows 5 bytes")
+
+	type sample struct {
+		Left  int32 `bin:"i24"`
+		Right int32 `bin:"i24 big"`
+		Time  int64 `bin:"i40"`
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			data := []byte{0x00, 0x00, 0x80, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+			var out sample
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, sample{Left: -1 << 23, Right: 1<<23 - 1, Time: -1}, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(out))
+			assert.Equal(t, data, buf.Bytes())
+
+			err := NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(sample{Left: 1 << 23})
+			assert.EqualError(t, err, `error while encoding "Left" field: intN: value 8388608 overflows 3 bytes`)
+		})
+	}
+
+	var narrow struct {
+		Value int16 `bin:"i24"`
+	}
+	err = NewBinDecoder([]byte{0x01, 0x02, 0x03}).Decode(&narrow)
+	assert.EqualError(t, err, `error while decoding "Value" field: i24: expected a signed integer field of at least 24 bits, got int16`)
+}
+
+func TestDecoder_ReadQ(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x00, 0x80, 0x01, 0x00, 0x00, 0x80, 0xff, 0xff, 0x80, 0xff})
+	f, err := dec.ReadQ(16, 16, LE)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	f, err = dec.ReadQ(8, 8, LE)
+	require.NoError(t, err)
+	assert.Equal(t, -128.0, f)
+	f, err = dec.ReadQ(1, 15, BE)
+	require.NoError(t, err)
+	assert.Equal(t, -1.0/32768, f)
+	f, err = dec.ReadUQ(1, 15, BE)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0x80ff)/32768, f)
+
+	_, err = dec.ReadQ(16, 15, LE)
+	assert.EqualError(t, err, "q16.15: width of 31 bits is not a whole number of 1 to 8 bytes")
+
+	for _, value := range []float64{0, 1.5, -1.5, 127.99609375, -128} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteQ(value, 8, 8, BE))
+		out, err := NewBinDecoder(buf.Bytes()).ReadQ(8, 8, BE)
+		require.NoError(t, err)
+		assert.Equal(t, value, out)
+	}
+	err = NewBinEncoder(new(bytes.Buffer)).WriteQ(128, 8, 8, LE)
+	assert.EqualError(t, err, "q8.8: value 128 out of range")
+	err = NewBinEncoder(new(bytes.Buffer)).WriteUQ(-0.5, 8, 8, LE)
+	assert.EqualError(t, err, "uq8.8: value -0.5 out of range")
+
+	type sample struct {
+		Position float64 `bin:"q16.16"`
+		Gain     float32 `bin:"uq8.8 big"`
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			data := []byte{0x00, 0xc0, 0xfe, 0xff, 0xff, 0x80}
+			var out sample
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, sample{Position: -1.25, Gain: 255.5}, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(out))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+
+	var wrong struct {
+		Value int32 `bin:"q16.16"`
+	}
+	err = NewBinDecoder([]byte{0x01, 0x02, 0x03, 0x04}).Decode(&wrong)
+	assert.EqualError(t, err, `error while decoding "Value" field: q16.16: expected a float field, got int32`)
+}
+
+func TestDecoder_NoneIf(t *testing.T) {
+	type sample struct {
+		Slot   *uint64 `bin:"none_if=18446744073709551615"`
+		Index  *int16  `bin:"none_if=-1 big"`
+		Parent *uint32 `bin:"none_if=0"`
+	}
+	slot, index := uint64(7), int16(-2)
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			data := []byte{
+				0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0xff, 0xfe,
+				0x00, 0x00, 0x00, 0x00,
+			}
+			var out sample
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, sample{Slot: &slot, Index: &index}, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(out))
+			assert.Equal(t, data, buf.Bytes())
+
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(sample{}))
+			assert.Equal(t, []byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff,
+				0x00, 0x00, 0x00, 0x00,
+			}, buf.Bytes())
+			out = sample{Slot: &slot}
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, sample{}, out)
+
+			zero := uint32(0)
+			err := NewEncoderWithEncoding(new(bytes.Buffer), encoding).Encode(sample{Parent: &zero})
+			assert.EqualError(t, err, `error while encoding "Parent" field: none_if: value 0 is the sentinel of an absent value`)
+		})
+	}
+
+	var invalid struct {
+		Value *uint8 `bin:"none_if=256"`
+	}
+	err := NewBinDecoder([]byte{0x01}).Decode(&invalid)
+	assert.EqualError(t, err, `error while decoding "Value" field: none_if: invalid uint8 sentinel "256": strconv.ParseUint: parsing "256": value out of range`)
+
+	size, ok := FixedSize(reflect.TypeOf(sample{}), EncodingBin)
+	assert.True(t, ok)
+	assert.Equal(t, 14, size)
+}
+
+func TestDecoder_InheritedOrder(t *testing.T) {
+	type header struct {
+		Magic   uint32
+		Version uint16
+		Flags   uint16 `bin:"little"`
+	}
+	type file struct {
+		Big    header `bin:"order=be"`
+		Native header
+		Size   uint16 `bin:"big"`
+	}
+	in := file{
+		Big:    header{Magic: 0x01020304, Version: 0x0506, Flags: 0x0708},
+		Native: header{Magic: 0x01020304, Version: 0x0506, Flags: 0x0708},
+		Size:   0x0a0b,
+	}
+	data := []byte{
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x08, 0x07,
+		0x04, 0x03, 0x02, 0x01, 0x06, 0x05, 0x08, 0x07,
+		0x0a, 0x0b,
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var out file
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+}
+
+func TestDecoder_InheritedOrderElements(t *testing.T) {
+	type body struct {
+		Array [2]uint16
+		Slice []uint32
+		Kind  uint8
+		Shape unionTestShape `bin:"union_tag=Kind"`
+		Token unionTestToken
+	}
+	type file struct {
+		Body body `bin:"order=be"`
+	}
+	in := file{Body: body{
+		Array: [2]uint16{0x0102, 0x0304},
+		Slice: []uint32{0x05060708},
+		Kind:  1,
+		Shape: &unionTestSquare{Side: 0x090a0b0c},
+		Token: unionTestNumber{Value: 0x0d0e},
+	}}
+	data := []byte{
+		0x01, 0x02, 0x03, 0x04,
+		0x01, 0x05, 0x06, 0x07, 0x08,
+		0x01, 0x09, 0x0a, 0x0b, 0x0c,
+		0x00, 0x0d, 0x0e,
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var out file
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+}
+
+type fieldPathRecorder struct {
+	path []string
+}
+
+func (r *fieldPathRecorder) UnmarshalWithDecoder(dec *Decoder) error {
+	r.path = dec.FieldPath()
+	return nil
+}
+
+func TestDecoder_FieldPath(t *testing.T) {
+	type inner struct {
+		A uint8
+		R fieldPathRecorder
+	}
+	type outer struct {
+		Inner inner
+		R     fieldPathRecorder
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			var out outer
+			dec := NewDecoderWithEncoding([]byte{0x01}, encoding)
+			require.NoError(t, dec.Decode(&out))
+			assert.Equal(t, []string{"Inner", "R"}, out.Inner.R.path)
+			assert.Equal(t, []string{"R"}, out.R.path)
+			assert.Empty(t, dec.FieldPath())
+		})
+	}
+}
+
+type generatedPoint struct {
+	X, Y uint16
+}
+
+func (p *generatedPoint) UnmarshalWithDecoder(dec *Decoder) (err error) {
+	if p.X, err = dec.ReadUint16(LE); err != nil {
+		return err
+	}
+	p.Y, err = dec.ReadUint16(LE)
+	return err
+}
+
+func TestDecoder_Decode_UnmarshalerFastPath(t *testing.T) {
+	if guardEnabled {
+		t.Skip("the bindebug guard allocates")
+	}
+	data := []byte{0x01, 0x00, 0x02, 0x00}
+	decoder := NewBinDecoder(data)
+	var p generatedPoint
+	allocs := testing.AllocsPerRun(100, func() {
+		decoder.pos = 0
+		if err := decoder.Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, generatedPoint{X: 1, Y: 2}, p)
+	assert.Zero(t, allocs)
+}
+
+type valueUnmarshaler struct{}
+
+func (valueUnmarshaler) UnmarshalWithDecoder(dec *Decoder) error {
+	return nil
+}
+
+func TestDecoder_Decode_UnmarshalerNonPointer(t *testing.T) {
+	err := NewBinDecoder([]byte{0x01}).Decode(valueUnmarshaler{})
+	assert.IsType(t, &InvalidDecoderError{}, err)
+
+	err = NewBinDecoder([]byte{0x01, 0x00, 0x02, 0x00}).Decode((*generatedPoint)(nil))
+	assert.IsType(t, &InvalidDecoderError{}, err)
+}
+
+func TestDecoder_SentinelErrors(t *testing.T) {
+	_, err := NewBinDecoder([]byte{0x01}).ReadUint32(LE)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.EqualError(t, err, "uint32 required [4] bytes, remaining [1]")
+
+	var s struct{ Name string }
+	err = NewBorshDecoder([]byte{0x05, 0x00, 0x00, 0x00, 'a'}).Decode(&s)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	dec := NewBorshDecoder([]byte{0x05, 0x00, 0x00, 0x00, 'a', 'b', 'c', 'd', 'e'})
+	dec.SetMaxStringLen(2)
+	_, err = dec.ReadString()
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	var u struct{ C complex64 }
+	err = NewBinDecoder([]byte{0x00}).Decode(&u)
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+	var f float64
+	err = NewBorshDecoder([]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x7f}).Decode(&f)
+	assert.True(t, errors.Is(err, ErrNaN))
+
+	// A varint longer than 64 bits is invalid, however much data follows.
+	overflow := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	_, err = NewBinDecoder(overflow).ReadUvarint64()
+	assert.Equal(t, ErrVarIntOverflow, err)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	assert.False(t, errors.Is(err, ErrShortBuffer))
+	_, err = NewBinDecoder(overflow).ReadVarint64()
+	assert.Equal(t, ErrVarIntOverflow, err)
+
+	_, err = NewBinDecoder([]byte{0xff}).ReadUvarint64()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// setDefaultValue sets target to the value parsed from the `bin:"default=..."` tag
+// of an absent optional: through a pointer, the pointed value is allocated,
+// and a nullable wrapper is marked as valid.
+// On error, target is left unchanged.
+func setDefaultValue(target reflect.Value, s string) error {
+	if target.Kind() == reflect.Ptr {
+		elem := reflect.New(target.Type().Elem())
+		if err := setDefaultValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	}
+
+	value := reflect.New(target.Type()).Elem()
+	if err := parseDefaultValue(setOptionalPresent(value), s); err != nil {
+		return err
+	}
+	target.Set(value)
+	return nil
+}
+
+func parseDefaultValue(rv reflect.Value, s string) error {
+	var err error
+	switch rv.Kind() {
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			rv.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 0, rv.Type().Bits()); err == nil {
+			rv.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 0, rv.Type().Bits()); err == nil {
+			rv.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, rv.Type().Bits()); err == nil {
+			rv.SetFloat(f)
+		}
+	case reflect.String:
+		rv.SetString(s)
+	default:
+		return fmt.Errorf("default: unsupported type %s", rv.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("default: invalid %s value %q: %w", rv.Type(), s, err)
+	}
+	return nil
+}
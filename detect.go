@@ -0,0 +1,133 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+)
+
+// lengthReader reads a length prefix from the start of data, returning the length,
+// the number of bytes the prefix takes, and whether the prefix is well-formed.
+type lengthReader func(data []byte) (length int, n int, ok bool)
+
+var detectableEncodings = []struct {
+	encoding   Encoding
+	readLength lengthReader
+}{
+	{EncodingBin, readBinLength},
+	{EncodingCompactU16, readCompactU16Length},
+	{EncodingBorsh, readBorshLength},
+}
+
+// DetectEncoding makes a best guess of the encoding of the provided data.
+//
+// The heuristic assumes the data is made of one or more length-prefixed
+// values (slices, strings, maps) and, for each encoding, checks how much of the data
+// can be consumed by walking those length prefixes; an encoding whose length prefixes
+// are malformed or run past the end of the data scores lower than one that
+// consumes the data exactly.
+//
+// The returned confidence is in the [0, 1] range, where 0 means that no encoding is
+// plausible, and 1 means that only the returned encoding is plausible.
+// This is meant for diagnostics and inspection tools; it's not a substitute
+// for knowing the encoding.
+func DetectEncoding(data []byte) (Encoding, float64) {
+	if len(data) == 0 {
+		return EncodingBin, 0
+	}
+
+	best := EncodingBin
+	bestScore := 0.0
+	total := 0.0
+	for _, candidate := range detectableEncodings {
+		score := scoreLengthPrefixed(data, candidate.readLength)
+		total += score
+		if score > bestScore {
+			best = candidate.encoding
+			bestScore = score
+		}
+	}
+	if bestScore == 0 {
+		return EncodingBin, 0
+	}
+	return best, bestScore / total
+}
+
+// scoreLengthPrefixed walks data as a sequence of length-prefixed values
+// and returns a score: the ratio of consumed bytes, doubled if the data
+// is consumed exactly.
+func scoreLengthPrefixed(data []byte, readLength lengthReader) float64 {
+	pos := 0
+	for pos < len(data) {
+		length, n, ok := readLength(data[pos:])
+		if !ok || length > len(data)-pos-n {
+			break
+		}
+		pos += n + length
+	}
+
+	score := float64(pos) / float64(len(data))
+	if pos == len(data) {
+		score *= 2
+	}
+	return score
+}
+
+func readBinLength(data []byte) (int, int, bool) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || l > uint64(len(data)) {
+		return 0, 0, false
+	}
+	// Non-minimal encodings (trailing zero groups) are valid
+	// for binary.Uvarint but never produced by an encoder.
+	if n > 1 && data[n-1] == 0 {
+		return 0, 0, false
+	}
+	return int(l), n, true
+}
+
+func readCompactU16Length(data []byte) (int, int, bool) {
+	ln := 0
+	for size := 0; size < 3; size++ {
+		if size >= len(data) {
+			return 0, 0, false
+		}
+		elem := int(data[size])
+		if size == 2 && elem > 0x03 {
+			// Would overflow a u16.
+			return 0, 0, false
+		}
+		ln |= (elem & 0x7f) << (size * 7)
+		if (elem & 0x80) == 0 {
+			if size > 0 && elem == 0 {
+				// Not in canonical form.
+				return 0, 0, false
+			}
+			return ln, size + 1, true
+		}
+	}
+	return 0, 0, false
+}
+
+func readBorshLength(data []byte) (int, int, bool) {
+	if len(data) < TypeSize.Uint32 {
+		return 0, 0, false
+	}
+	l := binary.LittleEndian.Uint32(data)
+	if uint64(l) > uint64(len(data)) {
+		return 0, 0, false
+	}
+	return int(l), TypeSize.Uint32, true
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	largeString := string(bytes.Repeat([]byte{'a'}, 300))
+
+	borsh, err := MarshalBorsh(struct {
+		A string
+		B []byte
+	}{"hello", []byte{1, 2, 3}})
+	require.NoError(t, err)
+	enc, confidence := DetectEncoding(borsh)
+	assert.Equal(t, EncodingBorsh, enc)
+	assert.Greater(t, confidence, 0.5)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewCompactU16Encoder(buf).WriteString(largeString))
+	enc, confidence = DetectEncoding(buf.Bytes())
+	assert.Equal(t, EncodingBin, enc)
+	assert.Equal(t, 0.5, confidence)
+
+	buf = new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WriteString(string(bytes.Repeat([]byte{'a'}, 1<<17))))
+	enc, confidence = DetectEncoding(buf.Bytes())
+	assert.Equal(t, EncodingBin, enc)
+	assert.Greater(t, confidence, 0.5)
+
+	enc, confidence = DetectEncoding([]byte{0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Equal(t, EncodingBin, enc)
+	assert.Equal(t, float64(0), confidence)
+
+	enc, confidence = DetectEncoding(nil)
+	assert.Equal(t, EncodingBin, enc)
+	assert.Equal(t, float64(0), confidence)
+}
@@ -19,7 +19,7 @@ package bin
 
 import (
 	"encoding/binary"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -35,6 +35,20 @@ type Encoder struct {
 	currentFieldOpt *option
 
 	encoding Encoding
+	// order is the default byte order for fixed-width
+	// numbers of fields that don't specify one.
+	order binary.ByteOrder
+	// cLayout forces the C layout on all the encoded structs,
+	// like the one of a Decoder; see Decoder.encoder.
+	cLayout bool
+
+	// namedInterfaces enables encoding interface values
+	// by type name; see SetNamedInterfaces.
+	namedInterfaces bool
+
+	// lengthEncoding overrides the length encoding
+	// of the encoding; see SetLengthEncoding.
+	lengthEncoding LengthEncoding
 }
 
 func (enc *Encoder) IsBorsh() bool {
@@ -49,6 +63,10 @@ func (enc *Encoder) IsCompactU16() bool {
 	return enc.encoding.IsCompactU16()
 }
 
+func (enc *Encoder) IsBitcoin() bool {
+	return enc.encoding.IsBitcoin()
+}
+
 func NewEncoderWithEncoding(writer io.Writer, enc Encoding) *Encoder {
 	if !isValidEncoding(enc) {
 		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
@@ -57,6 +75,20 @@ func NewEncoderWithEncoding(writer io.Writer, enc Encoding) *Encoder {
 		output:   writer,
 		count:    0,
 		encoding: enc,
+		order:    defaultByteOrder,
+	}
+}
+
+// subEncoder returns an encoder writing to the provided writer
+// with the same settings as enc.
+func (enc *Encoder) subEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		output:          writer,
+		encoding:        enc.encoding,
+		order:           enc.order,
+		cLayout:         enc.cLayout,
+		namedInterfaces: enc.namedInterfaces,
+		lengthEncoding:  enc.lengthEncoding,
 	}
 }
 
@@ -64,6 +96,14 @@ func NewBinEncoder(writer io.Writer) *Encoder {
 	return NewEncoderWithEncoding(writer, EncodingBin)
 }
 
+// NewBinEncoderWithOrder creates a Bin encoder that writes fixed-width
+// numbers with the provided byte order, unless a field tag says otherwise.
+func NewBinEncoderWithOrder(writer io.Writer, order binary.ByteOrder) *Encoder {
+	enc := NewBinEncoder(writer)
+	enc.order = order
+	return enc
+}
+
 func NewBorshEncoder(writer io.Writer) *Encoder {
 	return NewEncoderWithEncoding(writer, EncodingBorsh)
 }
@@ -72,9 +112,14 @@ func NewCompactU16Encoder(writer io.Writer) *Encoder {
 	return NewEncoderWithEncoding(writer, EncodingCompactU16)
 }
 
+func NewBitcoinEncoder(writer io.Writer) *Encoder {
+	return NewEncoderWithEncoding(writer, EncodingBitcoin)
+}
+
 func (e *Encoder) Encode(v interface{}) (err error) {
 	switch e.encoding {
-	case EncodingBin:
+	case EncodingBin, EncodingBitcoin:
+		// Bitcoin only differs from Bin by its length prefixes.
 		return e.encodeBin(reflect.ValueOf(v), nil)
 	case EncodingBorsh:
 		return e.encodeBorsh(reflect.ValueOf(v), nil)
@@ -120,25 +165,63 @@ func (e *Encoder) WriteLength(length int) error {
 	if traceEnabled {
 		zlog.Debug("encode: write length", zap.Int("len", length))
 	}
-	switch e.encoding {
-	case EncodingBin:
-		if err := e.WriteUVarInt(length); err != nil {
-			return err
+	kind := e.lengthEncoding
+	if kind == LengthEncodingDefault {
+		switch e.encoding {
+		case EncodingBin:
+			kind = LengthEncodingUvarint
+		case EncodingBorsh:
+			kind = LengthEncodingU32
+		case EncodingCompactU16:
+			kind = LengthEncodingCompactU16
+		case EncodingBitcoin:
+			kind = LengthEncodingCompactSize
+		default:
+			panic(fmt.Errorf("encoding not implemented: %s", e.encoding))
 		}
-	case EncodingBorsh:
-		if err := e.WriteUint32(uint32(length), LE); err != nil {
-			return err
+	}
+
+	switch kind {
+	case LengthEncodingUvarint:
+		return e.WriteUVarInt(length)
+	case LengthEncodingU16:
+		if uint64(length) > math.MaxUint16 {
+			return errorf(ErrInvalidLength, "length %d overflows a u16", length)
 		}
-	case EncodingCompactU16:
+		return e.WriteUint16(uint16(length), e.order)
+	case LengthEncodingU32:
+		if uint64(length) > math.MaxUint32 {
+			return errorf(ErrInvalidLength, "length %d overflows a u32", length)
+		}
+		return e.WriteUint32(uint32(length), e.order)
+	case LengthEncodingCompactU16:
 		var buf []byte
 		EncodeCompactU16Length(&buf, length)
-		if err := e.WriteBytes(buf, false); err != nil {
-			return err
-		}
+		return e.WriteBytes(buf, false)
+	case LengthEncodingBincodeVarint:
+		return e.WriteBincodeVarint(uint64(length))
+	case LengthEncodingCompactSize:
+		return e.WriteCompactSize(uint64(length))
 	default:
-		panic(fmt.Errorf("encoding not implemented: %s", e.encoding))
+		panic(fmt.Errorf("length encoding not implemented: %s", kind))
+	}
+}
+
+// SetLengthEncoding overrides the encoding of the length prefixes written by the encoder
+// (see WriteLength), independently of its Encoding; see Decoder.SetLengthEncoding.
+// Use LengthEncodingDefault to restore the length encoding of the Encoding.
+func (e *Encoder) SetLengthEncoding(kind LengthEncoding) {
+	e.lengthEncoding = kind
+}
+
+// writeByteLenFixedLength writes the length prefix, in bytes,
+// of count fixed-size elements of type elem.
+func (e *Encoder) writeByteLenFixedLength(elem reflect.Type, count int) error {
+	size, ok := staticSizeOf(elem)
+	if !ok || size == 0 || e.lengthEncoding == LengthEncodingBincodeVarint {
+		return fmt.Errorf("bytelen_fixed: element type %s doesn't have a fixed size", elem)
 	}
-	return nil
+	return e.WriteLength(count * size)
 }
 
 func (e *Encoder) WriteUVarInt(v int) (err error) {
@@ -151,6 +234,24 @@ func (e *Encoder) WriteUVarInt(v int) (err error) {
 	return e.toWriter(buf[:l])
 }
 
+// WriteUVarIntBE writes an unsigned varint encoded as big-endian
+// (most significant group first) 7-bit groups.
+// See Decoder.ReadUvarintBE.
+func (e *Encoder) WriteUVarIntBE(v uint64) (err error) {
+	if traceEnabled {
+		zlog.Debug("encode: write uvarint BE", zap.Uint64("val", v))
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return e.toWriter(buf[i:])
+}
+
 func (e *Encoder) WriteVarInt(v int) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write varint", zap.Int("val", v))
@@ -179,6 +280,22 @@ func (e *Encoder) WriteBool(b bool) (err error) {
 	return e.WriteByte(out)
 }
 
+// WriteTriBool writes a tri-state boolean byte: 0 for false, 1 for true, and 2 for nil (unknown).
+func (e *Encoder) WriteTriBool(b *bool) (err error) {
+	if b == nil {
+		return e.WriteByte(2)
+	}
+	return e.WriteBool(*b)
+}
+
+// encodeTriBool writes a `bin:"tribool"` *bool field.
+func (e *Encoder) encodeTriBool(rv reflect.Value) error {
+	if rv.Type() != reflect.TypeOf((*bool)(nil)) {
+		return fmt.Errorf("tribool: expected a *bool field, got %s", rv.Type())
+	}
+	return e.WriteTriBool(rv.Interface().(*bool))
+}
+
 func (e *Encoder) WriteUint8(i uint8) (err error) {
 	return e.WriteByte(i)
 }
@@ -215,6 +332,66 @@ func (e *Encoder) WriteUint32(i uint32, order binary.ByteOrder) (err error) {
 	return e.toWriter(buf)
 }
 
+// WriteUintN writes an unsigned integer on nBytes bytes (1 to 8),
+// for widths that are not a power of two, like 24-bit integers.
+func (e *Encoder) WriteUintN(i uint64, nBytes int, order binary.ByteOrder) (err error) {
+	if nBytes < 1 || nBytes > TypeSize.Uint64 {
+		return fmt.Errorf("uintN: invalid width of %d bytes", nBytes)
+	}
+	if nBytes < TypeSize.Uint64 && i>>(uint(nBytes)*8) != 0 {
+		return fmt.Errorf("uintN: value %d overflows %d bytes", i, nBytes)
+	}
+	if traceEnabled {
+		zlog.Debug("encode: write uintN", zap.Int("bytes", nBytes), zap.Uint64("val", i))
+	}
+	buf := make([]byte, TypeSize.Uint64)
+	order.PutUint64(buf, i)
+	if isBigEndian(order) {
+		return e.toWriter(buf[TypeSize.Uint64-nBytes:])
+	}
+	return e.toWriter(buf[:nBytes])
+}
+
+// WriteUint24 writes a 24-bit unsigned integer.
+func (e *Encoder) WriteUint24(i uint32, order binary.ByteOrder) (err error) {
+	return e.WriteUintN(uint64(i), 3, order)
+}
+
+// encodeU24 writes a `bin:"u24"` uint32 field.
+func (e *Encoder) encodeU24(rv reflect.Value, order binary.ByteOrder) error {
+	if rv.Kind() != reflect.Uint32 {
+		return fmt.Errorf("u24: expected a uint32 field, got %s", rv.Type())
+	}
+	return e.WriteUint24(uint32(rv.Uint()), order)
+}
+
+// WriteIntN writes a two's complement signed integer on nBytes bytes (1 to 8),
+// for widths that are not a power of two, like 24-bit integers.
+func (e *Encoder) WriteIntN(i int64, nBytes int, order binary.ByteOrder) (err error) {
+	if nBytes < 1 || nBytes > TypeSize.Uint64 {
+		return fmt.Errorf("intN: invalid width of %d bytes", nBytes)
+	}
+	shift := uint(64 - nBytes*8)
+	if (i<<shift)>>shift != i {
+		return fmt.Errorf("intN: value %d overflows %d bytes", i, nBytes)
+	}
+	// Drop the sign extension above the width.
+	return e.WriteUintN(uint64(i)<<shift>>shift, nBytes, order)
+}
+
+// WriteInt24 writes a 24-bit signed integer.
+func (e *Encoder) WriteInt24(i int32, order binary.ByteOrder) (err error) {
+	return e.WriteIntN(int64(i), 3, order)
+}
+
+// encodeIntN writes a `bin:"i24"` (or i40, i48, i56) signed integer field of nBytes bytes.
+func (e *Encoder) encodeIntN(rv reflect.Value, nBytes int, order binary.ByteOrder) error {
+	if err := checkIntNField(rv, nBytes); err != nil {
+		return err
+	}
+	return e.WriteIntN(rv.Int(), nBytes, order)
+}
+
 func (e *Encoder) WriteInt64(i int64, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write int64", zap.Int64("val", i))
@@ -257,8 +434,9 @@ func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
 	}
 
 	if e.IsBorsh() {
-		if float64(f) == math.NaN() {
-			return errors.New("NaN float value")
+		// Borsh forbids NaN, whose bit patterns aren't canonical.
+		if math.IsNaN(float64(f)) {
+			return errorf(ErrNaN, "float32: Borsh cannot encode NaN")
 		}
 	}
 
@@ -274,8 +452,9 @@ func (e *Encoder) WriteFloat64(f float64, order binary.ByteOrder) (err error) {
 	}
 
 	if e.IsBorsh() {
-		if float64(f) == math.NaN() {
-			return errors.New("NaN float value")
+		// Borsh forbids NaN, whose bit patterns aren't canonical.
+		if math.IsNaN(f) {
+			return errorf(ErrNaN, "float64: Borsh cannot encode NaN")
 		}
 	}
 	i := math.Float64bits(f)
@@ -292,6 +471,19 @@ func (e *Encoder) WriteString(s string) (err error) {
 	return e.WriteBytes([]byte(s), true)
 }
 
+// WriteJSON marshals v as a JSON document and writes it
+// as a length-prefixed byte slice.
+func (e *Encoder) WriteJSON(v interface{}) (err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("encode: write json", zap.ByteString("val", data))
+	}
+	return e.WriteBytes(data, true)
+}
+
 func (e *Encoder) WriteRustString(s string) (err error) {
 	err = e.WriteUint64(uint64(len(s)), binary.LittleEndian)
 	if err != nil {
@@ -312,6 +504,21 @@ func (e *Encoder) WriteCompactU16Length(ln int) (err error) {
 	return e.toWriter(buf)
 }
 
+// writePackedBools writes the first n booleans of the provided array or slice
+// bit-packed (least significant bit first).
+func (e *Encoder) writePackedBools(rv reflect.Value, n int) (err error) {
+	if traceEnabled {
+		zlog.Debug("encode: write packed bools", zap.Int("count", n))
+	}
+	buf := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		if rv.Index(i).Bool() {
+			buf[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return e.toWriter(buf)
+}
+
 // TODO: add rust string.
 // https://github.com/bmresearch/Solnet/blob/7826cc93ec6c997fc997a7a3c6be0f3511ca0c63/src/Solnet.Programs/Utilities/Serialization.cs#L219
 // public static byte[] EncodeRustString(string data)
@@ -77,7 +77,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	}
 
 	if opt.isOptional() {
-		if rv.IsZero() {
+		if !optionalIsPresent(rv, opt.Default) {
 			if traceEnabled {
 				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
 			}
@@ -89,10 +89,18 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 		// The optionality has been used; stop its propagation:
 		opt.setIsOptional(false)
+		rv = optionalPayload(rv)
 	}
 	// Reset optionality so it won't propagate to child types:
 	opt = opt.clone().setIsOptional(false)
 
+	if rv.Kind() == reflect.Interface && isUnionType(rv.Type()) {
+		return e.encodeInlineUnion(rv, LE)
+	}
+	if rv.Kind() == reflect.Interface && e.namedInterfaces {
+		return e.encodeNamedInterface(rv, LE)
+	}
+
 	if isZero(rv) {
 		return nil
 	}
@@ -107,6 +115,10 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		return marshaler.MarshalWithEncoder(e)
 	}
 
+	if e.lengthEncoding == LengthEncodingBincodeVarint && isBincodeVarintInt(rv) {
+		return e.encodeBincodeVarintInt(rv)
+	}
+
 	// Encode the value if it's a primitive type
 	isPrimitive, err := e.encodePrimitive(rv, nil)
 	if isPrimitive {
@@ -148,6 +160,10 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			if err := e.WriteBytes(arr, false); err != nil {
 				return err
 			}
+		} else if opt.isPacked() && rt.Elem().Kind() == reflect.Bool {
+			if err := e.writePackedBools(rv, l); err != nil {
+				return err
+			}
 		} else {
 			for i := 0; i < l; i++ {
 				if err = e.encodeBorsh(rv.Index(i), nil); err != nil {
@@ -162,9 +178,26 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			if traceEnabled {
 				zlog.Debug("encode: slice with sizeof set", zap.Int("size_of", l))
 			}
+		} else if opt.hasSVarintLen() {
+			if rv.IsNil() {
+				// A negative length denotes a null slice.
+				return e.WriteVarInt(-1)
+			}
+			l = rv.Len()
+			if err = e.WriteVarInt(l); err != nil {
+				return
+			}
+		} else if opt.hasFill() {
+			// The length is implied by the remaining bytes.
+			l = rv.Len()
+		} else if opt.hasByteLenFixed() {
+			l = rv.Len()
+			if err = e.writeByteLenFixedLength(rt.Elem(), l); err != nil {
+				return
+			}
 		} else {
 			l = rv.Len()
-			if err = e.WriteUint32(uint32(l), LE); err != nil {
+			if err = e.WriteLength(l); err != nil {
 				return
 			}
 		}
@@ -175,6 +208,9 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 		// we would want to skip to the correct head_offset
+		if opt.isPacked() && rt.Elem().Kind() == reflect.Bool {
+			return e.writePackedBools(rv, l)
+		}
 
 		for i := 0; i < l; i++ {
 			if err = e.encodeBorsh(rv.Index(i), nil); err != nil {
@@ -201,7 +237,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			zlog = zlog.Named("struct")
 		}
 
-		if err = e.WriteUint32(uint32(keyCount), LE); err != nil {
+		if err = e.WriteLength(keyCount); err != nil {
 			return
 		}
 
@@ -221,7 +257,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	// 		return e.encodeBorsh(rv.Elem(), opt)
 	// 	}
 	default:
-		return fmt.Errorf("encode: unsupported type %q", rt)
+		return errorf(ErrUnsupportedType, "encode: unsupported type %q", rt)
 	}
 	return
 }
@@ -284,8 +320,29 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 		}
 	}
 
+	cLayout := e.cLayout || hasCLayout(rt)
+	start := e.count
+
+	var presence *presenceBitmap
+	if hasPresenceBitmap(rt) {
+		if presence, err = e.writePresenceBitmap(rt, rv); err != nil {
+			return err
+		}
+	}
+
+	if hasFieldCount(rt) {
+		if err = e.writeFieldCount(rt); err != nil {
+			return err
+		}
+	}
+
+	fieldOrder, err := wireFieldOrder(rt)
+	if err != nil {
+		return err
+	}
+
 	sizeOfMap := map[string]int{}
-	for i := 0; i < l; i++ {
+	for _, i := range fieldOrder {
 		structField := rt.Field(i)
 		fieldTag := parseFieldTag(structField.Tag)
 
@@ -298,6 +355,55 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 			continue
 		}
 
+		if fieldTag.Unknown {
+			// The unknown bytes are encoded after the known fields.
+			continue
+		}
+
+		if presence != nil {
+			if i == 0 {
+				// The bitmap marker itself.
+				continue
+			}
+			if !presence.nextPresent() {
+				continue
+			}
+		}
+
+		if fieldTag.If != "" {
+			present, err := evalFieldCondition(rv, i, fieldTag.If)
+			if err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			if !present {
+				continue
+			}
+		}
+
+		if cLayout && rv.Field(i).CanInterface() {
+			if err := e.writeCPadding(start, structField.Type); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+		}
+
+		if fieldTag.UnionTag != "" {
+			if err := e.encodeUnionField(rt, rv, i, fieldTag.UnionTag, LE); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.PopcountLen != "" {
+			n, err := popcountLen(rv, i, fieldTag.PopcountLen)
+			if err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			if l := rv.Field(i).Len(); l != n {
+				return fmt.Errorf("error while encoding %q field: popcount_len: %d elements, but the mask %q has %d bits set", structField.Name, l, fieldTag.PopcountLen, n)
+			}
+			sizeOfMap[structField.Name] = n
+		}
+
 		rv := rv.Field(i)
 
 		if fieldTag.SizeOf != "" {
@@ -322,7 +428,12 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 
 		option := &option{
 			OptionalField: fieldTag.Optional,
+			Default:       fieldTag.Default,
 			Order:         fieldTag.Order,
+			Packed:        fieldTag.Packed,
+			SVarintLen:    fieldTag.SVarintLen,
+			ByteLenFixed:  fieldTag.ByteLenFixed,
+			Fill:          fieldTag.Fill,
 		}
 
 		if s, ok := sizeOfMap[structField.Name]; ok {
@@ -341,11 +452,137 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 			)
 		}
 
+		if fieldTag.Codec != "" {
+			if err := e.encodeWithFieldCodec(fieldTag.Codec, rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.Rest {
+			if err := e.encodeRestField(rt, i, rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.FillRest {
+			if err := e.encodeFillRestField(rt, i, rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.TrailerLen {
+			if err := e.encodeTrailerLenField(rt, i, rv, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.Continuation != "" {
+			if err := e.encodeContinuation(rv, fieldTag.Continuation); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.U24 {
+			if err := e.encodeU24(rv, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.IntN != 0 {
+			if err := e.encodeIntN(rv, fieldTag.IntN, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.Q != nil {
+			if err := e.encodeQ(rv, fieldTag.Q, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.NoneIf != nil {
+			if err := e.encodeNoneIf(rv, *fieldTag.NoneIf, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.GUID {
+			if err := e.encodeGUID(rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.TriBool {
+			if err := e.encodeTriBool(rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.RFC3339 {
+			if err := e.encodeRFC3339(rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.BigFloat {
+			if err := e.encodeBigFloat(rv); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.ASCIIStr {
+			if err := e.encodeASCIIStr(rv, fieldTag.ASCIIStrLen); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.FixedInner != 0 {
+			if err := e.encodeFixedInner(rv, fieldTag.FixedInner); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.Lazy != 0 {
+			if err := e.encodeLazyNumber(rv, fieldTag.Lazy); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		if fieldTag.Runes != "" {
+			if err := e.encodeRunes(rv, fieldTag.Runes, option.Order); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
 		if err := e.encodeBorsh(rv, option); err != nil {
 			return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
 		}
 	}
-	return nil
+
+	if cLayout {
+		// Trailing padding, up to the alignment of the struct itself.
+		if err := e.writeCPadding(start, rt); err != nil {
+			return err
+		}
+	}
+	return e.encodeUnknownField(rt, rv)
 }
 
 func vComp(keys []reflect.Value) func(int, int) bool {
@@ -369,6 +606,10 @@ func vComp(keys []reflect.Value) func(int, int) bool {
 		case reflect.String:
 			return a.String() < b.String()
 		}
+		// Byte arrays and bools, in the order checked by WithStrictMapOrder.
+		if cmp, err := compareMapKeys(a, b); err == nil {
+			return cmp < 0
+		}
 		panic("unsupported key compare")
 	}
 }
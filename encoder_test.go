@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
 	"testing"
 
@@ -641,3 +642,47 @@ func TestEncoder_InterfaceNil(t *testing.T) {
 	err := enc.Encode(foo)
 	assert.NoError(t, err)
 }
+
+func TestEncoder_BorshNaN(t *testing.T) {
+	_, err := MarshalBorsh(math.NaN())
+	assert.True(t, errors.Is(err, ErrNaN))
+	assert.EqualError(t, err, "float64: Borsh cannot encode NaN")
+
+	_, err = MarshalBorsh(float32(math.NaN()))
+	assert.EqualError(t, err, "float32: Borsh cannot encode NaN")
+
+	// Bin has no such restriction.
+	_, err = MarshalBin(math.NaN())
+	assert.NoError(t, err)
+}
+
+func TestEncoder_WriteLength(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf)
+	require.NoError(t, enc.WriteLength(math.MaxUint32))
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, buf.Bytes())
+
+	err := enc.WriteLength(math.MaxUint32 + 1)
+	assert.EqualError(t, err, "length 4294967296 overflows a u32")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	err = enc.WriteLength(-1)
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+
+	enc.SetLengthEncoding(LengthEncodingU16)
+	err = enc.WriteLength(math.MaxUint16 + 1)
+	assert.EqualError(t, err, "length 65536 overflows a u16")
+	assert.True(t, errors.Is(err, ErrInvalidLength))
+	assert.Equal(t, 4, buf.Len())
+}
+
+func TestEncoder_NilSelfReferentialPointer(t *testing.T) {
+	type node struct {
+		V    uint8
+		Next *node
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(node{V: 1}))
+		assert.Equal(t, []byte{0x01}, buf.Bytes())
+	}
+}
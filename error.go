@@ -14,7 +14,49 @@
 
 package bin
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Sentinel errors wrapped by the errors returned when decoding and encoding,
+// to be matched with errors.Is.
+var (
+	// ErrShortBuffer reports that the data ends before the value being decoded;
+	// more bytes may make decoding succeed.
+	ErrShortBuffer = errors.New("short buffer")
+	// ErrInvalidLength reports a length that is invalid for the value being decoded.
+	ErrInvalidLength = errors.New("invalid length")
+	// ErrNonCanonical reports an encoding that differs from the canonical one.
+	ErrNonCanonical = errors.New("non-canonical encoding")
+	// ErrUnsupportedType reports a Go type that cannot be decoded or encoded.
+	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrNaN reports a NaN float that is not allowed.
+	ErrNaN = errors.New("NaN float value")
+	// ErrInf reports an infinite float rejected by a FloatPolicy.
+	ErrInf = errors.New("infinite float value")
+)
+
+// kindError is an error with its own message, which wraps one of the sentinel errors.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// errorf formats an error message like fmt.Errorf, and returns
+// an error with this message that wraps the provided sentinel error.
+func errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
 
 // An InvalidDecoderError describes an invalid argument passed to Decoder.
 // (The argument to Decoder must be a non-nil pointer.)
@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// structFieldCounts caches the field count settings of struct types, by type.
+var structFieldCounts sync.Map
+
+type structFieldCount struct {
+	// has is true if the struct has a `bin:"field_count"` field.
+	has bool
+	// counted is the number of fields counted by the prefix.
+	counted int
+}
+
+func fieldCountOf(rt reflect.Type) *structFieldCount {
+	if cached, ok := structFieldCounts.Load(rt); ok {
+		return cached.(*structFieldCount)
+	}
+	out := &structFieldCount{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field.Tag)
+		if tag.FieldCount {
+			out.has = true
+		}
+		if field.PkgPath == "" && !tag.Skip && !tag.Unknown {
+			out.counted++
+		}
+	}
+	structFieldCounts.Store(rt, out)
+	return out
+}
+
+// hasFieldCount returns true if one of the fields of the struct type
+// is tagged with `bin:"field_count"`, usually a blank marker field:
+//
+//	type Record struct {
+//		_     struct{} `bin:"field_count"`
+//		ID    uint32
+//		Label string // added in a later version
+//	}
+//
+// Such a struct is prefixed with the number of its encoded fields, as a length.
+// When decoding, only that many fields are read, in wire order, and the
+// following ones are set to their zero value, so that a newer struct can decode
+// data encoded with an older version that had fewer fields.
+func hasFieldCount(rt reflect.Type) bool {
+	return fieldCountOf(rt).has
+}
+
+// countedFieldCount returns the number of fields of the
+// struct type that are counted by a field count prefix.
+func countedFieldCount(rt reflect.Type) int {
+	return fieldCountOf(rt).counted
+}
+
+// readFieldCount reads the field count prefix of the struct type rt.
+func (dec *Decoder) readFieldCount(rt reflect.Type) (int, error) {
+	if hasPresenceBitmap(rt) {
+		return 0, fmt.Errorf("field_count: not supported along with a presence bitmap")
+	}
+	count, err := dec.ReadLength()
+	if err != nil {
+		return 0, fmt.Errorf("field_count: %w", err)
+	}
+	if max := countedFieldCount(rt); count > max {
+		return 0, errorf(ErrInvalidLength, "field_count: %d fields present but %s only has %d", count, rt, max)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read field count", zap.Int("count", count))
+	}
+	return count, nil
+}
+
+// writeFieldCount writes the field count prefix of the struct type rt.
+func (e *Encoder) writeFieldCount(rt reflect.Type) error {
+	if hasPresenceBitmap(rt) {
+		return fmt.Errorf("field_count: not supported along with a presence bitmap")
+	}
+	return e.WriteLength(countedFieldCount(rt))
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// structFieldOrders caches the wire order of the fields of struct types, by type.
+var structFieldOrders sync.Map
+
+type structFieldOrder struct {
+	indices []int
+	err     error
+}
+
+// wireFieldOrder returns the indices of the fields of the struct type rt
+// in the order they appear on the wire.
+//
+// A field tagged with order_index=N is at wire position N; the other fields
+// keep their declaration index as wire position, and fields with the same
+// position keep their declaration order.
+func wireFieldOrder(rt reflect.Type) ([]int, error) {
+	if cached, ok := structFieldOrders.Load(rt); ok {
+		order := cached.(*structFieldOrder)
+		return order.indices, order.err
+	}
+	indices, err := newWireFieldOrder(rt)
+	structFieldOrders.Store(rt, &structFieldOrder{indices: indices, err: err})
+	return indices, err
+}
+
+func newWireFieldOrder(rt reflect.Type) ([]int, error) {
+	l := rt.NumField()
+	indices := make([]int, l)
+	positions := make([]int, l)
+	seen := map[int]string{}
+	for i := 0; i < l; i++ {
+		indices[i] = i
+		positions[i] = i
+
+		field := rt.Field(i)
+		tag := parseFieldTag(field.Tag)
+		if !tag.OrderIndexSet {
+			continue
+		}
+		if tag.OrderIndex < 0 {
+			return nil, fmt.Errorf("order_index: invalid wire position for %q field", field.Name)
+		}
+		if other, found := seen[tag.OrderIndex]; found {
+			return nil, fmt.Errorf("order_index: %q and %q fields have the same wire position %d", other, field.Name, tag.OrderIndex)
+		}
+		if hasPresenceBitmap(rt) {
+			return nil, fmt.Errorf("order_index: not supported along with a presence bitmap")
+		}
+		seen[tag.OrderIndex] = field.Name
+		positions[i] = tag.OrderIndex
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return positions[indices[a]] < positions[indices[b]]
+	})
+	return indices, nil
+}
+
+// fieldPrecedes returns true if the field at index a of the struct type rt
+// precedes the field at index b on the wire.
+func fieldPrecedes(rt reflect.Type, a, b int) (bool, error) {
+	order, err := wireFieldOrder(rt)
+	if err != nil {
+		return false, err
+	}
+	for _, i := range order {
+		if i == b {
+			return false, nil
+		}
+		if i == a {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isLastWireField reports whether the field at index i is the last field of the
+// struct type rt on the wire, ignoring the skipped and unexported fields after it.
+func isLastWireField(rt reflect.Type, i int) (bool, error) {
+	order, err := wireFieldOrder(rt)
+	if err != nil {
+		return false, err
+	}
+	for j := len(order) - 1; j >= 0; j-- {
+		field := rt.Field(order[j])
+		if field.PkgPath != "" || parseFieldTag(field.Tag).Skip {
+			continue
+		}
+		return order[j] == i, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+func checkFixedInner(rv reflect.Value, size int) error {
+	rt := rv.Type()
+	if rt.Kind() != reflect.Slice || rt.Elem().Kind() != reflect.Slice || rt.Elem().Elem().Kind() != reflect.Uint8 {
+		return fmt.Errorf("fixed_inner: expected a [][]byte field, got %s", rt)
+	}
+	if size <= 0 {
+		return fmt.Errorf("fixed_inner: the tag must specify a positive byte count, like fixed_inner=32")
+	}
+	return nil
+}
+
+// decodeFixedInner reads a `bin:"fixed_inner=N"` field: the outer length,
+// followed by that many N-byte slices without a length prefix.
+func (dec *Decoder) decodeFixedInner(rv reflect.Value, size int) error {
+	if err := checkFixedInner(rv, size); err != nil {
+		return err
+	}
+	l, err := dec.ReadLength()
+	if err != nil {
+		return fmt.Errorf("fixed_inner: %w", err)
+	}
+	if l > dec.Remaining()/size {
+		return fmt.Errorf("fixed_inner: %w", errorf(ErrShortBuffer, "%d elements of %d bytes, remaining [%d]", l, size, dec.Remaining()))
+	}
+	data, err := dec.ReadNBytes(l * size)
+	if err != nil {
+		return fmt.Errorf("fixed_inner: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read fixed inner slices", zap.Int("count", l), zap.Int("size", size))
+	}
+
+	// All the inner slices share a single copy of the data.
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	out := reflect.MakeSlice(rv.Type(), l, l)
+	for i := 0; i < l; i++ {
+		out.Index(i).SetBytes(buf[i*size : (i+1)*size : (i+1)*size])
+	}
+	rv.Set(out)
+	dec.reportProgress()
+	return nil
+}
+
+// encodeFixedInner writes a `bin:"fixed_inner=N"` field: the outer length,
+// followed by the inner slices, which must all be N bytes long.
+func (e *Encoder) encodeFixedInner(rv reflect.Value, size int) error {
+	if err := checkFixedInner(rv, size); err != nil {
+		return err
+	}
+	l := rv.Len()
+	for i := 0; i < l; i++ {
+		if n := rv.Index(i).Len(); n != size {
+			return fmt.Errorf("fixed_inner: element %d has %d bytes, expected %d", i, n, size)
+		}
+	}
+	if err := e.WriteLength(l); err != nil {
+		return err
+	}
+	for i := 0; i < l; i++ {
+		if err := e.toWriter(rv.Index(i).Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
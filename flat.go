@@ -0,0 +1,188 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// flatStruct is the decoding plan of a struct whose fields are all
+// exported fixed-width primitives, which are decoded directly,
+// without allocating per-field options.
+type flatStruct struct {
+	fields []flatField
+}
+
+type flatField struct {
+	index    int
+	name     string
+	kind     reflect.Kind
+	order    binary.ByteOrder
+	orderSet bool
+}
+
+func (f *flatField) orderOr(fallback binary.ByteOrder) binary.ByteOrder {
+	if f.orderSet {
+		return f.order
+	}
+	return fallback
+}
+
+// flatStructs caches the plans of struct types, by type;
+// the plan of a struct that is not flat is nil.
+var flatStructs sync.Map
+
+// flatStructOf returns the decoding plan of the struct type rt,
+// or nil if the struct is not flat.
+func flatStructOf(rt reflect.Type) *flatStruct {
+	if cached, ok := flatStructs.Load(rt); ok {
+		return cached.(*flatStruct)
+	}
+	plan := newFlatStruct(rt)
+	flatStructs.Store(rt, plan)
+	return plan
+}
+
+func newFlatStruct(rt reflect.Type) *flatStruct {
+	if reflect.PtrTo(rt).Implements(unmarshalableType) || rt.Implements(unmarshalableType) {
+		return nil
+	}
+	if rt.NumField() == 0 || hasPresenceBitmap(rt) {
+		return nil
+	}
+
+	plan := &flatStruct{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field.Tag)
+		if tag.Skip {
+			continue
+		}
+		// Only the byte order tags are supported.
+		if *tag != (fieldTag{Order: tag.Order, OrderSet: tag.OrderSet}) {
+			return nil
+		}
+		if field.PkgPath != "" {
+			// Unexported.
+			return nil
+		}
+		if reflect.PtrTo(field.Type).Implements(unmarshalableType) || field.Type.Implements(unmarshalableType) {
+			return nil
+		}
+		switch field.Type.Kind() {
+		case reflect.Bool,
+			reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return nil
+		}
+		plan.fields = append(plan.fields, flatField{
+			index:    i,
+			name:     field.Name,
+			kind:     field.Type.Kind(),
+			order:    tag.Order,
+			orderSet: tag.OrderSet,
+		})
+	}
+	return plan
+}
+
+// decodeFlatStruct decodes the fields of the settable struct rv,
+// following the provided plan; the untagged fields are decoded with order.
+func (dec *Decoder) decodeFlatStruct(rv reflect.Value, plan *flatStruct, order binary.ByteOrder) (err error) {
+	for _, field := range plan.fields {
+		// Borsh numbers are always little endian.
+		fieldOrder := LE
+		if !dec.IsBorsh() {
+			fieldOrder = field.orderOr(order)
+		}
+
+		v := rv.Field(field.index)
+		switch field.kind {
+		case reflect.Bool:
+			var n bool
+			n, err = dec.ReadBool()
+			v.SetBool(n)
+		case reflect.Int8:
+			var n int8
+			n, err = dec.ReadInt8()
+			v.SetInt(int64(n))
+		case reflect.Int16:
+			var n int16
+			n, err = dec.ReadInt16(fieldOrder)
+			v.SetInt(int64(n))
+		case reflect.Int32:
+			var n int32
+			n, err = dec.ReadInt32(fieldOrder)
+			v.SetInt(int64(n))
+		case reflect.Int64:
+			var n int64
+			n, err = dec.ReadInt64(fieldOrder)
+			v.SetInt(n)
+		case reflect.Uint8:
+			var n uint8
+			n, err = dec.ReadUint8()
+			v.SetUint(uint64(n))
+		case reflect.Uint16:
+			var n uint16
+			n, err = dec.ReadUint16(fieldOrder)
+			v.SetUint(uint64(n))
+		case reflect.Uint32:
+			var n uint32
+			n, err = dec.ReadUint32(fieldOrder)
+			v.SetUint(uint64(n))
+		case reflect.Uint64:
+			var n uint64
+			n, err = dec.ReadUint64(fieldOrder)
+			v.SetUint(n)
+		case reflect.Float32:
+			var n float32
+			n, err = dec.ReadFloat32(fieldOrder)
+			v.SetFloat(float64(n))
+		case reflect.Float64:
+			var n float64
+			n, err = dec.ReadFloat64(fieldOrder)
+			v.SetFloat(n)
+		}
+		if err != nil {
+			return fmt.Errorf("error while decoding %q field: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// canDecodeFlat reports whether the settings of dec allow decoding structs
+// with a flat struct plan, which reads fixed-width fields one after the other.
+func (dec *Decoder) canDecodeFlat() bool {
+	return !dec.cLayout && dec.fieldHook == nil && dec.spans == nil && dec.lengthEncoding != LengthEncodingBincodeVarint
+}
+
+// flatStructTarget returns the struct pointed to by v if it can be
+// decoded with a flat struct plan, and its plan.
+func (dec *Decoder) flatStructTarget(v interface{}) (reflect.Value, *flatStruct) {
+	if !dec.canDecodeFlat() {
+		return reflect.Value{}, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil
+	}
+	rv = rv.Elem()
+	return rv, flatStructOf(rv.Type())
+}
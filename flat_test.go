@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flatTestHeader struct {
+	Version  uint8
+	Flags    uint16
+	Length   uint32 `bin:"big"`
+	Offset   int64
+	Ratio    float32
+	Valid    bool
+	Ignored  string `bin:"-"`
+	Checksum uint64
+}
+
+func TestFlatStruct(t *testing.T) {
+	assert.NotNil(t, flatStructOf(reflect.TypeOf(flatTestHeader{})))
+	assert.Nil(t, flatStructOf(reflect.TypeOf(struct{ A string }{})))
+	assert.Nil(t, flatStructOf(reflect.TypeOf(struct{ A Int64 }{})))
+	assert.Nil(t, flatStructOf(reflect.TypeOf(struct{ a uint8 }{})))
+	assert.Nil(t, flatStructOf(reflect.TypeOf(struct {
+		A uint8 `bin:"optional"`
+	}{})))
+
+	in := flatTestHeader{Version: 1, Flags: 2, Length: 3, Offset: -4, Ratio: 0.5, Valid: true, Checksum: 6}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+
+			var out flatTestHeader
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&out))
+			assert.Equal(t, in, out)
+
+			// As a nested struct.
+			var nested struct {
+				Headers []flatTestHeader
+			}
+			buf = new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(struct{ Headers []flatTestHeader }{[]flatTestHeader{in, in}}))
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).Decode(&nested))
+			assert.Equal(t, []flatTestHeader{in, in}, nested.Headers)
+
+			err := NewDecoderWithEncoding(buf.Bytes()[:3], encoding).Decode(&out)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFlatStruct_ZeroAllocs(t *testing.T) {
+	if guardEnabled {
+		t.Skip("the bindebug guard allocates")
+	}
+	data, err := MarshalBin(flatTestHeader{Version: 1, Checksum: 6})
+	require.NoError(t, err)
+
+	var out flatTestHeader
+	dec := NewBinDecoder(data)
+	allocs := testing.AllocsPerRun(100, func() {
+		dec.pos = 0
+		if err := dec.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkDecodeFlatStruct(b *testing.B) {
+	data, err := MarshalBin(flatTestHeader{Version: 1, Checksum: 6})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var out flatTestHeader
+	dec := NewBinDecoder(data)
+	setupBench(b)
+	for i := 0; i < b.N; i++ {
+		dec.pos = 0
+		if err := dec.Decode(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+
+	"go.uber.org/zap"
+)
+
+// The vtable starts with its own size and the size of the table, both uint16.
+const flatBufferVTableHeaderSize = 4
+
+// ReadTableField locates a field of a FlatBuffers table, without a schema.
+//
+// The tableOffset is the absolute position of the table in the data
+// (for the root table, the little-endian uint32 at the start of the buffer),
+// and fieldID is the index of the field in the table definition.
+// The table's vtable is found by following the signed offset at the start
+// of the table, and the returned decoder, which shares the data of dec,
+// is positioned at the field's inline value.
+//
+// It returns false if the field is absent from the table (e.g. set to its default value),
+// or if the table or its vtable is out of the bounds of the data.
+func (dec *Decoder) ReadTableField(tableOffset, fieldID int) (*Decoder, bool) {
+	if tableOffset < 0 || fieldID < 0 || tableOffset+TypeSize.Uint32 > len(dec.data) {
+		return nil, false
+	}
+	vtableOffset := tableOffset - int(int32(binary.LittleEndian.Uint32(dec.data[tableOffset:])))
+	if vtableOffset < 0 || vtableOffset+flatBufferVTableHeaderSize > len(dec.data) {
+		return nil, false
+	}
+	vtableSize := int(binary.LittleEndian.Uint16(dec.data[vtableOffset:]))
+	if vtableOffset+vtableSize > len(dec.data) {
+		return nil, false
+	}
+
+	entry := flatBufferVTableHeaderSize + fieldID*TypeSize.Uint16
+	if entry+TypeSize.Uint16 > vtableSize {
+		// Fields added after the table was written are absent.
+		return nil, false
+	}
+	fieldOffset := int(binary.LittleEndian.Uint16(dec.data[vtableOffset+entry:]))
+	if fieldOffset == 0 || tableOffset+fieldOffset >= len(dec.data) {
+		return nil, false
+	}
+
+	if traceEnabled {
+		zlog.Debug("decode: flatbuffers table field",
+			zap.Int("table", tableOffset),
+			zap.Int("vtable", vtableOffset),
+			zap.Int("field_id", fieldID),
+			zap.Int("field_offset", fieldOffset),
+		)
+	}
+	return &Decoder{
+		data:     dec.data,
+		pos:      tableOffset + fieldOffset,
+		encoding: dec.encoding,
+		order:    binary.LittleEndian,
+	}, true
+}
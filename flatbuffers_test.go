@@ -0,0 +1,71 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_ReadTableField(t *testing.T) {
+	// table Monster { hp: short; mana: short; id: uint; }
+	// with hp=300, mana absent (default), id=7.
+	data := []byte{
+		0x10, 0x00, 0x00, 0x00, // root table offset
+		// vtable
+		0x0a, 0x00, // vtable size
+		0x0c, 0x00, // table size
+		0x08, 0x00, // hp
+		0x00, 0x00, // mana (absent)
+		0x04, 0x00, // id
+		0x00, 0x00, // padding
+		// table
+		0x0c, 0x00, 0x00, 0x00, // soffset to the vtable
+		0x07, 0x00, 0x00, 0x00, // id
+		0x2c, 0x01, // hp
+		0x00, 0x00, // padding
+	}
+
+	dec := NewBinDecoder(data)
+	root, err := dec.ReadUint32(LE)
+	require.NoError(t, err)
+
+	field, ok := dec.ReadTableField(int(root), 0)
+	require.True(t, ok)
+	hp, err := field.ReadInt16(LE)
+	require.NoError(t, err)
+	assert.Equal(t, int16(300), hp)
+
+	_, ok = dec.ReadTableField(int(root), 1)
+	assert.False(t, ok)
+
+	field, ok = dec.ReadTableField(int(root), 2)
+	require.True(t, ok)
+	id, err := field.ReadUint32(LE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), id)
+
+	// Beyond the vtable: a field added to the schema later.
+	_, ok = dec.ReadTableField(int(root), 3)
+	assert.False(t, ok)
+
+	// The parent decoder is left untouched.
+	assert.Equal(t, uint(4), dec.Position())
+
+	_, ok = dec.ReadTableField(len(data), 0)
+	assert.False(t, ok)
+}
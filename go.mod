@@ -8,6 +8,7 @@ require (
 	github.com/AlekSi/pointer v1.1.0
 	github.com/davecgh/go-spew v1.1.1
 	github.com/dfuse-io/logging v0.0.0-20201110202154-26697de88c79
+	github.com/klauspost/compress v1.12.3
 	github.com/kr/pretty v0.2.1 // indirect
 	github.com/shopspring/decimal v1.3.1
 	github.com/stretchr/testify v1.4.0
@@ -0,0 +1,29 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !bindebug
+// +build !bindebug
+
+package bin
+
+// guardEnabled reports whether the concurrent use of a Decoder is detected.
+const guardEnabled = false
+
+// decoderGuard is a no-op outside of builds with the bindebug tag;
+// see guard_bindebug.go.
+type decoderGuard struct{}
+
+func (g *decoderGuard) enter() {}
+
+func (g *decoderGuard) exit() {}
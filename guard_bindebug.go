@@ -0,0 +1,74 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build bindebug
+// +build bindebug
+
+package bin
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// guardEnabled reports whether the concurrent use of a Decoder is detected.
+const guardEnabled = true
+
+// decoderGuard detects the concurrent use of a Decoder, which is not safe
+// for concurrent use. It only exists in builds with the bindebug tag.
+//
+// Nested Decode calls from the same goroutine (e.g. from an UnmarshalWithDecoder method)
+// are allowed, so the guard tracks the goroutine that owns the decoder.
+type decoderGuard struct {
+	owner int64
+	depth int
+}
+
+func (g *decoderGuard) enter() {
+	id := goroutineID()
+	if atomic.CompareAndSwapInt64(&g.owner, 0, id) {
+		g.depth = 1
+		return
+	}
+	if atomic.LoadInt64(&g.owner) != id {
+		panic("bin: concurrent use of a Decoder from multiple goroutines; a Decoder is not safe for concurrent use")
+	}
+	g.depth++
+}
+
+func (g *decoderGuard) exit() {
+	g.depth--
+	if g.depth == 0 {
+		atomic.StoreInt64(&g.owner, 0)
+	}
+}
+
+// goroutineID returns the ID of the current goroutine, parsed from
+// the header of its stack trace ("goroutine 18 [running]:").
+// This is slow, and only meant for debugging.
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, err := strconv.ParseInt(string(buf), 10, 64)
+	if err != nil {
+		panic("bin: cannot parse goroutine ID: " + err.Error())
+	}
+	return id
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build bindebug
+// +build bindebug
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderGuard(t *testing.T) {
+	data, err := MarshalBorsh([]uint64{1, 2, 3})
+	require.NoError(t, err)
+
+	dec := NewBorshDecoder(data)
+	// Simulate a Decode call in progress on another goroutine.
+	dec.guard.owner = goroutineID() + 1
+	assert.PanicsWithValue(t, "bin: concurrent use of a Decoder from multiple goroutines; a Decoder is not safe for concurrent use", func() {
+		var out []uint64
+		dec.Decode(&out)
+	})
+
+	// Nested Decode calls from the same goroutine are allowed.
+	dec = NewBorshDecoder(data)
+	dec.guard.enter()
+	var out []uint64
+	assert.NoError(t, dec.Decode(&out))
+	dec.guard.exit()
+	assert.Equal(t, int64(0), dec.guard.owner)
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecoder_SeparateDecodersConcurrently is meant to be run with -race:
+// decoders are not safe for concurrent use, but separate decoders
+// over the same data are.
+func TestDecoder_SeparateDecodersConcurrently(t *testing.T) {
+	data, err := MarshalBorsh([]uint64{1, 2, 3})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out []uint64
+			assert.NoError(t, NewBorshDecoder(data).Decode(&out))
+			assert.Equal(t, []uint64{1, 2, 3}, out)
+		}()
+	}
+	wg.Wait()
+}
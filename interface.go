@@ -19,13 +19,29 @@ package bin
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
 )
 
 type BinaryMarshaler interface {
 	MarshalWithEncoder(encoder *Encoder) error
 }
 
+// BinaryUnmarshaler is implemented by types that decode themselves, e.g. with
+// code generated for speed, bypassing reflection entirely. Since Go interfaces
+// are satisfied structurally, it is the interface to implement for generated
+// decoders: any type with an UnmarshalWithDecoder(*Decoder) error method is one.
+//
+// Decode calls it directly when given a value that implements it.
+// Within a reflection-decoded value, the method of a field type takes precedence
+// over the reflection-based decoding of that type, but the field-level tags
+// (optional, codec, union_tag, rest, ...) are applied first: e.g. the presence flag
+// of an optional field is read before calling UnmarshalWithDecoder, and a field
+// with a codec is decoded by the codec only.
 type BinaryUnmarshaler interface {
 	UnmarshalWithDecoder(decoder *Decoder) error
 }
@@ -71,6 +87,100 @@ func UnmarshalCompactU16(v interface{}, b []byte) error {
 	return decoder.Decode(v)
 }
 
+// DecodeTry decodes data into v with each of the provided encodings in turn, on a fresh
+// decoder and a fresh value each time, and returns the first encoding that decodes
+// all of data without leaving trailing bytes; v is only set on success.
+// If none succeeds, the returned error reports the failure of each encoding.
+func DecodeTry(data []byte, v interface{}, encodings ...Encoding) (Encoding, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return 0, &InvalidDecoderError{reflect.TypeOf(v)}
+	}
+	if len(encodings) == 0 {
+		return 0, fmt.Errorf("decode try: no encodings provided")
+	}
+
+	failures := &decodeTryError{}
+	for _, enc := range encodings {
+		out := reflect.New(rv.Type().Elem())
+		decoder := NewDecoderWithEncoding(data, enc)
+		err := decoder.Decode(out.Interface())
+		if err == nil && decoder.Remaining() > 0 {
+			err = fmt.Errorf("%d trailing bytes", decoder.Remaining())
+		}
+		if err != nil {
+			failures.encodings = append(failures.encodings, enc)
+			failures.errs = append(failures.errs, err)
+			continue
+		}
+		rv.Elem().Set(out.Elem())
+		return enc, nil
+	}
+	return 0, failures
+}
+
+// decodeTryError reports the failure of each encoding tried by DecodeTry,
+// and matches any of them with errors.Is and errors.As.
+type decodeTryError struct {
+	encodings []Encoding
+	errs      []error
+}
+
+func (e *decodeTryError) Error() string {
+	failures := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		failures[i] = fmt.Sprintf("%s: %s", e.encodings[i], err)
+	}
+	return "decode try: no encoding succeeded: " + strings.Join(failures, "; ")
+}
+
+func (e *decodeTryError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *decodeTryError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDecoderFromHex creates a decoder with the provided encoding
+// for the hex-encoded data, which may have a 0x prefix and contain whitespace.
+func NewDecoderFromHex(s string, enc Encoding) (*Decoder, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode hex: %w", err)
+	}
+	return NewDecoderWithEncoding(data, enc), nil
+}
+
+// EncodeHex encodes v with the provided encoding, and returns
+// the result as a hex string, without 0x prefix.
+func EncodeHex(v interface{}, enc Encoding) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoderWithEncoding(buf, enc).Encode(v); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
 type byteCounter struct {
 	count uint64
 }
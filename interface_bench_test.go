@@ -15,6 +15,7 @@
 package bin
 
 import (
+	"bytes"
 	"math/rand"
 	"testing"
 )
@@ -51,6 +52,31 @@ func BenchmarkByteCount(b *testing.B) {
 	}
 }
 
+func BenchmarkDecodeByteArraySlice(b *testing.B) {
+	hashes := make([][32]byte, 10000)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		if err := NewEncoderWithEncoding(buf, encoding).Encode(hashes); err != nil {
+			b.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		b.Run(encoding.String(), func(b *testing.B) {
+			setupBench(b)
+			for i := 0; i < b.N; i++ {
+				var out [][32]byte
+				if err := NewDecoderWithEncoding(data, encoding).Decode(&out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 type benchFlat struct {
 	F1 string
 	F2 int16
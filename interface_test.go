@@ -16,9 +16,11 @@ package bin
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type Example struct {
@@ -66,3 +68,61 @@ func TestUnmarshalWithDecoder(t *testing.T) {
 	assert.Equal(t, e, &Example{Value: 72, Prefix: 0xaa})
 	assert.Equal(t, 0, d.Remaining())
 }
+
+func TestHex(t *testing.T) {
+	e := &Example{Value: 72, Prefix: 0xaa}
+
+	out, err := EncodeHex(e, EncodingBin)
+	require.NoError(t, err)
+	assert.Equal(t, "aa00000048", out)
+
+	for _, in := range []string{"aa00000048", "0xaa00000048", "0XAA00000048", " 0xaa 0000\n0048\n"} {
+		dec, err := NewDecoderFromHex(in, EncodingBin)
+		require.NoError(t, err, in)
+
+		got := &Example{}
+		require.NoError(t, dec.Decode(got))
+		assert.Equal(t, e, got)
+	}
+
+	_, err = NewDecoderFromHex("0xzz", EncodingBin)
+	assert.Error(t, err)
+}
+
+func TestDecodeTry(t *testing.T) {
+	type record struct {
+		Name string
+		ID   uint32
+	}
+	in := record{Name: "abc", ID: 7}
+
+	data, err := MarshalBorsh(in)
+	require.NoError(t, err)
+
+	var out record
+	enc, err := DecodeTry(data, &out, EncodingBin, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingBorsh, enc)
+	assert.Equal(t, in, out)
+
+	data, err = MarshalBin(in)
+	require.NoError(t, err)
+	out = record{}
+	enc, err = DecodeTry(data, &out, EncodingBorsh, EncodingBin)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingBin, enc)
+	assert.Equal(t, in, out)
+
+	out = record{}
+	_, err = DecodeTry(append(data, 0x00), &out, EncodingBin)
+	assert.EqualError(t, err, "decode try: no encoding succeeded: Bin: 1 trailing bytes")
+	assert.Equal(t, record{}, out)
+
+	// The failures stay wrapped.
+	_, err = DecodeTry([]byte{0x05, 0x00, 0x00, 0x00, 'a'}, &out, EncodingBorsh, EncodingBin)
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.False(t, errors.Is(err, ErrNaN))
+
+	_, err = DecodeTry(data, out, EncodingBin)
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// LazyNumber holds the raw bytes of a fixed-width number, which are interpreted
+// only once it's known whether it's signed, unsigned or a float, like a json.Number.
+// A struct field of this type is decoded with the `bin:"lazy=N"` tag, where N is the width in bytes.
+type LazyNumber struct {
+	raw   []byte
+	order binary.ByteOrder
+}
+
+// NewLazyNumber returns a number of the provided raw bytes, in the provided byte order.
+func NewLazyNumber(raw []byte, order binary.ByteOrder) LazyNumber {
+	return LazyNumber{raw: append([]byte(nil), raw...), order: order}
+}
+
+// Width returns the width of the number, in bytes.
+func (n LazyNumber) Width() int {
+	return len(n.raw)
+}
+
+// Bytes returns a copy of the raw bytes of the number.
+func (n LazyNumber) Bytes() []byte {
+	return append([]byte(nil), n.raw...)
+}
+
+// Uint64 interprets the number as an unsigned integer of 1 to 8 bytes.
+func (n LazyNumber) Uint64() (uint64, error) {
+	if len(n.raw) < 1 || len(n.raw) > TypeSize.Uint64 {
+		return 0, fmt.Errorf("lazy number: cannot interpret %d bytes as an integer", len(n.raw))
+	}
+	var buf [8]byte
+	if n.order == binary.BigEndian {
+		copy(buf[8-len(n.raw):], n.raw)
+		return binary.BigEndian.Uint64(buf[:]), nil
+	}
+	copy(buf[:], n.raw)
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// Int64 interprets the number as a two's complement signed integer of 1 to 8 bytes.
+func (n LazyNumber) Int64() (int64, error) {
+	u, err := n.Uint64()
+	if err != nil {
+		return 0, err
+	}
+	// Sign-extend from the width of the number.
+	shift := uint(64 - 8*len(n.raw))
+	return int64(u<<shift) >> shift, nil
+}
+
+// Float64 interprets the number as an IEEE 754 float of 4 or 8 bytes.
+func (n LazyNumber) Float64() (float64, error) {
+	switch len(n.raw) {
+	case TypeSize.Float32:
+		u, _ := n.Uint64()
+		return float64(math.Float32frombits(uint32(u))), nil
+	case TypeSize.Float64:
+		u, _ := n.Uint64()
+		return math.Float64frombits(u), nil
+	default:
+		return 0, fmt.Errorf("lazy number: cannot interpret %d bytes as a float", len(n.raw))
+	}
+}
+
+// ReadLazyNumber reads a number of width bytes, in the byte order of the decoder,
+// without interpreting it.
+func (dec *Decoder) ReadLazyNumber(width int) (LazyNumber, error) {
+	return dec.readLazyNumber(width, dec.order)
+}
+
+func (dec *Decoder) readLazyNumber(width int, order binary.ByteOrder) (LazyNumber, error) {
+	if width < 1 || width > TypeSize.Uint64 {
+		return LazyNumber{}, fmt.Errorf("lazy number: invalid width %d, expected 1 to 8 bytes", width)
+	}
+	raw, err := dec.ReadNBytes(width)
+	if err != nil {
+		return LazyNumber{}, fmt.Errorf("lazy number: %w", err)
+	}
+	return NewLazyNumber(raw, order), nil
+}
+
+// WriteLazyNumber writes the raw bytes of the number.
+func (e *Encoder) WriteLazyNumber(n LazyNumber) error {
+	return e.WriteBytes(n.raw, false)
+}
+
+var lazyNumberType = reflect.TypeOf(LazyNumber{})
+
+// decodeLazyNumber reads a `bin:"lazy=N"` LazyNumber field.
+func (dec *Decoder) decodeLazyNumber(rv reflect.Value, width int, order binary.ByteOrder) error {
+	if rv.Type() != lazyNumberType {
+		return fmt.Errorf("lazy number: expected a bin.LazyNumber field, got %s", rv.Type())
+	}
+	n, err := dec.readLazyNumber(width, order)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(n))
+	return nil
+}
+
+// encodeLazyNumber writes a `bin:"lazy=N"` LazyNumber field:
+// a zero LazyNumber is written as N zero bytes.
+func (e *Encoder) encodeLazyNumber(rv reflect.Value, width int) error {
+	if rv.Type() != lazyNumberType {
+		return fmt.Errorf("lazy number: expected a bin.LazyNumber field, got %s", rv.Type())
+	}
+	n := rv.Interface().(LazyNumber)
+	if n.Width() == 0 {
+		return e.WriteBytes(make([]byte, width), false)
+	}
+	if n.Width() != width {
+		return fmt.Errorf("lazy number: %d bytes don't match the width %d", n.Width(), width)
+	}
+	return e.WriteLazyNumber(n)
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_ReadLazyNumber(t *testing.T) {
+	dec := NewBinDecoder([]byte{0xfe, 0xff, 0x00, 0x00, 0xc0, 0x3f})
+	n, err := dec.ReadLazyNumber(2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n.Width())
+	assert.Equal(t, []byte{0xfe, 0xff}, n.Bytes())
+
+	u, err := n.Uint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0xfffe), u)
+	i, err := n.Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), i)
+	_, err = n.Float64()
+	assert.EqualError(t, err, "lazy number: cannot interpret 2 bytes as a float")
+
+	n, err = dec.ReadLazyNumber(4)
+	require.NoError(t, err)
+	f, err := n.Float64()
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	_, err = dec.ReadLazyNumber(1)
+	assert.Error(t, err)
+	_, err = dec.ReadLazyNumber(9)
+	assert.EqualError(t, err, "lazy number: invalid width 9, expected 1 to 8 bytes")
+}
+
+func TestDecoder_ReadLazyNumber_BigEndian(t *testing.T) {
+	dec := NewBinDecoderWithOrder([]byte{0xff, 0xff, 0xff, 0x85}, BE)
+	n, err := dec.ReadLazyNumber(4)
+	require.NoError(t, err)
+
+	i, err := n.Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-123), i)
+
+	n = NewLazyNumber([]byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}, BE)
+	f, err := n.Float64()
+	require.NoError(t, err)
+	assert.Equal(t, math.Pi, f)
+}
+
+func TestLazyNumber_Field(t *testing.T) {
+	type record struct {
+		Kind  uint8
+		Value LazyNumber `bin:"lazy=8"`
+		Small LazyNumber `bin:"lazy=1"`
+	}
+
+	data := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, 0x80}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		var out record
+		require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+		f, err := out.Value.Float64()
+		require.NoError(t, err)
+		assert.Equal(t, 1.5, f)
+		i, err := out.Small.Int64()
+		require.NoError(t, err)
+		assert.Equal(t, int64(-128), i)
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(out))
+		assert.Equal(t, data, buf.Bytes())
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(record{Kind: 2}))
+	assert.Equal(t, append([]byte{0x02}, make([]byte, 9)...), buf.Bytes())
+
+	err := NewBinEncoder(new(bytes.Buffer)).Encode(record{Value: NewLazyNumber([]byte{1, 2}, LE)})
+	assert.EqualError(t, err, `error while encoding "Value" field: lazy number: 2 bytes don't match the width 8`)
+
+	size, ok := FixedSize(reflect.TypeOf(record{}), EncodingBin)
+	assert.True(t, ok)
+	assert.Equal(t, 10, size)
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+)
+
+// checkLen checks the length n read from the wire against the minlen and
+// maxlen tags of the field being decoded, before anything is allocated for it.
+func (o *option) checkLen(n int) error {
+	if o == nil {
+		return nil
+	}
+	return checkLen(n, o.MinLen, o.MaxLen)
+}
+
+// checkMaxLen checks the number n of elements decoded so far of a field whose
+// length is only known once it's fully decoded, against its maxlen tag, so that
+// decoding stops at the first element out of bounds. Its minlen tag is checked
+// with checkLen once it's fully decoded.
+func (o *option) checkMaxLen(n int) error {
+	if o == nil {
+		return nil
+	}
+	return checkLen(n, 0, o.MaxLen)
+}
+
+func checkLen(n, min, max int) error {
+	if min < 0 {
+		return fmt.Errorf("minlen: invalid bound, expected a non-negative integer")
+	} else if max < 0 {
+		return fmt.Errorf("maxlen: invalid bound, expected a non-negative integer")
+	}
+	if n < min {
+		return errorf(ErrInvalidLength, "minlen: length %d is less than %d", n, min)
+	} else if max > 0 && n > max {
+		return errorf(ErrInvalidLength, "maxlen: length %d is greater than %d", n, max)
+	}
+	return nil
+}
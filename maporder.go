@@ -0,0 +1,103 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// WithStrictMapOrder makes the decoder reject the maps whose keys are not
+// strictly increasing, i.e. that contain duplicate or out-of-order keys,
+// with an error wrapping ErrNonCanonical: the canonical form of a Borsh BTreeMap,
+// which prevents the malleability of maps in consensus-critical data.
+// The keys must be integers, floats, strings, bools or byte arrays.
+func (dec *Decoder) WithStrictMapOrder() *Decoder {
+	dec.strictMapOrder = true
+	return dec
+}
+
+// checkMapKeyOrder returns an error if the decoded map key
+// is not strictly greater than the previous one.
+func checkMapKeyOrder(prev, key reflect.Value) error {
+	cmp, err := compareMapKeys(prev, key)
+	if err != nil {
+		return fmt.Errorf("strict map order: %w", err)
+	}
+	if cmp == 0 {
+		return errorf(ErrNonCanonical, "strict map order: duplicate key %v", key.Interface())
+	}
+	if cmp > 0 {
+		return errorf(ErrNonCanonical, "strict map order: key %v is out of order, after %v", key.Interface(), prev.Interface())
+	}
+	return nil
+}
+
+// compareMapKeys returns -1, 0 or 1 if the key a is less than, equal to,
+// or greater than the key b, of the same type.
+func compareMapKeys(a, b reflect.Value) (int, error) {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float()), nil
+	case reflect.String:
+		return compareOrdered(a.String() < b.String(), a.String() > b.String()), nil
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool()), nil
+	case reflect.Array:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			return bytes.Compare(byteArrayBytes(a), byteArrayBytes(b)), nil
+		}
+	}
+	return 0, fmt.Errorf("map keys of type %s are not ordered", a.Type())
+}
+
+// sortMapKeys sorts map keys in the order checked by WithStrictMapOrder, so that
+// the encoded maps are canonical; keys of a type that isn't ordered are left as is.
+func sortMapKeys(keys []reflect.Value) {
+	if len(keys) < 2 {
+		return
+	}
+	if _, err := compareMapKeys(keys[0], keys[1]); err != nil {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		cmp, _ := compareMapKeys(keys[i], keys[j])
+		return cmp < 0
+	})
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// byteArrayBytes returns the bytes of a byte array value, which may not be addressable.
+func byteArrayBytes(rv reflect.Value) []byte {
+	out := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(out), rv)
+	return out
+}
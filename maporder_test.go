@@ -0,0 +1,89 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_WithStrictMapOrder(t *testing.T) {
+	// Borsh: u32 count, then the entries with u16 keys and u8 values.
+	sorted := []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0a, 0x02, 0x00, 0x0b, 0x00, 0x01, 0x0c}
+	var out map[uint16]uint8
+	require.NoError(t, NewBorshDecoder(sorted).WithStrictMapOrder().Decode(&out))
+	assert.Equal(t, map[uint16]uint8{1: 10, 2: 11, 256: 12}, out)
+
+	unordered := []byte{0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0a, 0x01, 0x00, 0x0b}
+	require.NoError(t, NewBorshDecoder(unordered).Decode(&out))
+	err := NewBorshDecoder(unordered).WithStrictMapOrder().Decode(&out)
+	assert.EqualError(t, err, "strict map order: key 1 is out of order, after 2")
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+
+	duplicate := []byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0a, 0x01, 0x00, 0x0b}
+	err = NewBorshDecoder(duplicate).WithStrictMapOrder().Decode(&out)
+	assert.EqualError(t, err, "strict map order: duplicate key 1")
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+}
+
+func TestDecoder_WithStrictMapOrder_Encodings(t *testing.T) {
+	type registry struct {
+		Owners map[[4]byte]string
+		Names  map[string]int32
+	}
+	in := registry{
+		Owners: map[[4]byte]string{{1, 2, 3, 4}: "a", {0, 9, 9, 9}: "b", {1, 2, 3, 5}: "c"},
+		Names:  map[string]int32{"b": 1, "a": -1, "ab": 2},
+	}
+
+	// The Borsh encoder writes the keys sorted.
+	data, err := MarshalBorsh(in)
+	require.NoError(t, err)
+	var out registry
+	require.NoError(t, NewBorshDecoder(data).WithStrictMapOrder().Decode(&out))
+	assert.Equal(t, in, out)
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			// The other encoders write the keys sorted too.
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			var out registry
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), encoding).WithStrictMapOrder().Decode(&out))
+			assert.Equal(t, in, out)
+
+			buf.Reset()
+			enc := NewEncoderWithEncoding(buf, encoding)
+			require.NoError(t, enc.WriteLength(2))
+			require.NoError(t, enc.WriteBool(true))
+			require.NoError(t, enc.WriteUint8(1))
+			require.NoError(t, enc.WriteBool(false))
+			require.NoError(t, enc.WriteUint8(2))
+
+			var flags map[bool]uint8
+			err := NewDecoderWithEncoding(buf.Bytes(), encoding).WithStrictMapOrder().Decode(&flags)
+			assert.EqualError(t, err, "strict map order: key false is out of order, after true")
+		})
+	}
+
+	type point struct{ X, Y int8 }
+	var points map[point]uint8
+	err = NewBorshDecoder([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x03, 0x04, 0x00}).WithStrictMapOrder().Decode(&points)
+	assert.EqualError(t, err, "strict map order: map keys of type bin.point are not ordered")
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+)
+
+// MerkleProof is the inclusion proof of a leaf in a binary Merkle tree.
+type MerkleProof struct {
+	Leaf [32]byte
+	// Siblings holds the hashes of the siblings of the nodes
+	// on the path from the leaf to the root, starting from the leaf.
+	Siblings [][32]byte
+	// Path holds, for each level, whether the node on the path
+	// is the right child, i.e. whether its sibling is on the left.
+	Path []bool
+}
+
+// ReadMerkleProof reads a Merkle proof encoded as the 32-byte leaf, the number of
+// siblings (see ReadLength), the 32-byte sibling hashes, and the path
+// as bit-packed booleans (least significant bit first), one per sibling.
+func (dec *Decoder) ReadMerkleProof() (*MerkleProof, error) {
+	proof := &MerkleProof{}
+	leaf, err := dec.ReadNBytes(len(proof.Leaf))
+	if err != nil {
+		return nil, fmt.Errorf("merkle proof: leaf: %w", err)
+	}
+	copy(proof.Leaf[:], leaf)
+
+	count, err := dec.ReadLength()
+	if err != nil {
+		return nil, fmt.Errorf("merkle proof: sibling count: %w", err)
+	}
+	if count > dec.Remaining()/32 {
+		return nil, errorf(ErrShortBuffer, "merkle proof: %d siblings exceed the %d remaining bytes", count, dec.Remaining())
+	}
+	proof.Siblings = make([][32]byte, count)
+	for i := range proof.Siblings {
+		sibling, err := dec.ReadNBytes(32)
+		if err != nil {
+			return nil, fmt.Errorf("merkle proof: sibling %d: %w", i, err)
+		}
+		copy(proof.Siblings[i][:], sibling)
+	}
+
+	bits, err := dec.ReadNBytes((count + 7) / 8)
+	if err != nil {
+		return nil, fmt.Errorf("merkle proof: path: %w", err)
+	}
+	proof.Path = make([]bool, count)
+	for i := range proof.Path {
+		proof.Path[i] = bits[i/8]&(1<<uint(i%8)) != 0
+	}
+	return proof, nil
+}
+
+// Verify returns true if the proof leads from its leaf to the provided root,
+// where hash returns the hash of the concatenation of two child nodes.
+func (p *MerkleProof) Verify(root [32]byte, hash func(left, right []byte) [32]byte) bool {
+	if len(p.Path) != len(p.Siblings) {
+		return false
+	}
+	node := p.Leaf
+	for i, sibling := range p.Siblings {
+		if p.Path[i] {
+			node = hash(sibling[:], node[:])
+		} else {
+			node = hash(node[:], sibling[:])
+		}
+	}
+	return node == root
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_ReadMerkleProof(t *testing.T) {
+	hash := func(left, right []byte) [32]byte {
+		return sha256.Sum256(append(append([]byte{}, left...), right...))
+	}
+
+	// Tree of 4 leaves, proof of the inclusion of c:
+	// root = h(h(a, b), h(c, d))
+	a, b, c, d := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c")), sha256.Sum256([]byte("d"))
+	ab := hash(a[:], b[:])
+	cd := hash(c[:], d[:])
+	root := hash(ab[:], cd[:])
+
+	data := append([]byte{}, c[:]...)
+	data = append(data, 0x02)
+	data = append(data, d[:]...)
+	data = append(data, ab[:]...)
+	data = append(data, 0x02) // c is a left child, h(c, d) is a right child
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		dec := NewDecoderWithEncoding(data, encoding)
+		proof, err := dec.ReadMerkleProof()
+		require.NoError(t, err)
+		assert.Equal(t, c, proof.Leaf)
+		assert.Equal(t, [][32]byte{d, ab}, proof.Siblings)
+		assert.Equal(t, []bool{false, true}, proof.Path)
+		assert.Equal(t, 0, dec.Remaining())
+
+		assert.True(t, proof.Verify(root, hash))
+		assert.False(t, proof.Verify(ab, hash))
+	}
+
+	_, err := NewBinDecoder(data[:len(data)-1]).ReadMerkleProof()
+	assert.EqualError(t, err, "merkle proof: path: required [1] bytes, remaining [0]")
+
+	_, err = NewBinDecoder(data[:40]).ReadMerkleProof()
+	assert.EqualError(t, err, "merkle proof: 2 siblings exceed the 7 remaining bytes")
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is a fixed-scale amount in a currency, like an EOS asset:
+// Amount counts units of 10^-Precision of the currency.
+//
+// It's encoded as the amount (int64, little endian), then the precision (uint8),
+// then the currency name (TypeSize.CurrencyName bytes, padded with NUL bytes).
+type Money struct {
+	Amount    int64
+	Precision uint8
+	Currency  string
+}
+
+// String formats the amount with its precision, then the currency, e.g. "12.3400 EOS".
+func (m Money) String() string {
+	amount := strconv.FormatInt(m.Amount, 10)
+	sign := ""
+	if m.Amount < 0 {
+		sign, amount = "-", amount[1:]
+	}
+	if precision := int(m.Precision); precision > 0 {
+		if len(amount) <= precision {
+			amount = strings.Repeat("0", precision-len(amount)+1) + amount
+		}
+		amount = amount[:len(amount)-precision] + "." + amount[len(amount)-precision:]
+	}
+	return sign + amount + " " + m.Currency
+}
+
+func (m *Money) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadMoney()
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+func (m Money) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteMoney(m)
+}
+
+// ReadCurrencyName reads a currency name of TypeSize.CurrencyName bytes,
+// without its NUL padding.
+func (dec *Decoder) ReadCurrencyName() (string, error) {
+	data, err := dec.ReadNBytes(TypeSize.CurrencyName)
+	if err != nil {
+		return "", fmt.Errorf("currency name: %w", err)
+	}
+	return string(bytes.TrimRight(data, "\x00")), nil
+}
+
+// ReadMoney reads a Money amount: see Money.
+func (dec *Decoder) ReadMoney() (out Money, err error) {
+	if out.Amount, err = dec.ReadInt64(LE); err != nil {
+		return out, fmt.Errorf("money: amount: %w", err)
+	}
+	if out.Precision, err = dec.ReadUint8(); err != nil {
+		return out, fmt.Errorf("money: precision: %w", err)
+	}
+	if out.Currency, err = dec.ReadCurrencyName(); err != nil {
+		return out, fmt.Errorf("money: %w", err)
+	}
+	return out, nil
+}
+
+// WriteCurrencyName writes a currency name padded with NUL bytes to
+// TypeSize.CurrencyName bytes, and returns an error if it's longer.
+func (e *Encoder) WriteCurrencyName(name string) error {
+	if len(name) > TypeSize.CurrencyName {
+		return fmt.Errorf("currency name: %q is longer than %d bytes", name, TypeSize.CurrencyName)
+	}
+	data := make([]byte, TypeSize.CurrencyName)
+	copy(data, name)
+	return e.WriteBytes(data, false)
+}
+
+// WriteMoney writes a Money amount: see Money.
+func (e *Encoder) WriteMoney(m Money) error {
+	if err := e.WriteInt64(m.Amount, LE); err != nil {
+		return err
+	}
+	if err := e.WriteUint8(m.Precision); err != nil {
+		return err
+	}
+	return e.WriteCurrencyName(m.Currency)
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney(t *testing.T) {
+	type transfer struct {
+		Quantity Money
+		Memo     string
+	}
+	in := transfer{Quantity: Money{Amount: 123400, Precision: 4, Currency: "EOS"}, Memo: "m"}
+
+	data, err := MarshalBin(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x08, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x04,
+		'E', 'O', 'S', 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 'm',
+	}, data)
+
+	var out transfer
+	require.NoError(t, NewBinDecoder(data).Decode(&out))
+	assert.Equal(t, in, out)
+
+	size, ok := staticSizeOf(reflect.TypeOf(Money{}))
+	assert.True(t, ok)
+	assert.Equal(t, 16, size)
+
+	_, err = MarshalBin(Money{Currency: "TOOLONGNAME"})
+	assert.EqualError(t, err, "currency name: \"TOOLONGNAME\" is longer than 7 bytes")
+
+	_, err = NewBinDecoder(data[:10]).ReadMoney()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Contains(t, err.Error(), "money: currency name: ")
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "12.3400 EOS", Money{Amount: 123400, Precision: 4, Currency: "EOS"}.String())
+	assert.Equal(t, "-0.05 USD", Money{Amount: -5, Precision: 2, Currency: "USD"}.String())
+	assert.Equal(t, "7 XYZ", Money{Amount: 7, Currency: "XYZ"}.String())
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	namedTypesMu sync.RWMutex
+	// typesByName maps a registered name to its concrete type.
+	typesByName = map[string]reflect.Type{}
+	// namesByType maps a registered concrete type to its name.
+	namesByType = map[reflect.Type]string{}
+)
+
+// BinRegister records the concrete type of value under its name, like gob.Register,
+// for the interface values encoded by name (see Decoder.SetNamedInterfaces).
+// The name is the package path and name of the type, prefixed with "*" for pointers,
+// or its string representation for unnamed types.
+func BinRegister(value interface{}) {
+	rt := reflect.TypeOf(value)
+	if rt == nil {
+		panic("bin: cannot register a nil value")
+	}
+	name := rt.String()
+	star := ""
+	if rt.Name() == "" && rt.Kind() == reflect.Ptr {
+		star = "*"
+		rt = rt.Elem()
+	}
+	if rt.Name() != "" {
+		if rt.PkgPath() == "" {
+			name = star + rt.Name()
+		} else {
+			name = star + rt.PkgPath() + "." + rt.Name()
+		}
+	}
+	BinRegisterName(name, value)
+}
+
+// BinRegisterName is like BinRegister but uses the provided name instead of the name of the type.
+// It panics if the name or the type is already registered with a different counterpart.
+func BinRegisterName(name string, value interface{}) {
+	if name == "" {
+		panic("bin: cannot register a type with an empty name")
+	}
+	rt := reflect.TypeOf(value)
+	if rt == nil {
+		panic("bin: cannot register a nil value")
+	}
+
+	namedTypesMu.Lock()
+	defer namedTypesMu.Unlock()
+
+	if t, found := typesByName[name]; found && t != rt {
+		panic(fmt.Sprintf("bin: registering duplicate types for %q: %s != %s", name, t, rt))
+	}
+	if n, found := namesByType[rt]; found && n != name {
+		panic(fmt.Sprintf("bin: registering duplicate names for %s: %q != %q", rt, n, name))
+	}
+	typesByName[name] = rt
+	namesByType[rt] = name
+}
+
+// SetNamedInterfaces enables decoding the interface values that are not registered
+// unions as a string holding the name of their concrete type, as registered
+// with BinRegister, followed by their payload.
+// An empty name denotes a nil interface value.
+func (dec *Decoder) SetNamedInterfaces(enabled bool) {
+	dec.namedInterfaces = enabled
+}
+
+// SetNamedInterfaces enables encoding the interface values that are not registered
+// unions with the name of their concrete type; see Decoder.SetNamedInterfaces.
+func (e *Encoder) SetNamedInterfaces(enabled bool) {
+	e.namedInterfaces = enabled
+}
+
+// decodeNamedInterface decodes the name of a concrete type, then its
+// payload into the interface value rv with order as the default byte order.
+func (dec *Decoder) decodeNamedInterface(rv reflect.Value, order binary.ByteOrder) error {
+	name, err := dec.ReadString()
+	if err != nil {
+		return fmt.Errorf("named interface: %w", err)
+	}
+	if name == "" {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	name = dec.normalizeEnumName(name)
+
+	namedTypesMu.RLock()
+	concreteType, found := typesByName[name]
+	namedTypesMu.RUnlock()
+	if !found {
+		return fmt.Errorf("named interface: no type registered for name %q", name)
+	}
+	if !concreteType.Implements(rv.Type()) {
+		return fmt.Errorf("named interface: type %s registered for name %q doesn't implement %s", concreteType, name, rv.Type())
+	}
+	if traceEnabled {
+		zlog.Debug("decode: named interface", zap.String("name", name), zap.Stringer("type", concreteType))
+	}
+	return dec.decodeUnionVariant(rv, concreteType, reflect.Value{}, order)
+}
+
+// encodeNamedInterface encodes the name of the concrete type of the
+// interface value rv, then its payload with order as the default byte order.
+func (e *Encoder) encodeNamedInterface(rv reflect.Value, order binary.ByteOrder) error {
+	if rv.IsNil() {
+		return e.WriteString("")
+	}
+	concreteType := rv.Elem().Type()
+
+	namedTypesMu.RLock()
+	name, found := namesByType[concreteType]
+	namedTypesMu.RUnlock()
+	if !found {
+		return fmt.Errorf("named interface: type %s is not registered", concreteType)
+	}
+	if err := e.WriteString(name); err != nil {
+		return err
+	}
+	return e.encodeWithOrder(rv.Elem().Interface(), order)
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedTestAnimal interface{}
+
+type namedTestDog struct {
+	Age uint8
+}
+
+type namedTestCat struct {
+	Lives uint16
+}
+
+func init() {
+	BinRegister(namedTestDog{})
+	BinRegisterName("cat", &namedTestCat{})
+}
+
+type namedTestZoo struct {
+	Animals []namedTestAnimal
+}
+
+func TestNamedInterfaces(t *testing.T) {
+	in := namedTestZoo{Animals: []namedTestAnimal{namedTestDog{Age: 3}, &namedTestCat{Lives: 9}, nil}}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			encoder := NewEncoderWithEncoding(buf, enc)
+			encoder.SetNamedInterfaces(true)
+			require.NoError(t, encoder.Encode(in))
+			assert.Contains(t, buf.String(), "github.com/gagliardetto/binary.namedTestDog")
+
+			var out namedTestZoo
+			decoder := NewDecoderWithEncoding(buf.Bytes(), enc)
+			decoder.SetNamedInterfaces(true)
+			require.NoError(t, decoder.Decode(&out))
+			assert.Equal(t, in, out)
+			assert.Equal(t, 0, decoder.Remaining())
+		})
+	}
+}
+
+func TestNamedInterfaces_Errors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	encoder := NewBinEncoder(buf)
+	encoder.SetNamedInterfaces(true)
+	err := encoder.Encode(namedTestZoo{Animals: []namedTestAnimal{unionTestCircle{}}})
+	assert.EqualError(t, err, "error while encoding \"Animals\" field: named interface: type bin.unionTestCircle is not registered")
+
+	var out namedTestZoo
+	decoder := NewBinDecoder([]byte{0x01, 0x03, 'd', 'o', 'g'})
+	decoder.SetNamedInterfaces(true)
+	err = decoder.Decode(&out)
+	assert.EqualError(t, err, "error while decoding \"Animals\" field: named interface: no type registered for name \"dog\"")
+}
+
+func TestBinRegister_Duplicate(t *testing.T) {
+	assert.NotPanics(t, func() { BinRegister(namedTestDog{}) })
+	assert.Panics(t, func() { BinRegisterName("cat", namedTestDog{}) })
+	assert.Panics(t, func() { BinRegister(&namedTestCat{}) })
+}
+
+func TestNamedInterfaces_EnumNameNormalizer(t *testing.T) {
+	for _, name := range []string{"cat", "Cat", "CAT"} {
+		data := append([]byte{0x01, byte(len(name))}, name...)
+		data = append(data, 0x09, 0x00)
+
+		var out namedTestZoo
+		decoder := NewBinDecoder(data).WithEnumNameNormalizer(strings.ToLower)
+		decoder.SetNamedInterfaces(true)
+		require.NoError(t, decoder.Decode(&out))
+		assert.Equal(t, namedTestZoo{Animals: []namedTestAnimal{&namedTestCat{Lives: 9}}}, out)
+	}
+
+	var out namedTestZoo
+	decoder := NewBinDecoder([]byte{0x01, 0x03, 'C', 'A', 'T', 0x09, 0x00})
+	decoder.SetNamedInterfaces(true)
+	err := decoder.Decode(&out)
+	assert.EqualError(t, err, "error while decoding \"Animals\" field: named interface: no type registered for name \"CAT\"")
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// noneIfSentinel parses the sentinel of a `bin:"none_if=..."` field,
+// which must be a pointer to a sized integer type, like a *uint64.
+func noneIfSentinel(rv reflect.Value, s string) (reflect.Value, error) {
+	if rv.Kind() != reflect.Ptr {
+		return reflect.Value{}, fmt.Errorf("none_if: expected a pointer to an integer field, got %s", rv.Type())
+	}
+	sentinel := reflect.New(rv.Type().Elem()).Elem()
+	var err error
+	switch sentinel.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 0, sentinel.Type().Bits()); err == nil {
+			sentinel.SetInt(n)
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 0, sentinel.Type().Bits()); err == nil {
+			sentinel.SetUint(n)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("none_if: expected a pointer to an integer field, got %s", rv.Type())
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("none_if: invalid %s sentinel %q: %w", sentinel.Type(), s, err)
+	}
+	return sentinel, nil
+}
+
+// decodeNoneIf reads a `bin:"none_if=..."` field: the integer is always present,
+// and the field is set to nil if it's the sentinel, or to a pointer to it otherwise.
+func (dec *Decoder) decodeNoneIf(rv reflect.Value, s string, order binary.ByteOrder) error {
+	sentinel, err := noneIfSentinel(rv, s)
+	if err != nil {
+		return err
+	}
+	value := reflect.New(sentinel.Type())
+	width := int(sentinel.Type().Size())
+	if isSignedKind(sentinel.Kind()) {
+		n, err := dec.ReadIntN(width, order)
+		if err != nil {
+			return err
+		}
+		value.Elem().SetInt(n)
+	} else {
+		n, err := dec.ReadUintN(width, order)
+		if err != nil {
+			return err
+		}
+		value.Elem().SetUint(n)
+	}
+	if value.Elem().Interface() == sentinel.Interface() {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	rv.Set(value)
+	return nil
+}
+
+// encodeNoneIf writes a `bin:"none_if=..."` field: the sentinel if it's nil,
+// or the pointed integer otherwise, which can't be the sentinel itself.
+func (e *Encoder) encodeNoneIf(rv reflect.Value, s string, order binary.ByteOrder) error {
+	sentinel, err := noneIfSentinel(rv, s)
+	if err != nil {
+		return err
+	}
+	value := sentinel
+	if !rv.IsNil() {
+		value = rv.Elem()
+		if value.Interface() == sentinel.Interface() {
+			return fmt.Errorf("none_if: value %v is the sentinel of an absent value", value)
+		}
+	}
+	width := int(sentinel.Type().Size())
+	if isSignedKind(sentinel.Kind()) {
+		return e.WriteIntN(value.Int(), width, order)
+	}
+	return e.WriteUintN(value.Uint(), width, order)
+}
+
+func isSignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	nullablesMu sync.RWMutex
+	// nullables maps a nullable wrapper type to its fields.
+	nullables = map[reflect.Type]*nullableFields{}
+)
+
+type nullableFields struct {
+	value int
+	valid int
+}
+
+func init() {
+	RegisterNullable(sql.NullString{}, "String", "Valid")
+	RegisterNullable(sql.NullInt64{}, "Int64", "Valid")
+	RegisterNullable(sql.NullInt32{}, "Int32", "Valid")
+	RegisterNullable(sql.NullFloat64{}, "Float64", "Valid")
+	RegisterNullable(sql.NullBool{}, "Bool", "Valid")
+}
+
+// RegisterNullable registers a nullable wrapper struct type in the style of sql.NullString:
+// when a field of this type is tagged with optional, the presence flag sets
+// the bool field named valid, and the payload is the field named value.
+//
+//	type NullUint64 struct {
+//		Uint64 uint64
+//		Valid  bool
+//	}
+//
+//	bin.RegisterNullable(NullUint64{}, "Uint64", "Valid")
+//
+// The common sql.Null* types are registered by default.
+// RegisterNullable panics on invalid input.
+func RegisterNullable(wrapper interface{}, value, valid string) {
+	rt := reflect.TypeOf(wrapper)
+	if rt == nil || rt.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("nullable must be a struct, got %T", wrapper))
+	}
+	valueField, found := rt.FieldByName(value)
+	if !found || len(valueField.Index) != 1 || valueField.PkgPath != "" {
+		panic(fmt.Sprintf("nullable %s: no exported %q field", rt, value))
+	}
+	validField, found := rt.FieldByName(valid)
+	if !found || len(validField.Index) != 1 || validField.PkgPath != "" || validField.Type.Kind() != reflect.Bool {
+		panic(fmt.Sprintf("nullable %s: no exported bool %q field", rt, valid))
+	}
+
+	nullablesMu.Lock()
+	defer nullablesMu.Unlock()
+	nullables[rt] = &nullableFields{value: valueField.Index[0], valid: validField.Index[0]}
+}
+
+func lookupNullable(rt reflect.Type) *nullableFields {
+	nullablesMu.RLock()
+	defer nullablesMu.RUnlock()
+	return nullables[rt]
+}
+
+// optionalIsPresent returns true if the optional value rv is present:
+// a nullable wrapper is present if it's valid, a pointer if it's not nil,
+// and any other value if it's not zero, or if it has a default (see the default tag),
+// since an absent one would decode as the default instead of the zero value.
+func optionalIsPresent(rv reflect.Value, def *string) bool {
+	if nullable := lookupNullable(rv.Type()); nullable != nil {
+		return rv.Field(nullable.valid).Bool()
+	}
+	if def != nil && rv.Kind() != reflect.Ptr {
+		return true
+	}
+	return !rv.IsZero()
+}
+
+// optionalPayload returns the value to encode for the present optional value rv:
+// the value field of a nullable wrapper, or rv itself.
+func optionalPayload(rv reflect.Value) reflect.Value {
+	if nullable := lookupNullable(rv.Type()); nullable != nil {
+		return rv.Field(nullable.value)
+	}
+	return rv
+}
+
+// setOptionalPresent marks the optional value rv as present, and returns the value
+// to decode its payload into: the value field of a nullable wrapper, or rv itself.
+func setOptionalPresent(rv reflect.Value) reflect.Value {
+	if nullable := lookupNullable(rv.Type()); nullable != nil && rv.CanSet() {
+		rv.Field(nullable.valid).SetBool(true)
+		return rv.Field(nullable.value)
+	}
+	return rv
+}
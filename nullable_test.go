@@ -0,0 +1,81 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nullableTestUint16 struct {
+	Uint16 uint16
+	Valid  bool
+}
+
+func init() {
+	RegisterNullable(nullableTestUint16{}, "Uint16", "Valid")
+}
+
+type nullableTestRow struct {
+	Name  sql.NullString     `bin:"optional"`
+	Count sql.NullInt64      `bin:"optional"`
+	Port  nullableTestUint16 `bin:"optional"`
+	Empty sql.NullString     `bin:"optional"`
+}
+
+func TestNullable(t *testing.T) {
+	in := nullableTestRow{
+		Name:  sql.NullString{String: "", Valid: true},
+		Count: sql.NullInt64{},
+		Port:  nullableTestUint16{Uint16: 8080, Valid: true},
+		Empty: sql.NullString{String: "ignored", Valid: false},
+	}
+	expected := nullableTestRow{
+		Name: in.Name,
+		Port: in.Port,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+
+			var out nullableTestRow
+			decoder := NewDecoderWithEncoding(buf.Bytes(), enc)
+			require.NoError(t, decoder.Decode(&out))
+			assert.Equal(t, expected, out)
+			assert.Equal(t, 0, decoder.Remaining())
+		})
+	}
+
+	data, err := MarshalBorsh(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x01, 0x00, 0x00, 0x00, 0x00,
+		0x00,
+		0x01, 0x90, 0x1f,
+		0x00,
+	}, data)
+}
+
+func TestRegisterNullable_Invalid(t *testing.T) {
+	assert.Panics(t, func() { RegisterNullable(sql.NullString{}, "Str", "Valid") })
+	assert.Panics(t, func() { RegisterNullable(sql.NullString{}, "String", "String") })
+	assert.Panics(t, func() { RegisterNullable("", "String", "Valid") })
+}
@@ -21,8 +21,48 @@ import "encoding/binary"
 
 type option struct {
 	OptionalField bool
-	SizeOfSlice   *int
-	Order         binary.ByteOrder
+	// Default is the value of an absent optional, parsed
+	// from its string form, or nil for the zero value.
+	Default      *string
+	SizeOfSlice  *int
+	Order        binary.ByteOrder
+	Packed       bool
+	SVarintLen   bool
+	ByteLenFixed bool
+	Fill         bool
+	// MinLen and MaxLen are the length bounds of a string, slice,
+	// array or map, checked before it is allocated.
+	MinLen int
+	MaxLen int
+}
+
+// Option is a read-only view of the options of the value being
+// decoded, as set by the tags of its struct field, for custom unmarshalers.
+type Option struct {
+	Optional     bool
+	Order        binary.ByteOrder
+	Packed       bool
+	SVarintLen   bool
+	ByteLenFixed bool
+	Fill         bool
+	// SizeOfSlice is the slice length set by a sizeof field, or -1 if not set.
+	SizeOfSlice int
+}
+
+func (o *option) view() Option {
+	out := Option{
+		Optional:     o.OptionalField,
+		Order:        o.Order,
+		Packed:       o.Packed,
+		SVarintLen:   o.SVarintLen,
+		ByteLenFixed: o.ByteLenFixed,
+		Fill:         o.Fill,
+		SizeOfSlice:  -1,
+	}
+	if o.hasSizeOfSlice() {
+		out.SizeOfSlice = o.getSizeOfSlice()
+	}
+	return out
 }
 
 var LE binary.ByteOrder = binary.LittleEndian
@@ -40,8 +80,15 @@ func newDefaultOption() *option {
 func (o *option) clone() *option {
 	out := &option{
 		OptionalField: o.OptionalField,
+		Default:       o.Default,
 		SizeOfSlice:   o.SizeOfSlice,
 		Order:         o.Order,
+		Packed:        o.Packed,
+		SVarintLen:    o.SVarintLen,
+		ByteLenFixed:  o.ByteLenFixed,
+		Fill:          o.Fill,
+		MinLen:        o.MinLen,
+		MaxLen:        o.MaxLen,
 	}
 	return out
 }
@@ -50,6 +97,22 @@ func (o *option) isOptional() bool {
 	return o.OptionalField
 }
 
+func (o *option) isPacked() bool {
+	return o.Packed
+}
+
+func (o *option) hasSVarintLen() bool {
+	return o.SVarintLen
+}
+
+func (o *option) hasByteLenFixed() bool {
+	return o.ByteLenFixed
+}
+
+func (o *option) hasFill() bool {
+	return o.Fill
+}
+
 func (o *option) hasSizeOfSlice() bool {
 	return o.SizeOfSlice != nil
 }
@@ -62,6 +125,18 @@ func (o *option) setSizeOfSlice(size int) *option {
 	o.SizeOfSlice = &size
 	return o
 }
+
+// elemOption returns the option of the elements of an array or slice
+// with the option o: a default one that inherits the byte order of o.
+func (o *option) elemOption() *option {
+	return newDefaultOption().setOrder(o.Order)
+}
+
+func (o *option) setOrder(order binary.ByteOrder) *option {
+	o.Order = order
+	return o
+}
+
 func (o *option) setIsOptional(isOptional bool) *option {
 	o.OptionalField = isOptional
 	return o
@@ -73,6 +148,9 @@ const (
 	EncodingBin Encoding = iota
 	EncodingCompactU16
 	EncodingBorsh
+	// EncodingBitcoin is the Bin encoding with the length prefixes of Bitcoin:
+	// slices, maps and strings are prefixed by their CompactSize length.
+	EncodingBitcoin
 )
 
 func (enc Encoding) String() string {
@@ -83,6 +161,8 @@ func (enc Encoding) String() string {
 		return "CompactU16"
 	case EncodingBorsh:
 		return "Borsh"
+	case EncodingBitcoin:
+		return "Bitcoin"
 	default:
 		return ""
 	}
@@ -100,9 +180,55 @@ func (en Encoding) IsCompactU16() bool {
 	return en == EncodingCompactU16
 }
 
+func (en Encoding) IsBitcoin() bool {
+	return en == EncodingBitcoin
+}
+
+// LengthEncoding is the encoding of the length prefix
+// of slices, maps and byte slices.
+type LengthEncoding int
+
+const (
+	// LengthEncodingDefault is the length encoding of the decoder's or encoder's Encoding:
+	// uvarint for Bin, u32 for Borsh, compact-u16 for CompactU16, and CompactSize for Bitcoin.
+	LengthEncodingDefault LengthEncoding = iota
+	LengthEncodingUvarint
+	LengthEncodingU16
+	LengthEncodingU32
+	LengthEncodingCompactU16
+	// LengthEncodingBincodeVarint is the varint integer mode of Bincode:
+	// see Decoder.ReadBincodeVarint. Besides the length prefixes, it applies
+	// to the integer values wider than a byte, which are zigzag encoded if signed.
+	LengthEncodingBincodeVarint
+	// LengthEncodingCompactSize is the CompactSize of Bitcoin:
+	// see Decoder.ReadCompactSize.
+	LengthEncodingCompactSize
+)
+
+func (l LengthEncoding) String() string {
+	switch l {
+	case LengthEncodingDefault:
+		return "Default"
+	case LengthEncodingUvarint:
+		return "Uvarint"
+	case LengthEncodingU16:
+		return "U16"
+	case LengthEncodingU32:
+		return "U32"
+	case LengthEncodingCompactU16:
+		return "CompactU16"
+	case LengthEncodingBincodeVarint:
+		return "BincodeVarint"
+	case LengthEncodingCompactSize:
+		return "CompactSize"
+	default:
+		return ""
+	}
+}
+
 func isValidEncoding(enc Encoding) bool {
 	switch enc {
-	case EncodingBin, EncodingCompactU16, EncodingBorsh:
+	case EncodingBin, EncodingCompactU16, EncodingBorsh, EncodingBitcoin:
 		return true
 	default:
 		return false
@@ -20,6 +20,7 @@ package bin
 import (
 	"encoding/binary"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -27,12 +28,55 @@ type fieldTag struct {
 	SizeOf          string
 	Skip            bool
 	Order           binary.ByteOrder
+	OrderSet        bool
 	Optional        bool
+	Default         *string
 	BinaryExtension bool
+	Codec           string
+	Packed          bool
+	SVarintLen      bool
+	ByteLenFixed    bool
+	Fill            bool
+	U24             bool
+	IntN            int
+	Q               *qFormat
+	NoneIf          *string
+	GUID            bool
+	TriBool         bool
+	UnionTag        string
+	CLayout         bool
+	FieldCount      bool
+	ASCIIStr        bool
+	ASCIIStrLen     int
+	FixedInner      int
+	Lazy            int
+	PopcountLen     string
+	If              string
+	Runes           string
+	Rest            bool
+	FillRest        bool
+	TrailerLen      bool
+	Continuation    string
+	Unknown         bool
+	RFC3339         bool
+	BigFloat        bool
+	OrderIndex      int
+	OrderIndexSet   bool
+	MinLen          int
+	MaxLen          int
 
 	IsBorshEnum bool
 }
 
+// orderOr returns the byte order set by the tag, or the provided
+// fallback if the tag doesn't specify one.
+func (t *fieldTag) orderOr(fallback binary.ByteOrder) binary.ByteOrder {
+	if t.OrderSet {
+		return t.Order
+	}
+	return fallback
+}
+
 func parseFieldTag(tag reflect.StructTag) *fieldTag {
 	t := &fieldTag{
 		Order: defaultByteOrder,
@@ -44,12 +88,141 @@ func parseFieldTag(tag reflect.StructTag) *fieldTag {
 			t.SizeOf = tmp[1]
 		} else if s == "big" {
 			t.Order = binary.BigEndian
+			t.OrderSet = true
 		} else if s == "little" {
 			t.Order = binary.LittleEndian
+			t.OrderSet = true
+		} else if s == "order=be" || s == "order=big" {
+			t.Order = binary.BigEndian
+			t.OrderSet = true
+		} else if s == "order=le" || s == "order=little" {
+			t.Order = binary.LittleEndian
+			t.OrderSet = true
 		} else if s == "optional" {
 			t.Optional = true
+		} else if strings.HasPrefix(s, "default=") {
+			// An invalid default is reported when it's used.
+			tmp := strings.SplitN(s, "=", 2)
+			t.Default = &tmp[1]
+		} else if strings.HasPrefix(s, "none_if=") {
+			// An invalid sentinel is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			t.NoneIf = &tmp[1]
+		} else if s == "packed" {
+			t.Packed = true
+		} else if s == "svarint_len" {
+			t.SVarintLen = true
+		} else if s == "bytelen_fixed" {
+			t.ByteLenFixed = true
+		} else if s == "u24" {
+			t.U24 = true
+		} else if s == "i24" || s == "i40" || s == "i48" || s == "i56" {
+			bits, _ := strconv.Atoi(s[1:])
+			t.IntN = bits / 8
+		} else if q, ok := parseQFormat(s); ok {
+			// An invalid width is reported when the field
+			// is decoded or encoded.
+			t.Q = q
+		} else if s == "guid" {
+			t.GUID = true
+		} else if s == "tribool" {
+			t.TriBool = true
+		} else if s == "fill" {
+			t.Fill = true
+		} else if s == "rfc3339" {
+			t.RFC3339 = true
+		} else if s == "bigfloat" {
+			t.BigFloat = true
+		} else if s == "rest" {
+			t.Rest = true
+		} else if s == "fill_rest" {
+			t.FillRest = true
+		} else if s == "trailer_len" {
+			t.TrailerLen = true
+		} else if strings.HasPrefix(s, "continuation=") {
+			// An unknown position is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			t.Continuation = tmp[1]
+		} else if s == "unknown" {
+			t.Unknown = true
+		} else if s == "c_layout" {
+			t.CLayout = true
+		} else if s == "field_count" {
+			t.FieldCount = true
 		} else if s == "binary_extension" {
 			t.BinaryExtension = true
+		} else if strings.HasPrefix(s, "union_tag=") {
+			tmp := strings.SplitN(s, "=", 2)
+			t.UnionTag = tmp[1]
+		} else if s == "asciistr" || strings.HasPrefix(s, "asciistr=") {
+			// A missing or invalid byte count is reported when the field
+			// is decoded or encoded.
+			t.ASCIIStr = true
+			if tmp := strings.SplitN(s, "=", 2); len(tmp) == 2 {
+				t.ASCIIStrLen, _ = strconv.Atoi(tmp[1])
+			}
+		} else if strings.HasPrefix(s, "popcount_len=") {
+			tmp := strings.SplitN(s, "=", 2)
+			t.PopcountLen = tmp[1]
+		} else if strings.HasPrefix(s, "if=") {
+			// An invalid expression is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			t.If = tmp[1]
+		} else if strings.HasPrefix(s, "runes=") {
+			// An unknown encoding is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			t.Runes = tmp[1]
+		} else if strings.HasPrefix(s, "fixed_inner=") {
+			// An invalid byte count is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			if n, err := strconv.Atoi(tmp[1]); err == nil {
+				t.FixedInner = n
+			} else {
+				t.FixedInner = -1
+			}
+		} else if strings.HasPrefix(s, "lazy=") {
+			// An invalid width is reported when the field
+			// is decoded or encoded.
+			tmp := strings.SplitN(s, "=", 2)
+			if n, err := strconv.Atoi(tmp[1]); err == nil {
+				t.Lazy = n
+			} else {
+				t.Lazy = -1
+			}
+		} else if strings.HasPrefix(s, "order_index=") {
+			// An invalid wire position is reported when the field
+			// is decoded or encoded.
+			t.OrderIndexSet = true
+			tmp := strings.SplitN(s, "=", 2)
+			if n, err := strconv.Atoi(tmp[1]); err == nil {
+				t.OrderIndex = n
+			} else {
+				t.OrderIndex = -1
+			}
+		} else if strings.HasPrefix(s, "minlen=") {
+			// An invalid bound is reported when the field is decoded.
+			tmp := strings.SplitN(s, "=", 2)
+			if n, err := strconv.Atoi(tmp[1]); err == nil {
+				t.MinLen = n
+			} else {
+				t.MinLen = -1
+			}
+		} else if strings.HasPrefix(s, "maxlen=") {
+			// An invalid bound is reported when the field is decoded.
+			tmp := strings.SplitN(s, "=", 2)
+			if n, err := strconv.Atoi(tmp[1]); err == nil {
+				t.MaxLen = n
+			} else {
+				t.MaxLen = -1
+			}
+		} else if strings.HasPrefix(s, "codec=") {
+			tmp := strings.SplitN(s, "=", 2)
+			t.Codec = tmp[1]
 		} else if s == "-" {
 			t.Skip = true
 		}
@@ -68,6 +68,196 @@ func Test_parseFieldTag(t *testing.T) {
 				SizeOf:   "Nodes",
 			},
 		},
+		{
+			name: "with a codec",
+			tag:  `bin:"codec=base58pubkey"`,
+			expectValue: &fieldTag{
+				Order: binary.LittleEndian,
+				Codec: "base58pubkey",
+			},
+		},
+		{
+			name: "with packed",
+			tag:  `bin:"packed"`,
+			expectValue: &fieldTag{
+				Order:  binary.LittleEndian,
+				Packed: true,
+			},
+		},
+		{
+			name: "with big endian",
+			tag:  `bin:"big"`,
+			expectValue: &fieldTag{
+				Order:    binary.BigEndian,
+				OrderSet: true,
+			},
+		},
+		{
+			name: "with svarint_len",
+			tag:  `bin:"svarint_len"`,
+			expectValue: &fieldTag{
+				Order:      binary.LittleEndian,
+				SVarintLen: true,
+			},
+		},
+		{
+			name: "with bytelen_fixed",
+			tag:  `bin:"bytelen_fixed"`,
+			expectValue: &fieldTag{
+				Order:        binary.LittleEndian,
+				ByteLenFixed: true,
+			},
+		},
+		{
+			name: "with u24",
+			tag:  `bin:"u24"`,
+			expectValue: &fieldTag{
+				Order: binary.LittleEndian,
+				U24:   true,
+			},
+		},
+		{
+			name: "with i40",
+			tag:  `bin:"i40"`,
+			expectValue: &fieldTag{
+				Order: binary.LittleEndian,
+				IntN:  5,
+			},
+		},
+		{
+			name: "with fill",
+			tag:  `bin:"fill"`,
+			expectValue: &fieldTag{
+				Order: binary.LittleEndian,
+				Fill:  true,
+			},
+		},
+		{
+			name: "with c_layout",
+			tag:  `bin:"c_layout"`,
+			expectValue: &fieldTag{
+				Order:   binary.LittleEndian,
+				CLayout: true,
+			},
+		},
+		{
+			name: "with field_count",
+			tag:  `bin:"field_count"`,
+			expectValue: &fieldTag{
+				Order:      binary.LittleEndian,
+				FieldCount: true,
+			},
+		},
+		{
+			name: "with minlen and maxlen",
+			tag:  `bin:"minlen=1 maxlen=32"`,
+			expectValue: &fieldTag{
+				Order:  binary.LittleEndian,
+				MinLen: 1,
+				MaxLen: 32,
+			},
+		},
+		{
+			name: "with invalid minlen and maxlen",
+			tag:  `bin:"minlen=one maxlen=-"`,
+			expectValue: &fieldTag{
+				Order:  binary.LittleEndian,
+				MinLen: -1,
+				MaxLen: -1,
+			},
+		},
+		{
+			name: "with order_index",
+			tag:  `bin:"order_index=3"`,
+			expectValue: &fieldTag{
+				Order:         binary.LittleEndian,
+				OrderIndex:    3,
+				OrderIndexSet: true,
+			},
+		},
+		{
+			name: "with bigfloat",
+			tag:  `bin:"bigfloat"`,
+			expectValue: &fieldTag{
+				Order:    binary.LittleEndian,
+				BigFloat: true,
+			},
+		},
+		{
+			name: "with rfc3339",
+			tag:  `bin:"rfc3339"`,
+			expectValue: &fieldTag{
+				Order:   binary.LittleEndian,
+				RFC3339: true,
+			},
+		},
+		{
+			name: "with rest",
+			tag:  `bin:"rest"`,
+			expectValue: &fieldTag{
+				Order: binary.LittleEndian,
+				Rest:  true,
+			},
+		},
+		{
+			name: "with asciistr",
+			tag:  `bin:"asciistr=32"`,
+			expectValue: &fieldTag{
+				Order:       binary.LittleEndian,
+				ASCIIStr:    true,
+				ASCIIStrLen: 32,
+			},
+		},
+		{
+			name: "with asciistr without byte count",
+			tag:  `bin:"asciistr"`,
+			expectValue: &fieldTag{
+				Order:    binary.LittleEndian,
+				ASCIIStr: true,
+			},
+		},
+		{
+			name: "with optional default",
+			tag:  `bin:"optional default=-1"`,
+			expectValue: &fieldTag{
+				Order:    binary.LittleEndian,
+				Optional: true,
+				Default:  func() *string { s := "-1"; return &s }(),
+			},
+		},
+		{
+			name: "with runes",
+			tag:  `bin:"runes=utf32 big"`,
+			expectValue: &fieldTag{
+				Order:    binary.BigEndian,
+				OrderSet: true,
+				Runes:    "utf32",
+			},
+		},
+		{
+			name: "with popcount_len",
+			tag:  `bin:"popcount_len=Mask"`,
+			expectValue: &fieldTag{
+				Order:       binary.LittleEndian,
+				PopcountLen: "Mask",
+			},
+		},
+		{
+			name: "with tribool",
+			tag:  `bin:"tribool"`,
+			expectValue: &fieldTag{
+				Order:   binary.LittleEndian,
+				TriBool: true,
+			},
+		},
+		{
+			name: "with fixed_inner",
+			tag:  `bin:"fixed_inner=32"`,
+			expectValue: &fieldTag{
+				Order:      binary.LittleEndian,
+				FixedInner: 32,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,58 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/bits"
+	"reflect"
+)
+
+// popcountLen returns the number of bits set in the mask field of the struct rv,
+// which is the length of its i-th field, a `bin:"popcount_len=Mask"` slice.
+// The mask is an integer, or a byte slice or array, and must be decoded before the slice.
+func popcountLen(rv reflect.Value, i int, mask string) (int, error) {
+	if slice := rv.Field(i); slice.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("popcount_len: expected a slice field, got %s", slice.Type())
+	}
+	field, ok := rv.Type().FieldByName(mask)
+	if !ok || len(field.Index) != 1 {
+		return 0, fmt.Errorf("popcount_len: no field %q in %s", mask, rv.Type())
+	}
+	precedes, err := fieldPrecedes(rv.Type(), field.Index[0], i)
+	if err != nil {
+		return 0, err
+	}
+	if !precedes {
+		return 0, fmt.Errorf("popcount_len: mask %q is not a field preceding %q", mask, rv.Type().Field(i).Name)
+	}
+	v := rv.Field(field.Index[0])
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Only count the bits of the width of the field.
+		return bits.OnesCount64(uint64(v.Int()) & (1<<uint(v.Type().Bits()) - 1)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bits.OnesCount64(v.Uint()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			count := 0
+			for i := 0; i < v.Len(); i++ {
+				count += bits.OnesCount8(uint8(v.Index(i).Uint()))
+			}
+			return count, nil
+		}
+	}
+	return 0, fmt.Errorf("popcount_len: mask field %q must be an integer or bytes, got %s", mask, v.Type())
+}
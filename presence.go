@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// PresenceBitmap is a marker type: when it's the first field of a struct,
+// the struct is encoded with a leading field-presence bitmap.
+//
+// The bitmap has one bit per encoded (i.e. not skipped) field following the marker,
+// least significant bit first, rounded up to a whole number of bytes.
+// Only the fields whose bit is set are encoded after the bitmap;
+// on encoding, a field is considered present if it's not the zero value.
+//
+//	type Update struct {
+//		bin.PresenceBitmap
+//		Name  string
+//		Age   uint8
+//		Email string
+//	}
+type PresenceBitmap struct{}
+
+var presenceBitmapType = reflect.TypeOf(PresenceBitmap{})
+
+func hasPresenceBitmap(rt reflect.Type) bool {
+	return rt.NumField() > 0 && rt.Field(0).Type == presenceBitmapType
+}
+
+// presenceBitmapFieldCount returns the number of fields covered by
+// the presence bitmap of the provided struct type.
+func presenceBitmapFieldCount(rt reflect.Type) (count int) {
+	for i := 1; i < rt.NumField(); i++ {
+		if tag := parseFieldTag(rt.Field(i).Tag); !tag.Skip && !tag.Unknown {
+			count++
+		}
+	}
+	return count
+}
+
+type presenceBitmap struct {
+	bits  []byte
+	index int
+}
+
+// nextPresent returns whether the next field is present.
+func (p *presenceBitmap) nextPresent() bool {
+	present := p.bits[p.index/8]&(1<<uint(p.index%8)) != 0
+	p.index++
+	return present
+}
+
+func (dec *Decoder) readPresenceBitmap(rt reflect.Type) (*presenceBitmap, error) {
+	count := presenceBitmapFieldCount(rt)
+	bits, err := dec.ReadNBytes((count + 7) / 8)
+	if err != nil {
+		return nil, fmt.Errorf("presence bitmap: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read presence bitmap", zap.Int("fields", count), zap.Stringer("hex", HexBytes(bits)))
+	}
+	return &presenceBitmap{bits: bits}, nil
+}
+
+func (e *Encoder) writePresenceBitmap(rt reflect.Type, rv reflect.Value) (*presenceBitmap, error) {
+	count := presenceBitmapFieldCount(rt)
+	bits := make([]byte, (count+7)/8)
+	index := 0
+	for i := 1; i < rt.NumField(); i++ {
+		if tag := parseFieldTag(rt.Field(i).Tag); tag.Skip || tag.Unknown {
+			continue
+		}
+		if !rv.Field(i).IsZero() {
+			bits[index/8] |= 1 << uint(index%8)
+		}
+		index++
+	}
+	if traceEnabled {
+		zlog.Debug("encode: write presence bitmap", zap.Int("fields", count), zap.Stringer("hex", HexBytes(bits)))
+	}
+	if err := e.toWriter(bits); err != nil {
+		return nil, err
+	}
+	return &presenceBitmap{bits: bits}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type presenceTestStruct struct {
+	PresenceBitmap
+	A uint8
+	B uint16
+	C string `bin:"-"`
+	D uint32
+}
+
+func TestPresenceBitmap(t *testing.T) {
+	in := presenceTestStruct{
+		A: 0x11,
+		D: 0x22,
+	}
+	expected := []byte{
+		0x05, // A and D present
+		0x11,
+		0x22, 0x00, 0x00, 0x00,
+	}
+
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			out := presenceTestStruct{B: 99}
+			require.NoError(t, NewDecoderWithEncoding(expected, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+
+	var out presenceTestStruct
+	err := NewBinDecoder([]byte{0x02}).Decode(&out)
+	assert.Error(t, err)
+
+	err = NewBinDecoder(nil).Decode(&out)
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// The wire types of the fields of a Protocol Buffers message.
+const (
+	ProtoWireVarint     = 0
+	ProtoWireFixed64    = 1
+	ProtoWireBytes      = 2
+	ProtoWireStartGroup = 3
+	ProtoWireEndGroup   = 4
+	ProtoWireFixed32    = 5
+)
+
+// protoMaxFieldNum is the largest valid Protocol Buffers field number.
+const protoMaxFieldNum = 1<<29 - 1
+
+// ReadProtoTag reads the tag of a field of a Protocol Buffers message:
+// a varint holding its field number and wire type (see the ProtoWire constants).
+// The value of the field follows, to be read according to the wire type,
+// e.g. with ReadProtoVarint, ReadProtoLengthDelimited, or skipped with SkipProtoField.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadProtoTag() (fieldNum int, wireType int, err error) {
+	start := dec.pos
+	key, err := dec.ReadProtoVarint()
+	if err != nil {
+		return 0, 0, fmt.Errorf("proto tag: %w", err)
+	}
+	num, typ := key>>3, int(key&7)
+	if num < 1 || num > protoMaxFieldNum {
+		dec.pos = start
+		return 0, 0, fmt.Errorf("proto tag: invalid field number %d", num)
+	}
+	if typ > ProtoWireFixed32 {
+		dec.pos = start
+		return 0, 0, fmt.Errorf("proto tag: invalid wire type %d", typ)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read proto tag", zap.Uint64("field", num), zap.Int("wire_type", typ))
+	}
+	return int(num), typ, nil
+}
+
+// ReadProtoVarint reads the value of a Protocol Buffers varint field (int32, int64,
+// uint32, uint64, bool and enum; sint32 and sint64 are additionally zigzag-encoded).
+// Negative int32 and int64 values are encoded on 10 bytes, as their 64-bit two's complement.
+func (dec *Decoder) ReadProtoVarint() (uint64, error) {
+	out, err := dec.ReadUvarint64()
+	if err != nil {
+		return 0, fmt.Errorf("proto varint: %w", err)
+	}
+	return out, nil
+}
+
+// ReadProtoFixed32 reads the value of a Protocol Buffers fixed32, sfixed32 or float field,
+// which is little endian.
+func (dec *Decoder) ReadProtoFixed32() (uint32, error) {
+	return dec.ReadUint32(LE)
+}
+
+// ReadProtoFixed64 reads the value of a Protocol Buffers fixed64, sfixed64 or double field,
+// which is little endian.
+func (dec *Decoder) ReadProtoFixed64() (uint64, error) {
+	return dec.ReadUint64(LE)
+}
+
+// ReadProtoLengthDelimited reads the value of a Protocol Buffers length-delimited
+// field (string, bytes, embedded message, or packed repeated scalars): a varint
+// length, then the bytes, which are returned without being copied.
+// On error, the position of the decoder is left unchanged.
+func (dec *Decoder) ReadProtoLengthDelimited() ([]byte, error) {
+	start := dec.pos
+	length, err := dec.ReadProtoVarint()
+	if err != nil {
+		return nil, fmt.Errorf("proto length-delimited: %w", err)
+	}
+	if length > uint64(dec.Remaining()) {
+		dec.pos = start
+		return nil, errorf(ErrShortBuffer, "proto length-delimited: required [%d] bytes, remaining [%d]", length, dec.Remaining())
+	}
+	out := dec.data[dec.pos : dec.pos+int(length)]
+	dec.pos += int(length)
+	return out, nil
+}
+
+// SkipProtoField advances past the value of a Protocol Buffers
+// field of the provided wire type, e.g. of an unknown field.
+// Groups, which are deprecated, are not supported.
+func (dec *Decoder) SkipProtoField(wireType int) error {
+	switch wireType {
+	case ProtoWireVarint:
+		_, err := dec.ReadProtoVarint()
+		return err
+	case ProtoWireFixed64:
+		return dec.SkipBytes(8)
+	case ProtoWireBytes:
+		_, err := dec.ReadProtoLengthDelimited()
+		return err
+	case ProtoWireFixed32:
+		return dec.SkipBytes(4)
+	default:
+		return fmt.Errorf("proto: cannot skip a field of wire type %d", wireType)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_ReadProto(t *testing.T) {
+	data := []byte{
+		0x08, 0x96, 0x01, // 1: varint 150
+		0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g', // 2: string "testing"
+		0x1d, 0x01, 0x00, 0x00, 0x00, // 3: fixed32 1
+		0x21, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 4: fixed64 2
+		0x28, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // 5: int32 -1
+		0xf8, 0xff, 0xff, 0xff, 0x0f, 0x00, // 536870911: varint 0
+	}
+
+	dec := NewBinDecoder(data)
+	var fields []int
+	for dec.HasRemaining() {
+		num, wireType, err := dec.ReadProtoTag()
+		require.NoError(t, err)
+		fields = append(fields, num)
+
+		switch num {
+		case 1:
+			assert.Equal(t, ProtoWireVarint, wireType)
+			v, err := dec.ReadProtoVarint()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(150), v)
+		case 2:
+			assert.Equal(t, ProtoWireBytes, wireType)
+			v, err := dec.ReadProtoLengthDelimited()
+			require.NoError(t, err)
+			assert.Equal(t, "testing", string(v))
+		case 3:
+			assert.Equal(t, ProtoWireFixed32, wireType)
+			v, err := dec.ReadProtoFixed32()
+			require.NoError(t, err)
+			assert.Equal(t, uint32(1), v)
+		case 4:
+			assert.Equal(t, ProtoWireFixed64, wireType)
+			v, err := dec.ReadProtoFixed64()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(2), v)
+		case 5:
+			v, err := dec.ReadProtoVarint()
+			require.NoError(t, err)
+			assert.Equal(t, int32(-1), int32(v))
+		default:
+			require.NoError(t, dec.SkipProtoField(wireType))
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, protoMaxFieldNum}, fields)
+
+	// Skipping every field.
+	dec = NewBinDecoder(data)
+	for dec.HasRemaining() {
+		_, wireType, err := dec.ReadProtoTag()
+		require.NoError(t, err)
+		require.NoError(t, dec.SkipProtoField(wireType))
+	}
+}
+
+func TestDecoder_ReadProto_Errors(t *testing.T) {
+	dec := NewBinDecoder([]byte{0x00})
+	_, _, err := dec.ReadProtoTag()
+	assert.EqualError(t, err, "proto tag: invalid field number 0")
+	assert.Equal(t, 0, int(dec.Position()))
+
+	_, _, err = NewBinDecoder([]byte{0x0e}).ReadProtoTag()
+	assert.EqualError(t, err, "proto tag: invalid wire type 6")
+
+	_, _, err = NewBinDecoder([]byte{0x80}).ReadProtoTag()
+	assert.Error(t, err)
+
+	dec = NewBinDecoder([]byte{0x05, 'a', 'b'})
+	_, err = dec.ReadProtoLengthDelimited()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.Equal(t, 0, int(dec.Position()))
+
+	err = NewBinDecoder([]byte{0x01}).SkipProtoField(ProtoWireStartGroup)
+	assert.EqualError(t, err, "proto: cannot skip a field of wire type 3")
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// qFormat is the fixed-point format of a `bin:"q16.16"` (signed)
+// or `bin:"uq8.8"` (unsigned) float field.
+type qFormat struct {
+	Unsigned bool
+	IntBits  int
+	FracBits int
+}
+
+func (q qFormat) String() string {
+	prefix := "q"
+	if q.Unsigned {
+		prefix = "uq"
+	}
+	return fmt.Sprintf("%s%d.%d", prefix, q.IntBits, q.FracBits)
+}
+
+// parseQFormat parses a tag option like "q16.16" or "uq8.8".
+func parseQFormat(s string) (*qFormat, bool) {
+	q := &qFormat{}
+	if strings.HasPrefix(s, "uq") {
+		q.Unsigned = true
+		s = s[2:]
+	} else if strings.HasPrefix(s, "q") {
+		s = s[1:]
+	} else {
+		return nil, false
+	}
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	var err error
+	if q.IntBits, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, false
+	}
+	if q.FracBits, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, false
+	}
+	return q, true
+}
+
+// qWidth returns the width in bytes of a Q format of intBits integer bits
+// (including the sign bit of signed formats) and fracBits fractional bits.
+func qWidth(intBits, fracBits int) (int, error) {
+	bits := intBits + fracBits
+	if intBits < 0 || fracBits < 0 || bits%8 != 0 || bits < 8 || bits > 64 {
+		return 0, fmt.Errorf("q%d.%d: width of %d bits is not a whole number of 1 to 8 bytes", intBits, fracBits, bits)
+	}
+	return bits / 8, nil
+}
+
+// ReadQ reads a signed fixed-point number in the Qm.n format: a two's complement
+// integer of intBits+fracBits bits (the integer bits include the sign bit),
+// scaled by 2^-fracBits, e.g. ReadQ(16, 16, LE) for Q16.16 in a 32-bit integer.
+func (dec *Decoder) ReadQ(intBits, fracBits int, order binary.ByteOrder) (float64, error) {
+	width, err := qWidth(intBits, fracBits)
+	if err != nil {
+		return 0, err
+	}
+	n, err := dec.ReadIntN(width, order)
+	if err != nil {
+		return 0, err
+	}
+	return math.Ldexp(float64(n), -fracBits), nil
+}
+
+// ReadUQ reads an unsigned fixed-point number in the UQm.n format: an unsigned
+// integer of intBits+fracBits bits, scaled by 2^-fracBits.
+func (dec *Decoder) ReadUQ(intBits, fracBits int, order binary.ByteOrder) (float64, error) {
+	width, err := qWidth(intBits, fracBits)
+	if err != nil {
+		return 0, err
+	}
+	n, err := dec.ReadUintN(width, order)
+	if err != nil {
+		return 0, err
+	}
+	return math.Ldexp(float64(n), -fracBits), nil
+}
+
+// WriteQ writes f as a signed fixed-point number in the Qm.n format (see ReadQ),
+// rounded to the nearest multiple of 2^-fracBits.
+// It returns an error if f is NaN or out of the range of the format.
+func (e *Encoder) WriteQ(f float64, intBits, fracBits int, order binary.ByteOrder) error {
+	width, err := qWidth(intBits, fracBits)
+	if err != nil {
+		return err
+	}
+	n := math.Round(math.Ldexp(f, fracBits))
+	limit := math.Ldexp(1, width*8-1)
+	if math.IsNaN(n) || n < -limit || n >= limit {
+		return fmt.Errorf("q%d.%d: value %v out of range", intBits, fracBits, f)
+	}
+	return e.WriteIntN(int64(n), width, order)
+}
+
+// WriteUQ writes f as an unsigned fixed-point number in the UQm.n format (see ReadUQ),
+// rounded to the nearest multiple of 2^-fracBits.
+// It returns an error if f is NaN or out of the range of the format.
+func (e *Encoder) WriteUQ(f float64, intBits, fracBits int, order binary.ByteOrder) error {
+	width, err := qWidth(intBits, fracBits)
+	if err != nil {
+		return err
+	}
+	n := math.Round(math.Ldexp(f, fracBits))
+	if math.IsNaN(n) || n < 0 || n >= math.Ldexp(1, width*8) {
+		return fmt.Errorf("uq%d.%d: value %v out of range", intBits, fracBits, f)
+	}
+	return e.WriteUintN(uint64(n), width, order)
+}
+
+func checkQField(rv reflect.Value, q *qFormat) error {
+	if rv.Kind() != reflect.Float32 && rv.Kind() != reflect.Float64 {
+		return fmt.Errorf("%s: expected a float field, got %s", q, rv.Type())
+	}
+	return nil
+}
+
+// decodeQ reads a `bin:"q16.16"` or `bin:"uq16.16"` float field.
+func (dec *Decoder) decodeQ(rv reflect.Value, q *qFormat, order binary.ByteOrder) error {
+	if err := checkQField(rv, q); err != nil {
+		return err
+	}
+	var f float64
+	var err error
+	if q.Unsigned {
+		f, err = dec.ReadUQ(q.IntBits, q.FracBits, order)
+	} else {
+		f, err = dec.ReadQ(q.IntBits, q.FracBits, order)
+	}
+	if err != nil {
+		return err
+	}
+	rv.SetFloat(f)
+	return nil
+}
+
+// encodeQ writes a `bin:"q16.16"` or `bin:"uq16.16"` float field.
+func (e *Encoder) encodeQ(rv reflect.Value, q *qFormat, order binary.ByteOrder) error {
+	if err := checkQField(rv, q); err != nil {
+		return err
+	}
+	if q.Unsigned {
+		return e.WriteUQ(rv.Float(), q.IntBits, q.FracBits, order)
+	}
+	return e.WriteQ(rv.Float(), q.IntBits, q.FracBits, order)
+}
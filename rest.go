@@ -0,0 +1,134 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+var byteSliceType = reflect.TypeOf([]byte(nil))
+
+// checkRestField validates a `bin:"rest"` field, which must be
+// the last field of the struct, and of type []byte.
+func checkRestField(rt reflect.Type, fieldIndex int) error {
+	last, err := isLastWireField(rt, fieldIndex)
+	if err != nil {
+		return err
+	}
+	if !last {
+		return fmt.Errorf("rest: must be the last field of %s", rt)
+	}
+	if rt.Field(fieldIndex).Type != byteSliceType {
+		return fmt.Errorf("rest: expected a []byte field, got %s", rt.Field(fieldIndex).Type)
+	}
+	return nil
+}
+
+// decodeRestField reads all the remaining bytes of the decoder
+// into the `bin:"rest"` field at index fieldIndex of the struct type rt.
+func (dec *Decoder) decodeRestField(rt reflect.Type, fieldIndex int, rv reflect.Value, opt *option) error {
+	if err := checkRestField(rt, fieldIndex); err != nil {
+		return err
+	}
+	if err := opt.checkLen(dec.Remaining()); err != nil {
+		return err
+	}
+	data, err := dec.ReadNBytes(dec.Remaining())
+	if err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read rest", zap.Int("len", len(data)))
+	}
+	rv.SetBytes(append([]byte(nil), data...))
+	return nil
+}
+
+// encodeRestField writes the `bin:"rest"` field at index fieldIndex
+// of the struct type rt as is, without a length prefix.
+func (e *Encoder) encodeRestField(rt reflect.Type, fieldIndex int, rv reflect.Value) error {
+	if err := checkRestField(rt, fieldIndex); err != nil {
+		return err
+	}
+	return e.toWriter(rv.Bytes())
+}
+
+// checkFillRestField validates a `bin:"fill_rest"` field, which must be
+// the last field of the struct, and a slice.
+func checkFillRestField(rt reflect.Type, fieldIndex int) error {
+	last, err := isLastWireField(rt, fieldIndex)
+	if err != nil {
+		return err
+	}
+	if !last {
+		return fmt.Errorf("fill_rest: must be the last field of %s", rt)
+	}
+	if rt.Field(fieldIndex).Type.Kind() != reflect.Slice {
+		return fmt.Errorf("fill_rest: expected a slice field, got %s", rt.Field(fieldIndex).Type)
+	}
+	return nil
+}
+
+// decodeFillRestField reads elements until the decoder (or the frame
+// of a sub-decoder) is exhausted, into the `bin:"fill_rest"` slice field
+// at index fieldIndex of the struct type rt. Unlike `fill`, the elements
+// can have a variable size, since there is no count to compute upfront.
+func (dec *Decoder) decodeFillRestField(rt reflect.Type, fieldIndex int, rv reflect.Value, opt *option) error {
+	if err := checkFillRestField(rt, fieldIndex); err != nil {
+		return err
+	}
+	out := reflect.Zero(rv.Type())
+	for dec.HasRemaining() {
+		start := dec.pos
+		elem := reflect.New(rv.Type().Elem())
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return fmt.Errorf("fill_rest: element %d: %w", out.Len(), err)
+		}
+		if dec.pos == start {
+			// An empty element would be read forever.
+			return fmt.Errorf("fill_rest: element %d of type %s consumed no bytes", out.Len(), elem.Elem().Type())
+		}
+		out = reflect.Append(out, elem.Elem())
+		if err := opt.checkMaxLen(out.Len()); err != nil {
+			return err
+		}
+		dec.reportProgress()
+	}
+	if err := opt.checkLen(out.Len()); err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read fill_rest", zap.Int("len", out.Len()))
+	}
+	rv.Set(out)
+	return nil
+}
+
+// encodeFillRestField writes the elements of the `bin:"fill_rest"` field
+// at index fieldIndex of the struct type rt one after the other, without a length prefix.
+func (e *Encoder) encodeFillRestField(rt reflect.Type, fieldIndex int, rv reflect.Value) error {
+	if err := checkFillRestField(rt, fieldIndex); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.Encode(rv.Index(i).Interface()); err != nil {
+			return fmt.Errorf("fill_rest: element %d: %w", i, err)
+		}
+	}
+	return nil
+}
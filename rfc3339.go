@@ -0,0 +1,50 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeRFC3339 reads a `bin:"rfc3339"` time.Time field,
+// encoded as a string field holding an RFC 3339 timestamp.
+func (dec *Decoder) decodeRFC3339(rv reflect.Value) error {
+	if rv.Type() != timeType {
+		return fmt.Errorf("rfc3339: expected a time.Time field, got %s", rv.Type())
+	}
+	var s string
+	if err := dec.Decode(&s); err != nil {
+		return fmt.Errorf("rfc3339: %w", err)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("rfc3339: %w", err)
+	}
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// encodeRFC3339 writes a `bin:"rfc3339"` time.Time field
+// as a string field, with nanosecond precision.
+func (e *Encoder) encodeRFC3339(rv reflect.Value) error {
+	if rv.Type() != timeType {
+		return fmt.Errorf("rfc3339: expected a time.Time field, got %s", rv.Type())
+	}
+	return e.Encode(rv.Interface().(time.Time).Format(time.RFC3339Nano))
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// The encodings of a `bin:"runes=..."` []rune field.
+const (
+	// runesUTF8 encodes the runes as a string: UTF-8,
+	// prefixed by the length of the string in bytes.
+	runesUTF8 = "utf8"
+	// runesUTF32 encodes the runes as 32-bit code points,
+	// prefixed by the number of runes.
+	runesUTF32 = "utf32"
+)
+
+func checkRunes(rv reflect.Value, mode string) error {
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Int32 {
+		return fmt.Errorf("runes: expected a []rune field, got %s", rv.Type())
+	}
+	if mode != runesUTF8 && mode != runesUTF32 {
+		return fmt.Errorf("runes: unknown encoding %q, expected runes=utf8 or runes=utf32", mode)
+	}
+	return nil
+}
+
+// decodeRunes reads a `bin:"runes=..."` []rune field,
+// returning an error on invalid UTF-8 or code points.
+func (dec *Decoder) decodeRunes(rv reflect.Value, mode string, order binary.ByteOrder) error {
+	if err := checkRunes(rv, mode); err != nil {
+		return err
+	}
+
+	var runes []rune
+	if mode == runesUTF8 {
+		var s string
+		var err error
+		if dec.IsBin() {
+			s, err = dec.ReadRustString()
+		} else {
+			s, err = dec.ReadString()
+		}
+		if err != nil {
+			return fmt.Errorf("runes: %w", err)
+		}
+		if !utf8.ValidString(s) {
+			return fmt.Errorf("runes: invalid UTF-8 string %q", s)
+		}
+		runes = []rune(s)
+	} else {
+		count, err := dec.ReadLength()
+		if err != nil {
+			return fmt.Errorf("runes: %w", err)
+		}
+		if count > dec.Remaining()/TypeSize.Uint32 {
+			return errorf(ErrShortBuffer, "runes: %d runes exceed the %d remaining bytes", count, dec.Remaining())
+		}
+		runes = make([]rune, count)
+		for i := range runes {
+			n, err := dec.ReadUint32(order)
+			if err != nil {
+				return fmt.Errorf("runes: %w", err)
+			}
+			if !utf8.ValidRune(rune(n)) {
+				return fmt.Errorf("runes: invalid code point 0x%x at index %d", n, i)
+			}
+			runes[i] = rune(n)
+		}
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read runes", zap.String("encoding", mode), zap.Int("count", len(runes)))
+	}
+
+	out := reflect.MakeSlice(rv.Type(), len(runes), len(runes))
+	for i, r := range runes {
+		out.Index(i).SetInt(int64(r))
+	}
+	rv.Set(out)
+	return nil
+}
+
+// encodeRunes writes a `bin:"runes=..."` []rune field,
+// returning an error on invalid code points.
+func (e *Encoder) encodeRunes(rv reflect.Value, mode string, order binary.ByteOrder) error {
+	if err := checkRunes(rv, mode); err != nil {
+		return err
+	}
+	l := rv.Len()
+	for i := 0; i < l; i++ {
+		if r := rune(rv.Index(i).Int()); !utf8.ValidRune(r) {
+			return fmt.Errorf("runes: invalid code point 0x%x at index %d", r, i)
+		}
+	}
+
+	if mode == runesUTF8 {
+		buf := make([]byte, 0, l)
+		for i := 0; i < l; i++ {
+			buf = append(buf, string(rune(rv.Index(i).Int()))...)
+		}
+		if e.encoding.IsBin() {
+			return e.WriteRustString(string(buf))
+		}
+		return e.WriteString(string(buf))
+	}
+
+	if err := e.WriteLength(l); err != nil {
+		return err
+	}
+	for i := 0; i < l; i++ {
+		if err := e.WriteUint32(uint32(rv.Index(i).Int()), order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
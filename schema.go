@@ -0,0 +1,59 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Schema describes the fields of a message, in order,
+// for decoding it without a Go struct type.
+type Schema []SchemaField
+
+// SchemaField is a field of a Schema.
+type SchemaField struct {
+	Name string
+	Type reflect.Type
+	// Tag holds the options of the field, as in a `bin:"..."` struct tag
+	// (e.g. "big" or "optional"); options referring to other fields are not supported.
+	Tag string
+}
+
+// DecodeToMap decodes the fields of the schema, and returns
+// their values keyed by field name.
+func (dec *Decoder) DecodeToMap(schema Schema) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(schema))
+	for _, field := range schema {
+		if field.Type == nil {
+			return nil, fmt.Errorf("schema field %q has no type", field.Name)
+		}
+		if _, found := out[field.Name]; found {
+			return nil, fmt.Errorf("schema field %q is duplicated", field.Name)
+		}
+
+		// Decode the field as the only field of a struct, for its tag to apply.
+		holder := reflect.New(reflect.StructOf([]reflect.StructField{{
+			Name: "Value",
+			Type: field.Type,
+			Tag:  reflect.StructTag(fmt.Sprintf("bin:%q", field.Tag)),
+		}}))
+		if err := dec.Decode(holder.Interface()); err != nil {
+			return nil, fmt.Errorf("schema field %q: %w", field.Name, err)
+		}
+		out[field.Name] = holder.Elem().Field(0).Interface()
+	}
+	return out, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_DecodeToMap(t *testing.T) {
+	data, err := MarshalBorsh(struct {
+		Version uint8
+		Name    string
+		Amount  uint32 `bin:"big"`
+		Keys    [][2]byte
+	}{1, "alice", 42, [][2]byte{{1, 2}}})
+	require.NoError(t, err)
+
+	schema := Schema{
+		{Name: "version", Type: reflect.TypeOf(uint8(0))},
+		{Name: "name", Type: reflect.TypeOf("")},
+		{Name: "amount", Type: reflect.TypeOf(uint32(0)), Tag: "big"},
+		{Name: "keys", Type: reflect.TypeOf([][2]byte{})},
+	}
+	dec := NewBorshDecoder(data)
+	out, err := dec.DecodeToMap(schema)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"version": uint8(1),
+		"name":    "alice",
+		"amount":  uint32(42),
+		"keys":    [][2]byte{{1, 2}},
+	}, out)
+	assert.Equal(t, 0, dec.Remaining())
+
+	_, err = NewBorshDecoder(data[:3]).DecodeToMap(schema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `schema field "name": `)
+
+	_, err = NewBorshDecoder(data).DecodeToMap(Schema{{Name: "a"}})
+	assert.EqualError(t, err, `schema field "a" has no type`)
+}
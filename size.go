@@ -0,0 +1,177 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+)
+
+// knownStaticSizes holds the encoded size of the types of this package
+// that implement BinaryUnmarshaler and always take the same number of bytes.
+var knownStaticSizes = map[reflect.Type]int{
+	reflect.TypeOf(Uint128{}):        TypeSize.Uint128,
+	reflect.TypeOf(Int128{}):         TypeSize.Uint128,
+	reflect.TypeOf(Float128{}):       TypeSize.Uint128,
+	reflect.TypeOf(Int64(0)):         TypeSize.Uint64,
+	reflect.TypeOf(Uint64(0)):        TypeSize.Uint64,
+	reflect.TypeOf(JSONFloat64(0)):   TypeSize.Float64,
+	reflect.TypeOf(Bool(false)):      TypeSize.Bool,
+	reflect.TypeOf(Money{}):          TypeSize.Uint64 + TypeSize.Uint8 + TypeSize.CurrencyName,
+	reflect.TypeOf(Symbol{}):         TypeSize.Uint8 + TypeSize.CurrencyName,
+	reflect.TypeOf(Asset{}):          TypeSize.Uint64 + TypeSize.Uint8 + TypeSize.CurrencyName,
+	reflect.TypeOf(MessageHeader{}):  3,
+	reflect.TypeOf(EmptyVariant{}):   0,
+	reflect.TypeOf(PresenceBitmap{}): 0,
+	reflect.TypeOf(UUID{}):           len(UUID{}),
+}
+
+// FixedSize returns the number of bytes that a value of the provided type always
+// takes once encoded with enc, and false if the size depends on the value
+// (e.g. strings, slices, maps, optionals, or types with a custom unmarshaler),
+// or if enc is not a valid encoding.
+// It only analyzes the type, so it's cheap enough to validate buffer sizes or pre-allocate.
+// The size doesn't apply with LengthEncodingBincodeVarint, which encodes the integers as varints.
+func FixedSize(rt reflect.Type, enc Encoding) (int, bool) {
+	if rt == nil || !isValidEncoding(enc) {
+		return 0, false
+	}
+	// The fixed-size types take the same number of bytes in all the encodings.
+	return staticSizeOf(rt)
+}
+
+// fixedSizeOf returns the size of the values of type rt like staticSizeOf, and false
+// if the settings of dec make it vary: the C layout of all the structs,
+// or the integers encoded as varints by LengthEncodingBincodeVarint.
+func (dec *Decoder) fixedSizeOf(rt reflect.Type) (int, bool) {
+	if dec.cLayout || dec.lengthEncoding == LengthEncodingBincodeVarint {
+		return 0, false
+	}
+	return staticSizeOf(rt)
+}
+
+// staticSizeOf returns the number of bytes that a value of the provided type
+// always takes once encoded, and false if the size depends on the value
+// (e.g. strings, slices, optionals, or types with a custom unmarshaler).
+func staticSizeOf(rt reflect.Type) (int, bool) {
+	if size, ok := knownStaticSizes[rt]; ok {
+		return size, true
+	}
+	if reflect.PtrTo(rt).Implements(unmarshalableType) || rt.Implements(unmarshalableType) {
+		return 0, false
+	}
+
+	switch rt.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1, true
+	case reflect.Int16, reflect.Uint16:
+		return 2, true
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4, true
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 8, true
+	case reflect.Array:
+		size, ok := staticSizeOf(rt.Elem())
+		if !ok {
+			return 0, false
+		}
+		return size * rt.Len(), true
+	case reflect.Struct:
+		if hasPresenceBitmap(rt) {
+			return 0, false
+		}
+		cLayout := hasCLayout(rt)
+		order, err := wireFieldOrder(rt)
+		if err != nil {
+			return 0, false
+		}
+		total := 0
+		for _, i := range order {
+			field := rt.Field(i)
+			tag := parseFieldTag(field.Tag)
+			if tag.Skip || field.PkgPath != "" {
+				// Unexported fields are not encoded.
+				continue
+			}
+			if tag.Optional || tag.BinaryExtension || tag.Codec != "" || tag.IsBorshEnum || tag.FieldCount ||
+				tag.RFC3339 || tag.BigFloat || tag.If != "" {
+				return 0, false
+			}
+			if cLayout {
+				padding, err := cPaddingFor(total, field.Type)
+				if err != nil {
+					return 0, false
+				}
+				total += padding
+			}
+			if tag.Packed && field.Type.Kind() == reflect.Array && field.Type.Elem().Kind() == reflect.Bool {
+				total += (field.Type.Len() + 7) / 8
+				continue
+			}
+			if tag.U24 && field.Type.Kind() == reflect.Uint32 {
+				total += 3
+				continue
+			}
+			if tag.IntN != 0 && checkIntNField(reflect.Zero(field.Type), tag.IntN) == nil {
+				total += tag.IntN
+				continue
+			}
+			if tag.Q != nil && checkQField(reflect.Zero(field.Type), tag.Q) == nil {
+				width, err := qWidth(tag.Q.IntBits, tag.Q.FracBits)
+				if err != nil {
+					return 0, false
+				}
+				total += width
+				continue
+			}
+			if tag.NoneIf != nil {
+				if _, err := noneIfSentinel(reflect.Zero(field.Type), *tag.NoneIf); err != nil {
+					return 0, false
+				}
+				total += int(field.Type.Elem().Size())
+				continue
+			}
+			if tag.TriBool && field.Type == reflect.TypeOf((*bool)(nil)) {
+				total += TypeSize.Bool
+				continue
+			}
+			if field.Type == lazyNumberType {
+				if tag.Lazy < 1 || tag.Lazy > TypeSize.Uint64 {
+					return 0, false
+				}
+				total += tag.Lazy
+				continue
+			}
+			if tag.ASCIIStr && tag.ASCIIStrLen > 0 && field.Type.Kind() == reflect.String {
+				total += tag.ASCIIStrLen
+				continue
+			}
+			size, ok := staticSizeOf(field.Type)
+			if !ok {
+				return 0, false
+			}
+			total += size
+		}
+		if cLayout {
+			padding, err := cPaddingFor(total, rt)
+			if err != nil {
+				return 0, false
+			}
+			total += padding
+		}
+		return total, true
+	default:
+		return 0, false
+	}
+}
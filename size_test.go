@@ -0,0 +1,123 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_staticSizeOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		size   int
+		static bool
+	}{
+		{"uint8", uint8(0), 1, true},
+		{"int32", int32(0), 4, true},
+		{"float64", float64(0), 8, true},
+		{"uint128", Uint128{}, 16, true},
+		{"bin int64", Int64(0), 8, true},
+		{"array", [3]uint16{}, 6, true},
+		{"struct", struct {
+			A uint8
+			B [2]uint32
+			C string   `bin:"-"`
+			D [10]bool `bin:"packed"`
+		}{}, 11, true},
+		{"unexported", struct {
+			A      uint16
+			hidden uint64
+		}{}, 2, true},
+		{"c layout", cLayoutStruct{}, 32, true},
+		{"c layout wire order", struct {
+			_ struct{} `bin:"c_layout"`
+			A uint8    `bin:"order_index=2"`
+			B uint32   `bin:"order_index=1"`
+			C uint8    `bin:"order_index=3"`
+		}{}, 8, true},
+		{"asciistr", struct {
+			A uint8
+			S string `bin:"asciistr=16"`
+		}{}, 17, true},
+		{"u24", struct {
+			A uint32 `bin:"u24"`
+		}{}, 3, true},
+		{"i24", struct {
+			A int32 `bin:"i24"`
+			B int64 `bin:"i48"`
+		}{}, 9, true},
+		{"tribool", struct {
+			A *bool `bin:"tribool"`
+		}{}, 1, true},
+		{"string", "", 0, false},
+		{"slice", []byte{}, 0, false},
+		{"varuint32", Varuint32(0), 0, false},
+		{"optional", struct {
+			A uint8 `bin:"optional"`
+		}{}, 0, false},
+		{"rfc3339", struct {
+			T time.Time `bin:"rfc3339"`
+		}{}, 0, false},
+		{"bigfloat", struct {
+			F big.Float `bin:"bigfloat"`
+		}{}, 0, false},
+		{"nested string", struct {
+			A [2]string
+		}{}, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			size, static := staticSizeOf(reflect.TypeOf(test.value))
+			assert.Equal(t, test.static, static)
+			assert.Equal(t, test.size, size)
+		})
+	}
+}
+
+func TestFixedSize(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			size, ok := FixedSize(reflect.TypeOf(uint64(0)), encoding)
+			assert.True(t, ok)
+			assert.Equal(t, 8, size)
+
+			size, ok = FixedSize(reflect.TypeOf(cLayoutStruct{}), encoding)
+			assert.True(t, ok)
+			assert.Equal(t, 32, size)
+
+			size, ok = FixedSize(reflect.TypeOf(struct {
+				A      uint16
+				hidden uint64
+			}{}), encoding)
+			assert.True(t, ok)
+			assert.Equal(t, 2, size)
+
+			_, ok = FixedSize(reflect.TypeOf([]uint32{}), encoding)
+			assert.False(t, ok)
+		})
+	}
+
+	_, ok := FixedSize(reflect.TypeOf(uint64(0)), Encoding(0xff))
+	assert.False(t, ok)
+	_, ok = FixedSize(nil, EncodingBin)
+	assert.False(t, ok)
+}
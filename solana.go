@@ -0,0 +1,173 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math"
+)
+
+// MessageHeader is the header of a Solana transaction message: the number of
+// signatures required, and the number of read-only signed and unsigned accounts.
+// It's encoded as 3 bytes.
+type MessageHeader struct {
+	NumRequiredSignatures       uint8
+	NumReadonlySignedAccounts   uint8
+	NumReadonlyUnsignedAccounts uint8
+}
+
+// MessageAddressTableLookup is an address table lookup of a Solana versioned (v0) message:
+// the indexes of the writable and read-only accounts loaded from the address lookup table.
+//
+// It's encoded as the table account (32 bytes), then the writable and the read-only
+// indexes, each prefixed by their compact-u16 count.
+type MessageAddressTableLookup struct {
+	AccountKey      [32]byte
+	WritableIndexes []uint8
+	ReadonlyIndexes []uint8
+}
+
+// minAddressTableLookupSize is the size of an address table lookup without indexes.
+const minAddressTableLookupSize = 32 + 1 + 1
+
+func (h *MessageHeader) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadMessageHeader()
+	if err != nil {
+		return err
+	}
+	*h = out
+	return nil
+}
+
+func (h MessageHeader) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteMessageHeader(h)
+}
+
+func (l *MessageAddressTableLookup) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadAddressTableLookup()
+	if err != nil {
+		return err
+	}
+	*l = out
+	return nil
+}
+
+func (l MessageAddressTableLookup) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteAddressTableLookup(l)
+}
+
+// ReadMessageHeader reads the header of a Solana transaction message: see MessageHeader.
+func (dec *Decoder) ReadMessageHeader() (out MessageHeader, err error) {
+	data, err := dec.ReadNBytes(3)
+	if err != nil {
+		return out, fmt.Errorf("message header: %w", err)
+	}
+	out.NumRequiredSignatures = data[0]
+	out.NumReadonlySignedAccounts = data[1]
+	out.NumReadonlyUnsignedAccounts = data[2]
+	return out, nil
+}
+
+// ReadAddressTableLookup reads an address table lookup: see MessageAddressTableLookup.
+func (dec *Decoder) ReadAddressTableLookup() (out MessageAddressTableLookup, err error) {
+	key, err := dec.ReadNBytes(32)
+	if err != nil {
+		return out, fmt.Errorf("address table lookup: account key: %w", err)
+	}
+	copy(out.AccountKey[:], key)
+	if out.WritableIndexes, err = dec.readCompactU16Bytes(); err != nil {
+		return out, fmt.Errorf("address table lookup: writable indexes: %w", err)
+	}
+	if out.ReadonlyIndexes, err = dec.readCompactU16Bytes(); err != nil {
+		return out, fmt.Errorf("address table lookup: readonly indexes: %w", err)
+	}
+	return out, nil
+}
+
+// ReadAddressTableLookups reads the address table lookups of a Solana
+// versioned (v0) message, prefixed by their compact-u16 count.
+func (dec *Decoder) ReadAddressTableLookups() (out []MessageAddressTableLookup, err error) {
+	count, err := dec.ReadCompactU16Length()
+	if err != nil {
+		return nil, fmt.Errorf("address table lookups: %w", err)
+	}
+	if count > dec.Remaining()/minAddressTableLookupSize {
+		return nil, errorf(ErrShortBuffer, "address table lookups: %d lookups exceed the %d remaining bytes", count, dec.Remaining())
+	}
+	out = make([]MessageAddressTableLookup, count)
+	for i := range out {
+		if out[i], err = dec.ReadAddressTableLookup(); err != nil {
+			return nil, fmt.Errorf("address table lookups: lookup %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// readCompactU16Bytes reads bytes prefixed by their compact-u16 count.
+func (dec *Decoder) readCompactU16Bytes() ([]byte, error) {
+	count, err := dec.ReadCompactU16Length()
+	if err != nil || count == 0 {
+		// Empty indexes are left nil.
+		return nil, err
+	}
+	return dec.ReadNBytes(count)
+}
+
+// WriteMessageHeader writes the header of a Solana transaction message: see MessageHeader.
+func (e *Encoder) WriteMessageHeader(h MessageHeader) error {
+	return e.WriteBytes([]byte{h.NumRequiredSignatures, h.NumReadonlySignedAccounts, h.NumReadonlyUnsignedAccounts}, false)
+}
+
+// WriteAddressTableLookup writes an address table lookup: see MessageAddressTableLookup.
+func (e *Encoder) WriteAddressTableLookup(l MessageAddressTableLookup) error {
+	if err := e.WriteBytes(l.AccountKey[:], false); err != nil {
+		return err
+	}
+	if err := e.writeCompactU16Bytes(l.WritableIndexes); err != nil {
+		return fmt.Errorf("address table lookup: writable indexes: %w", err)
+	}
+	if err := e.writeCompactU16Bytes(l.ReadonlyIndexes); err != nil {
+		return fmt.Errorf("address table lookup: readonly indexes: %w", err)
+	}
+	return nil
+}
+
+// WriteAddressTableLookups writes the address table lookups of a Solana
+// versioned (v0) message, prefixed by their compact-u16 count.
+func (e *Encoder) WriteAddressTableLookups(lookups []MessageAddressTableLookup) error {
+	if len(lookups) > math.MaxUint16 {
+		return fmt.Errorf("address table lookups: %d lookups overflow a compact-u16 count", len(lookups))
+	}
+	if err := e.WriteCompactU16Length(len(lookups)); err != nil {
+		return err
+	}
+	for _, l := range lookups {
+		if err := e.WriteAddressTableLookup(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCompactU16Bytes writes bytes prefixed by their compact-u16 count.
+func (e *Encoder) writeCompactU16Bytes(b []byte) error {
+	if len(b) > math.MaxUint16 {
+		return fmt.Errorf("%d bytes overflow a compact-u16 count", len(b))
+	}
+	if err := e.WriteCompactU16Length(len(b)); err != nil {
+		return err
+	}
+	return e.WriteBytes(b, false)
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageV0Components(t *testing.T) {
+	var key [32]byte
+	key[0], key[31] = 0xaa, 0xbb
+
+	data := append([]byte{
+		0x01, 0x00, 0x02, // header
+		0x01, // 1 lookup
+	}, key[:]...)
+	data = append(data,
+		0x02, 0x03, 0x05, // writable indexes
+		0x00, // readonly indexes
+	)
+
+	dec := NewBinDecoder(data)
+	header, err := dec.ReadMessageHeader()
+	require.NoError(t, err)
+	assert.Equal(t, MessageHeader{NumRequiredSignatures: 1, NumReadonlyUnsignedAccounts: 2}, header)
+
+	lookups, err := dec.ReadAddressTableLookups()
+	require.NoError(t, err)
+	expected := []MessageAddressTableLookup{{AccountKey: key, WritableIndexes: []uint8{3, 5}}}
+	assert.Equal(t, expected, lookups)
+	assert.False(t, dec.HasRemaining())
+
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteMessageHeader(header))
+	require.NoError(t, enc.WriteAddressTableLookups(lookups))
+	assert.Equal(t, data, buf.Bytes())
+
+	// As struct fields, with the compact-u16 encoding of Solana.
+	type message struct {
+		Header  MessageHeader
+		Lookups []MessageAddressTableLookup
+	}
+	var msg message
+	require.NoError(t, NewCompactU16Decoder(data).Decode(&msg))
+	assert.Equal(t, message{Header: header, Lookups: expected}, msg)
+
+	_, err = NewBinDecoder(data[3:20]).ReadAddressTableLookups()
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+
+	_, err = NewBinDecoder(data[3 : len(data)-2]).ReadAddressTableLookups()
+	assert.Error(t, err)
+
+	err = NewBinEncoder(new(bytes.Buffer)).WriteAddressTableLookup(MessageAddressTableLookup{WritableIndexes: make([]uint8, 1<<16)})
+	assert.EqualError(t, err, "address table lookup: writable indexes: 65536 bytes overflow a compact-u16 count")
+}
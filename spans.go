@@ -0,0 +1,64 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// spanRecorder records the byte ranges of the fields of
+// the struct decoded at a given depth; see DecodeWithSpans.
+type spanRecorder struct {
+	// depth is the length of the field path of the recorded struct.
+	depth int
+	// open is the name of the field being decoded, or "".
+	open  string
+	start int
+	spans map[string][2]int
+}
+
+// DecodeWithSpans decodes v like Decode, and returns the byte range [start, end)
+// of each top-level field of the struct within the data of the decoder,
+// e.g. to build an index of field offsets and patch a field in place later.
+// The fields that are not decoded (skipped, absent, or past a field count)
+// have no range; a field's range excludes the C layout padding around it.
+// Types that implement BinaryUnmarshaler decode themselves, and have no ranges.
+func (dec *Decoder) DecodeWithSpans(v interface{}) (map[string][2]int, error) {
+	recorder := &spanRecorder{depth: len(dec.path), spans: map[string][2]int{}}
+	prev := dec.spans
+	dec.spans = recorder
+	defer func() { dec.spans = prev }()
+
+	if err := dec.Decode(v); err != nil {
+		return nil, err
+	}
+	return recorder.spans, nil
+}
+
+// startSpan opens the range of the named field of the struct whose fields
+// are at depth pathDepth, at the current position of the decoder.
+func (dec *Decoder) startSpan(pathDepth int, name string) {
+	if dec.spans == nil || dec.spans.depth != pathDepth {
+		return
+	}
+	dec.spans.open = name
+	dec.spans.start = dec.pos
+}
+
+// endSpan closes the range of the field of the struct whose fields
+// are at depth pathDepth, if one is open, at the current position of the decoder.
+func (dec *Decoder) endSpan(pathDepth int) {
+	if dec.spans == nil || dec.spans.depth != pathDepth || dec.spans.open == "" {
+		return
+	}
+	dec.spans.spans[dec.spans.open] = [2]int{dec.spans.start, dec.pos}
+	dec.spans.open = ""
+}
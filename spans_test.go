@@ -0,0 +1,88 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_DecodeWithSpans(t *testing.T) {
+	type inner struct {
+		A uint8
+		B uint16
+	}
+	type record struct {
+		Version uint8
+		Name    string
+		Inner   inner
+		Skipped uint64 `bin:"-"`
+		Extra   uint32 `bin:"if=Version==2"`
+		Balance uint64
+	}
+
+	in := record{Version: 1, Name: "abc", Inner: inner{A: 1, B: 2}, Balance: 100}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			data := buf.Bytes()
+
+			var out record
+			spans, err := NewDecoderWithEncoding(data, encoding).DecodeWithSpans(&out)
+			require.NoError(t, err)
+			assert.Equal(t, in, out)
+
+			nameEnd := len(data) - 11
+			assert.Equal(t, map[string][2]int{
+				"Version": {0, 1},
+				"Name":    {1, nameEnd},
+				"Inner":   {nameEnd, nameEnd + 3},
+				"Balance": {nameEnd + 3, len(data)},
+			}, spans)
+
+			// Patch the balance in place.
+			balance := spans["Balance"]
+			copy(data[balance[0]:balance[1]], []byte{0xe8, 0x03, 0, 0, 0, 0, 0, 0})
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&out))
+			assert.Equal(t, uint64(1000), out.Balance)
+		})
+	}
+}
+
+func TestDecoder_DecodeWithSpans_CLayout(t *testing.T) {
+	type header struct {
+		_     struct{} `bin:"c_layout"`
+		Flag  uint8
+		Value uint32
+		Tail  uint8
+	}
+	data := []byte{0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00}
+
+	var out header
+	dec := NewBinDecoder(data)
+	spans, err := dec.DecodeWithSpans(&out)
+	require.NoError(t, err)
+	assert.Equal(t, header{Flag: 1, Value: 2, Tail: 3}, out)
+	// The ranges exclude the padding.
+	assert.Equal(t, map[string][2]int{"Flag": {0, 1}, "Value": {4, 8}, "Tail": {8, 9}}, spans)
+	assert.Equal(t, 0, dec.Remaining())
+
+	// The decoder stops recording.
+	assert.Nil(t, dec.spans)
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// checkTrailerLenField validates a `bin:"trailer_len"` field, which must be
+// the last field of the struct, and a slice.
+func checkTrailerLenField(rt reflect.Type, fieldIndex int) error {
+	last, err := isLastWireField(rt, fieldIndex)
+	if err != nil {
+		return err
+	}
+	if !last {
+		return fmt.Errorf("trailer_len: must be the last field of %s", rt)
+	}
+	if rt.Field(fieldIndex).Type.Kind() != reflect.Slice {
+		return fmt.Errorf("trailer_len: expected a slice field, got %s", rt.Field(fieldIndex).Type)
+	}
+	return nil
+}
+
+// decodeTrailerLenField reads the `bin:"trailer_len"` slice field at index fieldIndex
+// of the struct type rt, whose elements come first, followed by their count: a u32,
+// in the byte order of the field, at the end of the data (or of the frame of a sub-decoder).
+// The count is peeked first, then the elements are decoded forward with the byte order
+// of the field, bounded so that the trailer isn't read as data. This relies on the decoder
+// holding all the data, which all decoders do, since none of them reads from a stream.
+func (dec *Decoder) decodeTrailerLenField(rt reflect.Type, fieldIndex int, rv reflect.Value, opt *option) error {
+	if err := checkTrailerLenField(rt, fieldIndex); err != nil {
+		return err
+	}
+	trailer, err := dec.PeekTail(TypeSize.Uint32)
+	if err != nil {
+		return fmt.Errorf("trailer_len: %w", err)
+	}
+	count := opt.Order.Uint32(trailer)
+
+	elems := dec.subDecoder(dec.data[dec.pos : len(dec.data)-TypeSize.Uint32])
+	elems.order = opt.Order
+	// Even elements that take no bytes are bounded by the remaining bytes,
+	// so that a bogus count isn't looped over.
+	if uint64(count) > uint64(elems.Remaining()) {
+		return errorf(ErrInvalidLength, "trailer_len: %d elements exceed the %d bytes before the trailer", count, elems.Remaining())
+	}
+	if err := opt.checkLen(int(count)); err != nil {
+		return err
+	}
+
+	out := reflect.MakeSlice(rv.Type(), 0, 0)
+	for i := 0; i < int(count); i++ {
+		elem := reflect.New(rv.Type().Elem())
+		if err := elems.Decode(elem.Interface()); err != nil {
+			return fmt.Errorf("trailer_len: element %d: %w", i, err)
+		}
+		out = reflect.Append(out, elem.Elem())
+	}
+	if elems.HasRemaining() {
+		return errorf(ErrInvalidLength, "trailer_len: %d bytes left between the %d elements and the trailer", elems.Remaining(), count)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read trailer_len", zap.Uint32("len", count))
+	}
+	dec.pos = len(dec.data)
+	rv.Set(out)
+	return nil
+}
+
+// encodeTrailerLenField writes the elements of the `bin:"trailer_len"` field
+// at index fieldIndex of the struct type rt with the byte order of the field,
+// followed by their count as a u32.
+func (e *Encoder) encodeTrailerLenField(rt reflect.Type, fieldIndex int, rv reflect.Value, order binary.ByteOrder) error {
+	if err := checkTrailerLenField(rt, fieldIndex); err != nil {
+		return err
+	}
+	if uint64(rv.Len()) > 0xffffffff {
+		return fmt.Errorf("trailer_len: %d elements overflow a u32", rv.Len())
+	}
+	defer func(prev binary.ByteOrder) { e.order = prev }(e.order)
+	e.order = order
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.Encode(rv.Index(i).Interface()); err != nil {
+			return fmt.Errorf("trailer_len: element %d: %w", i, err)
+		}
+	}
+	return e.WriteUint32(uint32(rv.Len()), order)
+}
@@ -0,0 +1,509 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	unionsMu sync.RWMutex
+	// unions maps an interface type to its variants.
+	unions = map[reflect.Type]*unionVariants{}
+)
+
+type unionVariants struct {
+	// types holds the concrete types of the variants, keyed by discriminant.
+	types map[string]reflect.Type
+	// units holds the values of the unit variants, which have no payload,
+	// keyed by discriminant.
+	units map[string]reflect.Value
+	// discriminants holds the registered discriminant values, keyed by discriminant.
+	discriminants map[string]reflect.Value
+	// width is the size in bytes of the inline discriminants set by RegisterEnum,
+	// or 0 if they are encoded as their registered type.
+	width int
+}
+
+// addDiscriminant registers a discriminant value, with its key.
+func (u *unionVariants) addDiscriminant(key string, discriminant reflect.Value) {
+	u.discriminants[key] = discriminant
+}
+
+// discriminantType returns the type of the discriminants of the union,
+// or nil if they don't all have the same type.
+func (u *unionVariants) discriminantType() reflect.Type {
+	var out reflect.Type
+	for _, discriminant := range u.discriminants {
+		if out != nil && discriminant.Type() != out {
+			return nil
+		}
+		out = discriminant.Type()
+	}
+	return out
+}
+
+// lookup returns the variant registered for the discriminant key: either
+// its concrete type, or the value of a unit variant.
+func (u *unionVariants) lookup(ifaceType reflect.Type, key string) (reflect.Type, reflect.Value, error) {
+	if unit, found := u.units[key]; found {
+		return unit.Type(), unit, nil
+	}
+	variantType, found := u.types[key]
+	if !found {
+		return nil, reflect.Value{}, fmt.Errorf("no variant of %s registered for discriminant %q", ifaceType, key)
+	}
+	return variantType, reflect.Value{}, nil
+}
+
+// discriminantOf returns the discriminant of the variant of the provided type,
+// and whether it's a unit variant.
+func (u *unionVariants) discriminantOf(ifaceType, variantType reflect.Type) (reflect.Value, bool, error) {
+	var out reflect.Value
+	isUnit := false
+	matches := 0
+	for key, typ := range u.types {
+		if typ == variantType {
+			out = u.discriminants[key]
+			matches++
+		}
+	}
+	for key, unit := range u.units {
+		if unit.Type() == variantType {
+			out, isUnit = u.discriminants[key], true
+			matches++
+		}
+	}
+	switch matches {
+	case 0:
+		return reflect.Value{}, false, fmt.Errorf("no variant of %s registered for type %s", ifaceType, variantType)
+	case 1:
+		return out, isUnit, nil
+	default:
+		return reflect.Value{}, false, fmt.Errorf("type %s is registered for several variants of %s", variantType, ifaceType)
+	}
+}
+
+// lookupUnion returns the variants of the union of the provided interface type.
+// The variants must only be read with unionsMu held.
+func lookupUnion(ifaceType reflect.Type) (*unionVariants, bool) {
+	variants, found := unions[ifaceType]
+	return variants, found
+}
+
+// isUnionType returns true if rt is the interface type of a registered union.
+func isUnionType(rt reflect.Type) bool {
+	if rt.Kind() != reflect.Interface {
+		return false
+	}
+	unionsMu.RLock()
+	defer unionsMu.RUnlock()
+	_, found := lookupUnion(rt)
+	return found
+}
+
+// RegisterUnion registers the variants of a tagged union whose discriminant
+// is a separate, earlier field of the same struct.
+//
+// The iface parameter must be a nil pointer to the interface type of the union field,
+// and variants maps each discriminant value (an integer or a string, as found in
+// the discriminant field) to a value of the concrete type to decode.
+//
+// A value of the interface type that is not a struct field with a union_tag
+// (e.g. an element of an array or a slice) is encoded inline: its discriminant, then its payload.
+// This requires all the discriminants to have the same, encodable, type (e.g. uint8).
+//
+//	type Shape interface{}
+//
+//	bin.RegisterUnion((*Shape)(nil), map[interface{}]interface{}{
+//		uint8(0): Circle{},
+//		uint8(1): &Square{},
+//	})
+//
+//	type Drawing struct {
+//		Kind  uint8
+//		Name  string
+//		Shape Shape `bin:"union_tag=Kind"`
+//	}
+//
+// Since the registry is keyed by the interface type, each union
+// should have its own interface type.
+// RegisterUnion panics on invalid input or if the interface type is already registered.
+func RegisterUnion(iface interface{}, variants map[interface{}]interface{}) {
+	ifaceType := reflect.TypeOf(iface)
+	if ifaceType == nil || ifaceType.Kind() != reflect.Ptr || ifaceType.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("union must be registered with a nil pointer to an interface type, got %T", iface))
+	}
+	ifaceType = ifaceType.Elem()
+
+	union := &unionVariants{
+		types:         make(map[string]reflect.Type, len(variants)),
+		units:         map[string]reflect.Value{},
+		discriminants: make(map[string]reflect.Value, len(variants)),
+	}
+	for discriminant, variant := range variants {
+		key, err := unionKey(reflect.ValueOf(discriminant))
+		if err != nil {
+			panic(fmt.Sprintf("union %s: %s", ifaceType, err))
+		}
+		variantType := reflect.TypeOf(variant)
+		if variantType == nil || !variantType.Implements(ifaceType) {
+			panic(fmt.Sprintf("union %s: variant %T for discriminant %q doesn't implement the interface", ifaceType, variant, key))
+		}
+		union.types[key] = variantType
+		union.addDiscriminant(key, reflect.ValueOf(discriminant))
+	}
+
+	unionsMu.Lock()
+	defer unionsMu.Unlock()
+
+	if _, found := unions[ifaceType]; found {
+		panic(fmt.Sprintf("union %s is already registered", ifaceType))
+	}
+	unions[ifaceType] = union
+}
+
+// RegisterUnitVariant registers a unit variant of a tagged union, i.e. a variant
+// without payload: when the discriminant field has the provided value,
+// the union field is set to singleton without reading any bytes, and
+// encoding a value of the type of singleton writes no bytes.
+//
+//	type Status interface{}
+//	type Pending struct{}
+//
+//	bin.RegisterUnion((*Status)(nil), map[interface{}]interface{}{
+//		uint8(1): Failed{},
+//	})
+//	bin.RegisterUnitVariant((*Status)(nil), uint8(0), Pending{})
+//
+// The union must already be registered with RegisterUnion, possibly with no variants.
+// RegisterUnitVariant panics on invalid input or if the discriminant is already registered.
+func RegisterUnitVariant(iface interface{}, discriminant interface{}, singleton interface{}) {
+	ifaceType := reflect.TypeOf(iface)
+	if ifaceType == nil || ifaceType.Kind() != reflect.Ptr || ifaceType.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("union must be registered with a nil pointer to an interface type, got %T", iface))
+	}
+	ifaceType = ifaceType.Elem()
+
+	key, err := unionKey(reflect.ValueOf(discriminant))
+	if err != nil {
+		panic(fmt.Sprintf("union %s: %s", ifaceType, err))
+	}
+	if singleton == nil || !reflect.TypeOf(singleton).Implements(ifaceType) {
+		panic(fmt.Sprintf("union %s: unit variant %T for discriminant %q doesn't implement the interface", ifaceType, singleton, key))
+	}
+
+	unionsMu.Lock()
+	defer unionsMu.Unlock()
+
+	variants, found := lookupUnion(ifaceType)
+	if !found {
+		panic(fmt.Sprintf("union %s is not registered", ifaceType))
+	}
+	_, isType := variants.types[key]
+	_, isUnit := variants.units[key]
+	if isType || isUnit {
+		panic(fmt.Sprintf("union %s: discriminant %q is already registered", ifaceType, key))
+	}
+	if variants.width != 0 {
+		if err := checkDiscriminantWidth(reflect.ValueOf(discriminant), variants.width); err != nil {
+			panic(fmt.Sprintf("union %s: %s", ifaceType, err))
+		}
+	}
+	variants.units[key] = reflect.ValueOf(singleton)
+	variants.addDiscriminant(key, reflect.ValueOf(discriminant))
+}
+
+// RegisterEnum sets the width in bytes (1, 2, 4 or 8) of the discriminants of a union
+// that are encoded inline (see RegisterUnion), like the discriminant of a Rust enum
+// with a #[repr(u16)], instead of the size of their registered type: they are encoded
+// as unsigned integers of that width, in the byte order of the decoder or encoder
+// (always little endian for Borsh). The discriminants can then be of any integer types.
+//
+//	bin.RegisterUnion((*Instruction)(nil), map[interface{}]interface{}{
+//		0:   Transfer{},
+//		300: Close{},
+//	})
+//	bin.RegisterEnum((*Instruction)(nil), 2)
+//
+// Discriminant fields tagged with union_tag aren't affected, since they have their own type.
+// The union must already be registered with RegisterUnion.
+// RegisterEnum panics on invalid input, or if a discriminant doesn't fit in the width.
+func RegisterEnum(iface interface{}, discriminantWidth int) {
+	ifaceType := reflect.TypeOf(iface)
+	if ifaceType == nil || ifaceType.Kind() != reflect.Ptr || ifaceType.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("union must be registered with a nil pointer to an interface type, got %T", iface))
+	}
+	ifaceType = ifaceType.Elem()
+	switch discriminantWidth {
+	case 1, 2, 4, 8:
+	default:
+		panic(fmt.Sprintf("union %s: invalid discriminant width of %d bytes", ifaceType, discriminantWidth))
+	}
+
+	unionsMu.Lock()
+	defer unionsMu.Unlock()
+
+	variants, found := lookupUnion(ifaceType)
+	if !found {
+		panic(fmt.Sprintf("union %s is not registered", ifaceType))
+	}
+	for _, discriminant := range variants.discriminants {
+		if err := checkDiscriminantWidth(discriminant, discriminantWidth); err != nil {
+			panic(fmt.Sprintf("union %s: %s", ifaceType, err))
+		}
+	}
+	variants.width = discriminantWidth
+}
+
+// checkDiscriminantWidth verifies that the discriminant v is an integer
+// that fits in an unsigned integer of width bytes.
+func checkDiscriminantWidth(v reflect.Value, width int) error {
+	var n uint64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return fmt.Errorf("negative discriminant %d", v.Int())
+		}
+		n = uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = v.Uint()
+	default:
+		return fmt.Errorf("discriminant of kind %s doesn't have a width", v.Kind())
+	}
+	if width < TypeSize.Uint64 && n>>(uint(width)*8) != 0 {
+		return fmt.Errorf("discriminant %d overflows %d bytes", n, width)
+	}
+	return nil
+}
+
+// unionKey returns the registry key of a discriminant value.
+func unionKey(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.String:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported discriminant kind %s", v.Kind())
+	}
+}
+
+// unionVariant returns the variant of the union field at index fieldIndex of
+// the struct rv, as selected by the value of its discriminant field: either its concrete type,
+// or the value of a unit variant. A string discriminant is normalized by normalize, if not nil.
+func unionVariant(rt reflect.Type, rv reflect.Value, fieldIndex int, tagField string, normalize func(string) string) (reflect.Type, reflect.Value, error) {
+	discriminantField, found := rt.FieldByName(tagField)
+	if !found || len(discriminantField.Index) != 1 || discriminantField.Index[0] >= fieldIndex {
+		return nil, reflect.Value{}, fmt.Errorf("union_tag: %q is not a field preceding %q", tagField, rt.Field(fieldIndex).Name)
+	}
+	key, err := unionKey(rv.Field(discriminantField.Index[0]))
+	if err != nil {
+		return nil, reflect.Value{}, fmt.Errorf("union_tag: %w", err)
+	}
+	if normalize != nil && discriminantField.Type.Kind() == reflect.String {
+		key = normalize(key)
+	}
+
+	ifaceType := rt.Field(fieldIndex).Type
+
+	unionsMu.RLock()
+	defer unionsMu.RUnlock()
+
+	variants, found := lookupUnion(ifaceType)
+	if !found {
+		return nil, reflect.Value{}, fmt.Errorf("union_tag: no union registered for %s", ifaceType)
+	}
+	variantType, unit, err := variants.lookup(ifaceType, key)
+	if err != nil {
+		return nil, reflect.Value{}, fmt.Errorf("union_tag: %w", err)
+	}
+	return variantType, unit, nil
+}
+
+// decodeUnionField decodes the union field at index fieldIndex of the struct rv,
+// whose variant is selected by the tagField field; its payload is decoded
+// with order as the default byte order, the one of the field.
+func (dec *Decoder) decodeUnionField(rt reflect.Type, rv reflect.Value, fieldIndex int, tagField string, order binary.ByteOrder) error {
+	variantType, unit, err := unionVariant(rt, rv, fieldIndex, tagField, dec.normalizeEnumName)
+	if err != nil {
+		return err
+	}
+	if traceEnabled {
+		zlog.Debug("decode: union variant", zap.Stringer("type", variantType), zap.Bool("unit", unit.IsValid()))
+	}
+
+	return dec.decodeUnionVariant(rv.Field(fieldIndex), variantType, unit, order)
+}
+
+// decodeUnionVariant decodes the payload of a variant of the provided type into the
+// interface value rv, with order as the default byte order, or sets it to unit if it's valid.
+func (dec *Decoder) decodeUnionVariant(rv reflect.Value, variantType reflect.Type, unit reflect.Value, order binary.ByteOrder) error {
+	if unit.IsValid() {
+		rv.Set(unit)
+	} else if variantType.Kind() == reflect.Ptr {
+		value := reflect.New(variantType.Elem())
+		if err := dec.decodeWithOrder(value.Interface(), order); err != nil {
+			return err
+		}
+		rv.Set(value)
+	} else {
+		value := reflect.New(variantType)
+		if err := dec.decodeWithOrder(value.Interface(), order); err != nil {
+			return err
+		}
+		rv.Set(value.Elem())
+	}
+	return nil
+}
+
+// decodeInlineUnion decodes a value of a union interface type
+// that is encoded inline, as its discriminant then its payload,
+// with order as the default byte order.
+func (dec *Decoder) decodeInlineUnion(rv reflect.Value, order binary.ByteOrder) error {
+	ifaceType := rv.Type()
+
+	unionsMu.RLock()
+	variants, _ := lookupUnion(ifaceType)
+	discriminantType := variants.discriminantType()
+	width := variants.width
+	unionsMu.RUnlock()
+
+	var key string
+	if width != 0 {
+		if dec.IsBorsh() {
+			order = LE
+		}
+		n, err := dec.ReadUintN(width, order)
+		if err != nil {
+			return fmt.Errorf("union %s: discriminant: %w", ifaceType, err)
+		}
+		key = strconv.FormatUint(n, 10)
+	} else {
+		if discriminantType == nil {
+			return fmt.Errorf("union %s: inline discriminants must all have the same type", ifaceType)
+		}
+		discriminant := reflect.New(discriminantType)
+		if err := dec.decodeWithOrder(discriminant.Interface(), order); err != nil {
+			return fmt.Errorf("union %s: discriminant: %w", ifaceType, err)
+		}
+		var err error
+		if key, err = unionKey(discriminant.Elem()); err != nil {
+			return fmt.Errorf("union %s: %w", ifaceType, err)
+		}
+		if discriminantType.Kind() == reflect.String {
+			key = dec.normalizeEnumName(key)
+		}
+	}
+
+	unionsMu.RLock()
+	variantType, unit, err := variants.lookup(ifaceType, key)
+	unionsMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("union %s: %w", ifaceType, err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: inline union variant", zap.String("discriminant", key), zap.Stringer("type", variantType))
+	}
+	return dec.decodeUnionVariant(rv, variantType, unit, order)
+}
+
+// decodeWithOrder decodes v like Decode, with order as the default byte order.
+func (dec *Decoder) decodeWithOrder(v interface{}, order binary.ByteOrder) error {
+	defer func(prev binary.ByteOrder) { dec.order = prev }(dec.order)
+	dec.order = order
+	return dec.Decode(v)
+}
+
+// encodeWithOrder encodes v like Encode, with order as the default byte order.
+func (e *Encoder) encodeWithOrder(v interface{}, order binary.ByteOrder) error {
+	defer func(prev binary.ByteOrder) { e.order = prev }(e.order)
+	e.order = order
+	return e.Encode(v)
+}
+
+// encodeUnionField encodes the payload of the union field at index fieldIndex
+// of the struct rv with order as the default byte order, the one of the field.
+func (e *Encoder) encodeUnionField(rt reflect.Type, rv reflect.Value, fieldIndex int, tagField string, order binary.ByteOrder) error {
+	variantType, unit, err := unionVariant(rt, rv, fieldIndex, tagField, nil)
+	if err != nil {
+		return err
+	}
+	field := rv.Field(fieldIndex)
+	if field.IsNil() {
+		return fmt.Errorf("union_tag: cannot encode nil union value")
+	}
+	if field.Elem().Type() != variantType {
+		return fmt.Errorf("union_tag: value of type %s doesn't match the discriminant, expected %s", field.Elem().Type(), variantType)
+	}
+	if unit.IsValid() {
+		// Unit variants have no payload.
+		return nil
+	}
+	return e.encodeWithOrder(field.Elem().Interface(), order)
+}
+
+// encodeInlineUnion encodes a value of a union interface type
+// inline, as its discriminant then its payload, with order as the default byte order.
+func (e *Encoder) encodeInlineUnion(rv reflect.Value, order binary.ByteOrder) error {
+	ifaceType := rv.Type()
+	if rv.IsNil() {
+		return fmt.Errorf("union %s: cannot encode nil union value", ifaceType)
+	}
+
+	unionsMu.RLock()
+	variants, _ := lookupUnion(ifaceType)
+	discriminantType := variants.discriminantType()
+	width := variants.width
+	discriminant, isUnit, err := variants.discriminantOf(ifaceType, rv.Elem().Type())
+	unionsMu.RUnlock()
+	if discriminantType == nil && width == 0 {
+		return fmt.Errorf("union %s: inline discriminants must all have the same type", ifaceType)
+	}
+	if err != nil {
+		return fmt.Errorf("union %s: %w", ifaceType, err)
+	}
+
+	if width != 0 {
+		if e.IsBorsh() {
+			order = LE
+		}
+		// RegisterEnum verified that the discriminants are non-negative integers.
+		var n uint64
+		if isSignedKind(discriminant.Kind()) {
+			n = uint64(discriminant.Int())
+		} else {
+			n = discriminant.Uint()
+		}
+		err = e.WriteUintN(n, width, order)
+	} else {
+		err = e.encodeWithOrder(discriminant.Interface(), order)
+	}
+	if err != nil {
+		return fmt.Errorf("union %s: discriminant: %w", ifaceType, err)
+	}
+	if isUnit {
+		return nil
+	}
+	return e.encodeWithOrder(rv.Elem().Interface(), order)
+}
@@ -0,0 +1,268 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unionTestShape interface{}
+
+type unionTestCircle struct {
+	Radius uint16
+}
+
+type unionTestSquare struct {
+	Side uint32
+}
+
+type unionTestPoint struct{}
+
+func init() {
+	RegisterUnion((*unionTestShape)(nil), map[interface{}]interface{}{
+		0: unionTestCircle{},
+		1: &unionTestSquare{},
+	})
+	RegisterUnitVariant((*unionTestShape)(nil), 3, unionTestPoint{})
+	RegisterUnion((*unionTestCommand)(nil), map[interface{}]interface{}{
+		"transfer": unionTestTransfer{},
+	})
+	RegisterUnitVariant((*unionTestCommand)(nil), "burn", unionTestBurn{})
+}
+
+type unionTestCommand interface{}
+
+type unionTestTransfer struct {
+	Amount uint8
+}
+
+type unionTestBurn struct{}
+
+type unionTestDrawing struct {
+	Kind  uint8
+	Name  string
+	Shape unionTestShape `bin:"union_tag=Kind"`
+}
+
+func TestUnion(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       unionTestDrawing
+		expected []byte
+	}{
+		{
+			name: "value variant",
+			in:   unionTestDrawing{Kind: 0, Name: "a", Shape: unionTestCircle{Radius: 5}},
+			expected: []byte{
+				0x00,
+				0x01, 0x00, 0x00, 0x00, 'a',
+				0x05, 0x00,
+			},
+		},
+		{
+			name: "pointer variant",
+			in:   unionTestDrawing{Kind: 1, Name: "b", Shape: &unionTestSquare{Side: 6}},
+			expected: []byte{
+				0x01,
+				0x01, 0x00, 0x00, 0x00, 'b',
+				0x06, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			name: "unit variant",
+			in:   unionTestDrawing{Kind: 3, Name: "c", Shape: unionTestPoint{}},
+			expected: []byte{
+				0x03,
+				0x01, 0x00, 0x00, 0x00, 'c',
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewBorshEncoder(buf).Encode(test.in))
+			assert.Equal(t, test.expected, buf.Bytes())
+
+			var out unionTestDrawing
+			require.NoError(t, NewBorshDecoder(test.expected).Decode(&out))
+			assert.Equal(t, test.in, out)
+		})
+	}
+
+	var out unionTestDrawing
+	err := NewBinDecoder([]byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "Shape" field: union_tag: no variant of bin.unionTestShape registered for discriminant "2"`)
+
+	_, err = MarshalBin(unionTestDrawing{Kind: 0, Shape: &unionTestSquare{}})
+	assert.EqualError(t, err, `error while encoding "Shape" field: union_tag: value of type *bin.unionTestSquare doesn't match the discriminant, expected bin.unionTestCircle`)
+
+	type badTag struct {
+		Shape unionTestShape `bin:"union_tag=Kind"`
+		Kind  uint8
+	}
+	err = NewBinDecoder([]byte{0x00, 0x00}).Decode(&badTag{})
+	assert.EqualError(t, err, `error while decoding "Shape" field: union_tag: "Kind" is not a field preceding "Shape"`)
+}
+
+func TestRegisterUnitVariant(t *testing.T) {
+	assert.PanicsWithValue(t, `union bin.unionTestShape: discriminant "1" is already registered`, func() {
+		RegisterUnitVariant((*unionTestShape)(nil), 1, unionTestPoint{})
+	})
+
+	type unregistered interface{}
+	assert.PanicsWithValue(t, "union bin.unregistered is not registered", func() {
+		RegisterUnitVariant((*unregistered)(nil), 0, unionTestPoint{})
+	})
+}
+
+type unionTestToken interface{}
+
+type unionTestNumber struct {
+	Value uint16
+}
+
+type unionTestEnd struct{}
+
+func init() {
+	RegisterUnion((*unionTestToken)(nil), map[interface{}]interface{}{
+		uint8(0): unionTestNumber{},
+		uint8(1): &unionTestSquare{},
+	})
+	RegisterUnitVariant((*unionTestToken)(nil), uint8(2), unionTestEnd{})
+}
+
+func TestUnion_Inline(t *testing.T) {
+	type tokens struct {
+		Array [3]unionTestToken
+		Slice []unionTestToken
+	}
+
+	in := tokens{
+		Array: [3]unionTestToken{unionTestNumber{Value: 7}, &unionTestSquare{Side: 8}, unionTestEnd{}},
+		Slice: []unionTestToken{unionTestEnd{}, unionTestNumber{Value: 9}},
+	}
+	expected := []byte{
+		0x00, 0x07, 0x00,
+		0x01, 0x08, 0x00, 0x00, 0x00,
+		0x02,
+		0x02, 0x00, 0x00, 0x00,
+		0x02,
+		0x00, 0x09, 0x00,
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).Encode(in))
+	assert.Equal(t, expected, buf.Bytes())
+
+	var out tokens
+	require.NoError(t, NewBorshDecoder(expected).Decode(&out))
+	assert.Equal(t, in, out)
+
+	var array [1]unionTestToken
+	err := NewBinDecoder([]byte{0x05}).Decode(&array)
+	assert.EqualError(t, err, `union bin.unionTestToken: no variant of bin.unionTestToken registered for discriminant "5"`)
+
+	_, err = MarshalBin([1]unionTestToken{unionTestCircle{}})
+	assert.EqualError(t, err, `union bin.unionTestToken: no variant of bin.unionTestToken registered for type bin.unionTestCircle`)
+
+	_, err = MarshalBin([1]unionTestToken{})
+	assert.EqualError(t, err, `union bin.unionTestToken: cannot encode nil union value`)
+}
+
+func TestUnion_EnumNameNormalizer(t *testing.T) {
+	type instruction struct {
+		Name    string
+		Command unionTestCommand `bin:"union_tag=Name"`
+	}
+
+	for _, name := range []string{"transfer", "Transfer", "TRANSFER"} {
+		data := append([]byte{byte(len(name)), 0x00, 0x00, 0x00}, name...)
+		data = append(data, 0x05)
+
+		var out instruction
+		require.NoError(t, NewBorshDecoder(data).WithEnumNameNormalizer(strings.ToLower).Decode(&out))
+		// The discriminant field keeps its decoded value.
+		assert.Equal(t, instruction{Name: name, Command: unionTestTransfer{Amount: 5}}, out)
+	}
+
+	var out instruction
+	err := NewBorshDecoder([]byte{0x08, 0x00, 0x00, 0x00, 'T', 'r', 'a', 'n', 's', 'f', 'e', 'r', 0x05}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "Command" field: union_tag: no variant of bin.unionTestCommand registered for discriminant "Transfer"`)
+
+	var inline [1]unionTestCommand
+	require.NoError(t, NewBorshDecoder([]byte{0x04, 0x00, 0x00, 0x00, 'B', 'U', 'R', 'N'}).WithEnumNameNormalizer(strings.ToLower).Decode(&inline))
+	assert.Equal(t, [1]unionTestCommand{unionTestBurn{}}, inline)
+}
+
+type unionTestOpcode interface{}
+
+func init() {
+	RegisterUnion((*unionTestOpcode)(nil), map[interface{}]interface{}{
+		0:           unionTestNumber{},
+		uint16(300): &unionTestSquare{},
+	})
+	RegisterUnitVariant((*unionTestOpcode)(nil), uint32(0xffff), unionTestEnd{})
+	RegisterEnum((*unionTestOpcode)(nil), 2)
+}
+
+type unionTestWide interface{}
+
+func init() {
+	RegisterUnion((*unionTestWide)(nil), map[interface{}]interface{}{
+		uint16(0):   unionTestNumber{},
+		uint16(300): unionTestEnd{},
+	})
+}
+
+func TestRegisterEnum(t *testing.T) {
+	in := []unionTestOpcode{unionTestNumber{Value: 7}, &unionTestSquare{Side: 8}, unionTestEnd{}}
+	expected := []byte{
+		0x03, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x07, 0x00,
+		0x2c, 0x01, 0x08, 0x00, 0x00, 0x00,
+		0xff, 0xff,
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).Encode(in))
+	assert.Equal(t, expected, buf.Bytes())
+
+	var out []unionTestOpcode
+	require.NoError(t, NewBorshDecoder(expected).Decode(&out))
+	assert.Equal(t, in, out)
+
+	var array [1]unionTestOpcode
+	err := NewBinDecoder([]byte{0x01, 0x00}).Decode(&array)
+	assert.EqualError(t, err, `union bin.unionTestOpcode: no variant of bin.unionTestOpcode registered for discriminant "1"`)
+
+	assert.PanicsWithValue(t, "union bin.unionTestOpcode: discriminant 65536 overflows 2 bytes", func() {
+		RegisterUnitVariant((*unionTestOpcode)(nil), 0x10000, unionTestPoint{})
+	})
+	assert.PanicsWithValue(t, "union bin.unionTestWide: discriminant 300 overflows 1 bytes", func() {
+		RegisterEnum((*unionTestWide)(nil), 1)
+	})
+	assert.PanicsWithValue(t, "union bin.unionTestOpcode: invalid discriminant width of 3 bytes", func() {
+		RegisterEnum((*unionTestOpcode)(nil), 3)
+	})
+	assert.PanicsWithValue(t, "union bin.unionTestCommand: discriminant of kind string doesn't have a width", func() {
+		RegisterEnum((*unionTestCommand)(nil), 4)
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// unknownFieldIndexes caches the index of the `bin:"unknown"` field of struct types, by type.
+var unknownFieldIndexes sync.Map
+
+type unknownFieldIndexEntry struct {
+	index int
+	err   error
+}
+
+// unknownFieldIndex returns the index of the `bin:"unknown"` field
+// of the struct type rt, or -1 if it has none.
+func unknownFieldIndex(rt reflect.Type) (int, error) {
+	if cached, ok := unknownFieldIndexes.Load(rt); ok {
+		entry := cached.(*unknownFieldIndexEntry)
+		return entry.index, entry.err
+	}
+	index, err := newUnknownFieldIndex(rt)
+	unknownFieldIndexes.Store(rt, &unknownFieldIndexEntry{index: index, err: err})
+	return index, err
+}
+
+func newUnknownFieldIndex(rt reflect.Type) (int, error) {
+	index := -1
+	for i := 0; i < rt.NumField(); i++ {
+		if !parseFieldTag(rt.Field(i).Tag).Unknown {
+			continue
+		}
+		if index >= 0 {
+			return -1, fmt.Errorf("unknown: %s has more than one unknown field", rt)
+		}
+		if rt.Field(i).Type != byteSliceType {
+			return -1, fmt.Errorf("unknown: expected a []byte field, got %s", rt.Field(i).Type)
+		}
+		index = i
+	}
+	return index, nil
+}
+
+// decodeUnknownField reads the bytes remaining after the known fields of the
+// struct, up to the end of the decoder (or of the frame of a sub-decoder),
+// into its `bin:"unknown"` field, if any, so that they can be encoded back.
+func (dec *Decoder) decodeUnknownField(rt reflect.Type, rv reflect.Value) error {
+	index, err := unknownFieldIndex(rt)
+	if err != nil || index < 0 {
+		return err
+	}
+	field := rv.Field(index)
+	if !field.CanSet() {
+		return fmt.Errorf("unknown: cannot set field %q", rt.Field(index).Name)
+	}
+	data, err := dec.ReadNBytes(dec.Remaining())
+	if err != nil {
+		return fmt.Errorf("unknown: %w", err)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read unknown bytes", zap.Int("len", len(data)))
+	}
+	if len(data) == 0 {
+		field.Set(reflect.Zero(byteSliceType))
+		return nil
+	}
+	// ReadNBytes returns a copy, which doesn't share the data of the decoder.
+	field.SetBytes(data)
+	return nil
+}
+
+// encodeUnknownField writes the `bin:"unknown"` field of the struct, if any,
+// as is after its known fields.
+func (e *Encoder) encodeUnknownField(rt reflect.Type, rv reflect.Value) error {
+	index, err := unknownFieldIndex(rt)
+	if err != nil || index < 0 {
+		return err
+	}
+	return e.toWriter(rv.Field(index).Bytes())
+}
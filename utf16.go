@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+)
+
+// readUTF16Units reads a u16 count of code units,
+// then the UTF-16 code units, with the provided byte order.
+func (dec *Decoder) readUTF16Units(order binary.ByteOrder) ([]uint16, error) {
+	count, err := dec.ReadUint16(order)
+	if err != nil {
+		return nil, fmt.Errorf("utf16 string: length: %w", err)
+	}
+	if err := dec.checkStringLen(uint64(count)*2, nil); err != nil {
+		return nil, fmt.Errorf("utf16 string: %w", err)
+	}
+	if int(count)*2 > dec.Remaining() {
+		return nil, errorf(ErrShortBuffer, "utf16 string: %d code units exceed the %d remaining bytes", count, dec.Remaining())
+	}
+	units := make([]uint16, count)
+	for i := range units {
+		units[i] = order.Uint16(dec.data[dec.pos:])
+		dec.pos += 2
+	}
+	return units, nil
+}
+
+// ReadUTF16String reads a string encoded as a u16 count of UTF-16 code units,
+// then the code units, with the provided byte order (e.g. UTF-16LE in Windows formats).
+// Invalid surrogates are replaced with U+FFFD; see ReadUTF16StringStrict.
+func (dec *Decoder) ReadUTF16String(order binary.ByteOrder) (out string, err error) {
+	units, err := dec.readUTF16Units(order)
+	if err != nil {
+		return "", err
+	}
+	out = string(utf16.Decode(units))
+	if traceEnabled {
+		zlog.Debug("decode: read utf16 string", zap.String("val", out))
+	}
+	return out, nil
+}
+
+// ReadUTF16StringStrict is like ReadUTF16String, but returns an error
+// if the string contains an unpaired surrogate.
+func (dec *Decoder) ReadUTF16StringStrict(order binary.ByteOrder) (out string, err error) {
+	units, err := dec.readUTF16Units(order)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < len(units); i++ {
+		switch u := units[i]; {
+		case u >= 0xd800 && u < 0xdc00:
+			if i+1 == len(units) || units[i+1] < 0xdc00 || units[i+1] >= 0xe000 {
+				return "", fmt.Errorf("utf16 string: unpaired high surrogate %#04x at code unit %d", u, i)
+			}
+			i++
+		case u >= 0xdc00 && u < 0xe000:
+			return "", fmt.Errorf("utf16 string: unpaired low surrogate %#04x at code unit %d", u, i)
+		}
+	}
+	out = string(utf16.Decode(units))
+	if traceEnabled {
+		zlog.Debug("decode: read utf16 string", zap.String("val", out))
+	}
+	return out, nil
+}
+
+// WriteUTF16String writes a string as a u16 count of UTF-16 code units,
+// then the code units, with the provided byte order.
+func (e *Encoder) WriteUTF16String(s string, order binary.ByteOrder) error {
+	units := utf16.Encode([]rune(s))
+	if len(units) > 0xffff {
+		return errorf(ErrInvalidLength, "utf16 string: %d code units overflow the u16 length", len(units))
+	}
+	if err := e.WriteUint16(uint16(len(units)), order); err != nil {
+		return err
+	}
+	buf := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(buf[2*i:], u)
+	}
+	return e.WriteBytes(buf, false)
+}
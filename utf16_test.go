@@ -0,0 +1,63 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTF16String(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUTF16String("hé😀", binary.LittleEndian))
+	assert.Equal(t, []byte{
+		0x04, 0x00,
+		'h', 0x00, 0xe9, 0x00, 0x3d, 0xd8, 0x00, 0xde,
+	}, buf.Bytes())
+
+	out, err := NewBinDecoder(buf.Bytes()).ReadUTF16String(binary.LittleEndian)
+	require.NoError(t, err)
+	assert.Equal(t, "hé😀", out)
+
+	out, err = NewBinDecoder(buf.Bytes()).ReadUTF16StringStrict(binary.LittleEndian)
+	require.NoError(t, err)
+	assert.Equal(t, "hé😀", out)
+
+	buf.Reset()
+	require.NoError(t, NewBinEncoder(buf).WriteUTF16String("a", binary.BigEndian))
+	out, err = NewBinDecoder(buf.Bytes()).ReadUTF16String(binary.BigEndian)
+	require.NoError(t, err)
+	assert.Equal(t, "a", out)
+
+	// Unpaired high surrogate.
+	invalid := []byte{0x02, 0x00, 0x3d, 0xd8, 'a', 0x00}
+	out, err = NewBinDecoder(invalid).ReadUTF16String(binary.LittleEndian)
+	require.NoError(t, err)
+	assert.Equal(t, "�a", out)
+
+	_, err = NewBinDecoder(invalid).ReadUTF16StringStrict(binary.LittleEndian)
+	assert.EqualError(t, err, "utf16 string: unpaired high surrogate 0xd83d at code unit 0")
+
+	_, err = NewBinDecoder([]byte{0x01, 0x00, 0x00, 0xdc}).ReadUTF16StringStrict(binary.LittleEndian)
+	assert.EqualError(t, err, "utf16 string: unpaired low surrogate 0xdc00 at code unit 0")
+
+	_, err = NewBinDecoder([]byte{0x02, 0x00, 'a', 0x00}).ReadUTF16String(binary.LittleEndian)
+	assert.EqualError(t, err, "utf16 string: 2 code units exceed the 2 remaining bytes")
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// UUID is a 16-byte universally unique identifier. It's encoded as its 16 bytes
+// in the RFC 4122 order, or in the mixed-endian layout of Microsoft GUIDs
+// if its struct field is tagged with `bin:"guid"`.
+type UUID [16]byte
+
+var uuidType = reflect.TypeOf(UUID{})
+
+// ParseUUID parses the canonical 8-4-4-4-12 hex form of a UUID,
+// e.g. "123e4567-e89b-12d3-a456-426614174000".
+func ParseUUID(s string) (out UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return out, fmt.Errorf("uuid: invalid format %q", s)
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(out[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("uuid: invalid format %q: %w", s, err)
+	}
+	return out, nil
+}
+
+// String returns the canonical 8-4-4-4-12 hex form of the UUID.
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+func (u UUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.String())
+}
+
+func (u *UUID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	out, err := ParseUUID(s)
+	if err != nil {
+		return err
+	}
+	*u = out
+	return nil
+}
+
+func (u *UUID) UnmarshalWithDecoder(dec *Decoder) error {
+	out, err := dec.ReadUUID()
+	if err != nil {
+		return err
+	}
+	*u = out
+	return nil
+}
+
+func (u UUID) MarshalWithEncoder(enc *Encoder) error {
+	return enc.WriteUUID(u)
+}
+
+// swapGUID converts between the RFC 4122 byte order of a UUID and the
+// mixed-endian layout of a Microsoft GUID, whose first three groups are little endian.
+func swapGUID(u UUID) UUID {
+	u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+	u[4], u[5] = u[5], u[4]
+	u[6], u[7] = u[7], u[6]
+	return u
+}
+
+// ReadUUID reads a UUID of 16 bytes in the RFC 4122 order.
+func (dec *Decoder) ReadUUID() (out UUID, err error) {
+	data, err := dec.ReadNBytes(len(out))
+	if err != nil {
+		return out, fmt.Errorf("uuid: %w", err)
+	}
+	copy(out[:], data)
+	return out, nil
+}
+
+// ReadGUID reads a UUID in the mixed-endian layout of a Microsoft GUID:
+// its first three groups (of 4, 2 and 2 bytes) are little endian.
+func (dec *Decoder) ReadGUID() (out UUID, err error) {
+	if out, err = dec.ReadUUID(); err != nil {
+		return out, err
+	}
+	return swapGUID(out), nil
+}
+
+// WriteUUID writes the 16 bytes of u in the RFC 4122 order.
+func (e *Encoder) WriteUUID(u UUID) error {
+	return e.WriteBytes(u[:], false)
+}
+
+// WriteGUID writes u in the mixed-endian layout of a Microsoft GUID (see ReadGUID).
+func (e *Encoder) WriteGUID(u UUID) error {
+	guid := swapGUID(u)
+	return e.WriteBytes(guid[:], false)
+}
+
+// decodeGUID reads a `bin:"guid"` UUID field.
+func (dec *Decoder) decodeGUID(rv reflect.Value) error {
+	if rv.Type() != uuidType {
+		return fmt.Errorf("guid: expected a bin.UUID field, got %s", rv.Type())
+	}
+	out, err := dec.ReadGUID()
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(out))
+	return nil
+}
+
+// encodeGUID writes a `bin:"guid"` UUID field.
+func (e *Encoder) encodeGUID(rv reflect.Value) error {
+	if rv.Type() != uuidType {
+		return fmt.Errorf("guid: expected a bin.UUID field, got %s", rv.Type())
+	}
+	return e.WriteGUID(rv.Interface().(UUID))
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUID(t *testing.T) {
+	u, err := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	require.NoError(t, err)
+	assert.Equal(t, UUID{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, u)
+	assert.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", u.String())
+
+	_, err = ParseUUID("00112233445566778899aabbccddeeff")
+	assert.EqualError(t, err, `uuid: invalid format "00112233445566778899aabbccddeeff"`)
+
+	data, err := json.Marshal(u)
+	require.NoError(t, err)
+	assert.Equal(t, `"00112233-4455-6677-8899-aabbccddeeff"`, string(data))
+	var fromJSON UUID
+	require.NoError(t, json.Unmarshal(data, &fromJSON))
+	assert.Equal(t, u, fromJSON)
+
+	type record struct {
+		ID    UUID
+		Class UUID `bin:"guid"`
+	}
+	in := record{ID: u, Class: u}
+	expected := []byte{
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+		0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(in))
+			assert.Equal(t, expected, buf.Bytes())
+
+			var out record
+			require.NoError(t, NewDecoderWithEncoding(expected, encoding).Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+
+	size, ok := FixedSize(reflect.TypeOf(record{}), EncodingBin)
+	assert.True(t, ok)
+	assert.Equal(t, 32, size)
+
+	_, err = NewBinDecoder(expected[:10]).ReadUUID()
+	assert.EqualError(t, err, "uuid: required [16] bytes, remaining [10]")
+
+	var wrong struct {
+		ID [16]byte `bin:"guid"`
+	}
+	err = NewBinDecoder(expected).Decode(&wrong)
+	assert.EqualError(t, err, `error while decoding "ID" field: guid: expected a bin.UUID field, got [16]uint8`)
+}